@@ -5,16 +5,22 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/szonov/godlna/config"
 	"github.com/szonov/godlna/dlna"
 	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/dlna/profiles"
 	"github.com/szonov/godlna/logger"
 	"github.com/szonov/godlna/network"
 	"github.com/szonov/godlna/pkg/ffmpeg"
@@ -42,12 +48,30 @@ var (
 	listenPort      int
 	minissdpdSocket string
 	logLevel        string
+	bookmarkMode    string
+	clientAliases   StringList
+	recentlyAdded   string
+	maxTranscodes   int
+	profilesFile    string
+	reindexWorkers  int
+	configFile      string
+	apiToken        string
+	apiAllow        StringList
+
+	// conf is content of configuration file, flags set in command line take precedence
+	conf     = &config.Config{}
+	flagsSet = make(map[string]bool)
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "db" {
+		runDbCommand(os.Args[2:])
+		return
+	}
+
 	v4faceDefault := network.DefaultV4Interface()
 
-	flag.StringVar(&dsn, "dsn", "database=godlna", "database `dsn` string")
+	flag.StringVar(&dsn, "dsn", "database=godlna", "database `dsn` string, postgres dsn, file:///path/to/godlna.db for embedded storage or memory:// for in-memory storage")
 	flag.Var(&videoDirs, "root", "`directory` containing video files, can be specified multiple times. (default is "+defaultVideoRoot()+")")
 	flag.StringVar(&friendlyName, "name", "GoDLNA", "`friendlyName` as you see it on TV")
 	flag.StringVar(&listenInterface, "eth", v4faceDefault.Interface.Name, "network `interface` name")
@@ -55,16 +79,26 @@ func main() {
 	flag.IntVar(&listenPort, "port", 50003, "on which `port` run dlna server")
 	flag.StringVar(&minissdpdSocket, "minissdpd", defaultMinissdpd(), "Minissdp `socket` file, pass empty string to disable")
 	flag.StringVar(&logLevel, "log", "info", "Log `level`, accepted values are: systemd, debug, info, warn, error")
+	flag.StringVar(&bookmarkMode, "bookmarks", "shared", "Bookmarks `mode`, accepted values are: shared (one for all TVs), client (own for every TV)")
+	flag.Var(&clientAliases, "client-alias", "client alias in format `match=name`, where match is remote IP or part of User-Agent, can be specified multiple times")
+	flag.StringVar(&recentlyAdded, "recently-added", "50", "`window` of \"Recently Added\" container: amount of videos (50), age in days (14d) or both (50,14d), 0 to hide container")
+	flag.IntVar(&maxTranscodes, "max-transcodes", dlna.DefaultMaxTranscodes, "max `amount` of concurrent transcodes for clients which can't decode video, 0 to disable transcoding")
+	flag.StringVar(&profilesFile, "profiles", "", "JSON `file` with client profiles, extends and overrides built-in profiles")
+	flag.IntVar(&reindexWorkers, "reindex-workers", backend.DefaultReindexWorkers, "`amount` of videos reindexed in parallel (ffprobe and thumbnails)")
+	flag.StringVar(&apiToken, "api-token", "", "`token` required by mutating admin API requests (Authorization: Bearer token), empty for no token")
+	flag.Var(&apiAllow, "api-allow", "`ip` or network (192.168.1.0/24) allowed to use admin API, can be specified multiple times (default is all)")
+	flag.StringVar(&configFile, "config", "", "configuration `file` (TOML), flags override its settings, reloaded on SIGHUP")
 	flag.Parse()
 
+	applyConfig(configFile)
 	makeLogger(logLevel)
 
 	if len(videoDirs) == 0 {
 		videoDirs = append(videoDirs, defaultVideoRoot())
 	}
 
-	psql := makeDbConnection(dsn)
-	back := makeBackend(videoDirs, psql)
+	driver := makeDatabaseDriver(dsn)
+	back := makeBackend(videoDirs, driver)
 
 	if minissdpdSocket != "" && !ssdp.IsSocket(minissdpdSocket) {
 		slog.Warn("minissdpd socket disabled, incorrect", "socket", minissdpdSocket)
@@ -95,6 +129,14 @@ func main() {
 		syscall.SIGTERM,
 	)
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(back, dlnaServer, ssdpServer)
+		}
+	}()
+
 	go func() {
 		<-c
 		// terminate backend, ssdp, dlna servers
@@ -128,23 +170,267 @@ func main() {
 }
 
 func makeLogger(level string) {
-	var loggerLogLevel slog.Level
+	loggerLogLevel, onlyMessage, err := parseLogLevel(level)
+	if err != nil {
+		criticalError(err)
+	}
+	logger.SetOnlyMessage(onlyMessage)
+	logger.InitLogger(loggerLogLevel)
+}
+
+// parseLogLevel returns level of logger and flag of short messages format (systemd)
+func parseLogLevel(level string) (slog.Level, bool, error) {
 	switch strings.ToLower(level) {
 	case "debug":
-		loggerLogLevel = slog.LevelDebug
+		return slog.LevelDebug, false, nil
 	case "info":
-		loggerLogLevel = slog.LevelInfo
+		return slog.LevelInfo, false, nil
 	case "warn":
-		loggerLogLevel = slog.LevelWarn
+		return slog.LevelWarn, false, nil
 	case "error":
-		loggerLogLevel = slog.LevelError
+		return slog.LevelError, false, nil
 	case "systemd":
-		logger.OnlyMessage = true
-		loggerLogLevel = slog.LevelInfo
+		return slog.LevelInfo, true, nil
+	}
+	return slog.LevelInfo, false, fmt.Errorf("invalid log level: %s", level)
+}
+
+// applyConfig loads configuration file and uses its settings for flags not set in command line
+func applyConfig(file string) {
+	var err error
+	if conf, err = config.Load(file); err != nil {
+		// logger is not configured yet
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		flagsSet[f.Name] = true
+	})
+
+	configString := func(name string, value *string, configured string) {
+		if !flagsSet[name] && configured != "" {
+			*value = configured
+		}
+	}
+	configInt := func(name string, value *int, configured int) {
+		if !flagsSet[name] && configured != 0 {
+			*value = configured
+		}
+	}
+
+	configString("dsn", &dsn, conf.Database.DSN)
+	configString("name", &friendlyName, conf.Name)
+	configString("eth", &listenInterface, conf.Network.Interface)
+	configString("ip", &listenIP, conf.Network.IP)
+	configInt("port", &listenPort, conf.Network.Port)
+	configString("log", &logLevel, conf.Log)
+	configString("bookmarks", &bookmarkMode, conf.Bookmarks)
+	configString("recently-added", &recentlyAdded, conf.RecentlyAdded)
+	configString("profiles", &profilesFile, conf.ProfilesFile)
+	configInt("reindex-workers", &reindexWorkers, conf.Reindex.Workers)
+	configString("api-token", &apiToken, conf.API.Token)
+
+	if !flagsSet["root"] && len(conf.Roots) > 0 {
+		videoDirs = conf.Roots
+	}
+	if !flagsSet["api-allow"] && len(conf.API.Allow) > 0 {
+		apiAllow = conf.API.Allow
+	}
+	if !flagsSet["minissdpd"] && conf.Network.Minissdpd != nil {
+		minissdpdSocket = *conf.Network.Minissdpd
+	}
+	if !flagsSet["max-transcodes"] && conf.MaxTranscodes != nil {
+		maxTranscodes = *conf.MaxTranscodes
+	}
+	if !flagsSet["client-alias"] {
+		for _, c := range conf.Clients {
+			clientAliases = append(clientAliases, c.Match+"="+c.Name)
+		}
+	}
+}
+
+// reloadConfig applies settings of configuration file which can be safely changed at runtime:
+// log level, thumbnail style, ignore patterns and friendly name
+func reloadConfig(back *backend.Backend, srv *dlna.Server, ssdpServer ssdp.Server) {
+	if configFile == "" {
+		slog.Warn("SIGHUP ignored, no configuration file")
+		return
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		slog.Error("configuration is not reloaded", "err", err)
+		return
+	}
+
+	level := logLevel
+	if !flagsSet["log"] && cfg.Log != "" {
+		level = cfg.Log
+	}
+	loggerLogLevel, onlyMessage, err := parseLogLevel(level)
+	if err != nil {
+		slog.Error("configuration is not reloaded", "err", err)
+		return
+	}
+
+	if err = back.SetThumbnailStyle(makeThumbnailStyle(cfg.Thumbnail)); err != nil {
+		slog.Error("configuration is not reloaded", "err", err)
+		return
+	}
+
+	if !slices.Equal(cfg.Index.Ignore, conf.Index.Ignore) {
+		if err = back.SetIgnorePatterns(cfg.Index.Ignore); err != nil {
+			slog.Error("ignore patterns are not reloaded", "err", err)
+		} else if err = back.Rescan(0); err != nil {
+			// already indexed objects matched by new patterns are removed by rescan
+			slog.Warn("rescan after change of ignore patterns failed", "err", err)
+		}
+	}
+
+	file := profilesFile
+	if !flagsSet["profiles"] {
+		file = cfg.ProfilesFile
+	}
+	if reg, err := profiles.Load(file, cfg.Profiles...); err != nil {
+		slog.Error("client profiles are not reloaded", "err", err)
+	} else {
+		// profiles file is read again even if it is not changed, it may be edited
+		srv.SetProfiles(reg)
+		profilesFile = file
+	}
+
+	logger.SetLevel(loggerLogLevel)
+	logger.SetOnlyMessage(onlyMessage)
+
+	name := friendlyName
+	if !flagsSet["name"] && cfg.Name != "" {
+		name = cfg.Name
+	}
+	if name != friendlyName {
+		if err = srv.SetFriendlyName(name); err != nil {
+			slog.Error("friendly name is not changed", "err", err)
+		} else {
+			friendlyName = name
+			ssdpServer.Announce()
+		}
+	}
+
+	conf = cfg
+	slog.Info("configuration reloaded", "file", configFile, "log", level, "name", friendlyName)
+}
+
+func makeDatabaseDriver(dsn string) backend.DatabaseDriver {
+	if strings.HasPrefix(dsn, "memory:") {
+		slog.Warn("in-memory storage used, bookmarks will be lost after restart")
+		return backend.NewMemoryDriver()
+	}
+	if strings.HasPrefix(dsn, "file:") {
+		return makeEmbeddedDriver(dsn)
+	}
+	driver := backend.NewPostgresDriver(makeDbConnection(dsn))
+	if err := driver.Migrate(); err != nil {
+		criticalError(err)
+	}
+	return driver
+}
+
+// runDbCommand handles `godlna db [-dsn DSN] [-config FILE] migrate|status|broken|requeue [id]`
+func runDbCommand(args []string) {
+	fs := flag.NewFlagSet("db", flag.ExitOnError)
+	fs.StringVar(&dsn, "dsn", "database=godlna", "postgres database `dsn` string")
+	fs.StringVar(&logLevel, "log", "info", "Log `level`, accepted values are: systemd, debug, info, warn, error")
+	fs.StringVar(&configFile, "config", "", "configuration `file` (TOML), used for dsn and log level when flags are not set")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s db [OPTIONS] migrate|status|broken|requeue [id]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if configFile != "" {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+		if !set["dsn"] && cfg.Database.DSN != "" {
+			dsn = cfg.Database.DSN
+		}
+		if !set["log"] && cfg.Log != "" {
+			logLevel = cfg.Log
+		}
+	}
+
+	makeLogger(logLevel)
+
+	if strings.HasPrefix(dsn, "memory:") || strings.HasPrefix(dsn, "file:") {
+		// embedded storage is owned by running server, it keeps whole database in memory
+		criticalError(fmt.Errorf("db commands work with postgres only, use admin API (/api/reindex) for dsn: %s", dsn))
+	}
+	driver := backend.NewPostgresDriver(makeDbConnection(dsn))
+
+	switch fs.Arg(0) {
+	case "migrate":
+		if err := driver.Migrate(); err != nil {
+			criticalError(err)
+		}
+		fallthrough
+	case "status":
+		current, latest, err := driver.SchemaVersion()
+		if err != nil {
+			criticalError(err)
+		}
+		slog.Info("database schema", "version", current, "latest", latest)
+	case "broken":
+		// report of videos quarantined after too many failed reindexing
+		res, err := driver.GetObjects(backend.ObjectSearchFilter{Status: backend.StatusBroken, Sort: backend.SortById})
+		if err != nil {
+			criticalError(err)
+		}
+		for _, o := range res.Items {
+			fmt.Printf("%d\t%d\t%s\t%s\n", o.ID, o.ReindexFailures, o.Path, o.ReindexError)
+		}
+	case "requeue":
+		// all broken videos, or only one with given id
+		id := 0
+		if fs.Arg(1) != "" {
+			var err error
+			if id, err = strconv.Atoi(fs.Arg(1)); err != nil || id <= 0 {
+				criticalError(fmt.Errorf("invalid object id: %s", fs.Arg(1)))
+			}
+		}
+		count, err := driver.RequeueBroken(id)
+		if err != nil {
+			criticalError(err)
+		}
+		// running server finds them by periodic poll of the database
+		slog.Info("broken videos requeued for reindexing", "count", count, "within", backend.ReindexPollInterval)
 	default:
-		criticalError(fmt.Errorf("invalid log level: %s", logLevel))
+		fs.Usage()
+		os.Exit(2)
 	}
-	logger.InitLogger(loggerLogLevel)
+}
+
+func makeEmbeddedDriver(dsn string) *backend.EmbeddedDriver {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		criticalError(fmt.Errorf("invalid dsn '%s': %w", dsn, err))
+	}
+	// file:///absolute/path or file:relative/path
+	file := u.Path
+	if u.Opaque != "" {
+		file = u.Opaque
+	}
+	if file == "" {
+		criticalError(fmt.Errorf("invalid dsn '%s': empty file path", dsn))
+	}
+	driver, err := backend.NewEmbeddedDriver(file)
+	if err != nil {
+		criticalError(err)
+	}
+	return driver
 }
 
 func makeDbConnection(dsn string) *pgxpool.Pool {
@@ -162,7 +448,7 @@ func makeDbConnection(dsn string) *pgxpool.Pool {
 	return engine
 }
 
-func makeBackend(dirs []string, psql *pgxpool.Pool) *backend.Backend {
+func makeBackend(dirs []string, driver backend.DatabaseDriver) *backend.Backend {
 
 	if !ffmpeg.Autodetect() {
 		criticalError(fmt.Errorf("ffmpeg binary not found"))
@@ -170,12 +456,68 @@ func makeBackend(dirs []string, psql *pgxpool.Pool) *backend.Backend {
 	if !ffprobe.Autodetect() {
 		criticalError(fmt.Errorf("ffprobe binary not found"))
 	}
-	driver := backend.NewPostgresDriver(psql)
+	if len(conf.Index.VideoExtensions) > 0 {
+		backend.SetVideoExtensions(conf.Index.VideoExtensions)
+	}
 	back, err := backend.NewBackend(dirs, driver)
 	if err != nil {
 		criticalError(err)
 	}
-	return back
+	if err = back.SetIgnorePatterns(conf.Index.Ignore); err != nil {
+		criticalError(err)
+	}
+	if err = back.SetThumbnailStyle(makeThumbnailStyle(conf.Thumbnail)); err != nil {
+		criticalError(err)
+	}
+	return back.
+		WithRecentlyAdded(makeRecentlyAddedWindow(recentlyAdded)).
+		WithReindexWorkers(reindexWorkers).
+		WithReindexIntervals(conf.Reindex.Delay.Duration, conf.Reindex.Interval.Duration)
+}
+
+// makeThumbnailStyle returns default thumbnail style with overridden configured values
+func makeThumbnailStyle(c config.Thumbnail) backend.ThumbnailStyle {
+	style := backend.DefaultThumbnailStyle
+	if c.Width != 0 {
+		style.Width = c.Width
+	}
+	if c.Height != 0 {
+		style.Height = c.Height
+	}
+	if c.Quality != 0 {
+		style.Quality = c.Quality
+	}
+	if c.ProgressSize != 0 {
+		style.ProgressSize = c.ProgressSize
+	}
+	if c.ProgressPosition != "" {
+		style.ProgressPosition = c.ProgressPosition
+	}
+	if c.CompleteLeeway.Duration != 0 {
+		style.CompleteLeeway = c.CompleteLeeway.Duration
+	}
+	style.ProgressCompleteColor = c.ProgressCompleteColor
+	style.ProgressIncompleteColor = c.ProgressIncompleteColor
+	style.ProgressFullColor = c.ProgressFullColor
+	return style
+}
+
+func makeRecentlyAddedWindow(value string) backend.RecentlyAddedWindow {
+	var w backend.RecentlyAddedWindow
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		days, isDays := strings.CutSuffix(part, "d")
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			criticalError(fmt.Errorf("invalid recently added window: %s", value))
+		}
+		if isDays {
+			w.Days = n
+		} else {
+			w.Count = n
+		}
+	}
+	return w
 }
 
 func makeNetwork(eth string, ip string) (network.V4Interface, string) {
@@ -192,24 +534,82 @@ func makeNetwork(eth string, ip string) (network.V4Interface, string) {
 
 func makeDLNAServer(friendlyName string, listenAddress string, back *backend.Backend) *dlna.Server {
 	srv := dlna.NewServer(friendlyName, listenAddress, back)
+	srv.BookmarkMode = makeBookmarkMode(bookmarkMode)
+	srv.ClientAliases = makeClientAliases(clientAliases)
+	srv.MaxTranscodes = maxTranscodes
+	srv.Profiles = makeProfiles(profilesFile, conf.Profiles)
+	srv.APIToken = apiToken
+	srv.APIAllow = makeAPIAllow(apiAllow)
 	srv.DebugRequest = true
 	//srv.DebugRequestHeader = true
 	//srv.DebugRequestBody = true
 	return srv
 }
 
+func makeProfiles(file string, extra []*profiles.Profile) *profiles.Registry {
+	reg, err := profiles.Load(file, extra...)
+	if err != nil {
+		criticalError(err)
+	}
+	return reg
+}
+
+func makeBookmarkMode(mode string) dlna.BookmarkMode {
+	switch strings.ToLower(mode) {
+	case "shared":
+		return dlna.BookmarkShared
+	case "client":
+		return dlna.BookmarkPerClient
+	default:
+		criticalError(fmt.Errorf("invalid bookmarks mode: %s", mode))
+	}
+	return dlna.BookmarkShared
+}
+
+func makeClientAliases(list []string) []dlna.ClientAlias {
+	aliases := make([]dlna.ClientAlias, 0, len(list))
+	for _, item := range list {
+		match, name, found := strings.Cut(item, "=")
+		if !found || match == "" || name == "" {
+			criticalError(fmt.Errorf("invalid client alias: %s", item))
+		}
+		aliases = append(aliases, dlna.ClientAlias{Match: match, Name: name})
+	}
+	return aliases
+}
+
+func makeAPIAllow(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, item := range list {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			criticalError(fmt.Errorf("invalid api allow: %s", item))
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
 func makeSsdpOptions(s *dlna.Server) *ssdp.Options {
 	services := make([]string, 0)
 	for _, serv := range s.DeviceDescription.Device.ServiceList {
 		services = append(services, serv.ServiceType)
 	}
+	notifyInterval := 1 * time.Minute
+	if conf.Network.NotifyInterval.Duration > 0 {
+		notifyInterval = conf.Network.NotifyInterval.Duration
+	}
 	return &ssdp.Options{
 		Location:       "http://" + s.ListenAddress + s.DeviceDescription.Location,
 		ServerHeader:   dlna.ServerHeader,
 		DeviceType:     s.DeviceDescription.Device.DeviceType,
 		DeviceUDN:      s.DeviceDescription.Device.UDN,
 		ServiceList:    services,
-		NotifyInterval: 1 * time.Minute,
+		NotifyInterval: notifyInterval,
 	}
 }
 
@@ -54,14 +54,14 @@ func main() {
 
 	videoFile, err := filepath.Abs(inputFile)
 	if err != nil {
-		fmt.Printf("ERROR: failed to get absolute path for input '%s': %w\n", videoFile, err)
+		fmt.Printf("ERROR: failed to get absolute path for input '%s': %v\n", videoFile, err)
 		os.Exit(ExitFileError)
 	}
 
 	thumbFile := videoFile + ".jpg"
 	if outputFile != "" {
 		if thumbFile, err = filepath.Abs(outputFile); err != nil {
-			fmt.Printf("ERROR: failed to get absolute path for output '%s': %w\n", outputFile, err)
+			fmt.Printf("ERROR: failed to get absolute path for output '%s': %v\n", outputFile, err)
 			os.Exit(ExitFileError)
 		}
 	}
@@ -0,0 +1,160 @@
+// Package config describes configuration file of godlna (TOML), settings of the file are overridden
+// by command line flags. Example:
+//
+//	name = "GoDLNA"
+//	roots = ["/volume1/video"]
+//	log = "info"
+//
+//	[network]
+//	interface = "eth0"
+//	port = 50003
+//
+//	[database]
+//	dsn = "file:///volume1/scripts/godlna/godlna.db"
+//
+//	[thumbnail]
+//	width = 480
+//	height = 300
+//	progress_position = "bottom"
+//
+//	[index]
+//	ignore = ["*.sample.*", ".Trash*", "/volume1/video/private"]
+//
+//	[api]
+//	token = "secret"
+//	allow = ["127.0.0.1", "192.168.1.0/24"]
+//
+//	[[client]]
+//	match = "192.168.1.20"
+//	name = "bedroom"
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/szonov/godlna/dlna/profiles"
+)
+
+// Config is content of configuration file, empty values mean "not configured", pointers are used
+// for values where zero or empty string has own meaning
+type Config struct {
+	// Name is friendlyName as you see it on TV
+	Name  string   `toml:"name"`
+	Roots []string `toml:"roots"`
+	// Log is level of logging: systemd, debug, info, warn, error
+	Log string `toml:"log"`
+	// Bookmarks is bookmarks mode: shared or client
+	Bookmarks string `toml:"bookmarks"`
+	// RecentlyAdded is window of "Recently Added" container: "50", "14d" or "50,14d"
+	RecentlyAdded string `toml:"recently_added"`
+	MaxTranscodes *int   `toml:"max_transcodes"`
+
+	Network   Network   `toml:"network"`
+	Database  Database  `toml:"database"`
+	Reindex   Reindex   `toml:"reindex"`
+	Thumbnail Thumbnail `toml:"thumbnail"`
+	Index     Index     `toml:"index"`
+	API       API       `toml:"api"`
+
+	// Clients are aliases of the clients, [[client]] tables
+	Clients []Client `toml:"client"`
+
+	// ProfilesFile is JSON file with client profiles, Profiles are profiles defined by [[profile]] tables,
+	// both extend and override built-in profiles
+	ProfilesFile string              `toml:"profiles"`
+	Profiles     []*profiles.Profile `toml:"profile"`
+}
+
+type Network struct {
+	Interface string `toml:"interface"`
+	IP        string `toml:"ip"`
+	Port      int    `toml:"port"`
+	// Minissdpd is socket of minissdpd, empty string disables it
+	Minissdpd *string `toml:"minissdpd"`
+	// NotifyInterval is a period of SSDP Alive messages
+	NotifyInterval Duration `toml:"notify_interval"`
+}
+
+type Database struct {
+	DSN string `toml:"dsn"`
+}
+
+type Reindex struct {
+	Workers int `toml:"workers"`
+	// Delay is a pause after start before first reindexing, Interval is a period of search of dirty videos
+	Delay    Duration `toml:"delay"`
+	Interval Duration `toml:"interval"`
+}
+
+type Thumbnail struct {
+	Width                   int      `toml:"width"`
+	Height                  int      `toml:"height"`
+	Quality                 int      `toml:"quality"`
+	ProgressSize            int      `toml:"progress_size"`
+	ProgressPosition        string   `toml:"progress_position"`
+	ProgressCompleteColor   string   `toml:"progress_complete_color"`
+	ProgressIncompleteColor string   `toml:"progress_incomplete_color"`
+	ProgressFullColor       string   `toml:"progress_full_color"`
+	CompleteLeeway          Duration `toml:"complete_leeway"`
+}
+
+type Index struct {
+	// VideoExtensions replaces built-in list of video extensions
+	VideoExtensions []string `toml:"video_extensions"`
+	// Ignore are glob patterns of excluded files and directories: without slash matched with base name,
+	// with slash matched with absolute path
+	Ignore []string `toml:"ignore"`
+}
+
+type API struct {
+	// Token is required by mutating requests of admin API, empty means not required
+	Token string `toml:"token"`
+	// Allow are IPs or networks (192.168.1.0/24) allowed to use admin API, empty means all
+	Allow []string `toml:"allow"`
+}
+
+type Client struct {
+	Match string `toml:"match"`
+	Name  string `toml:"name"`
+}
+
+// Duration is time.Duration written as string, for example "30s" or "1m30s"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Load reads configuration file, empty file name means empty configuration
+func Load(file string) (*Config, error) {
+	cfg := &Config{}
+	if file == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("(config.Load) failed to read %s: %w", file, err)
+	}
+	meta, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("(config.Load) failed to parse %s: %w", file, err)
+	}
+	if keys := meta.Undecoded(); len(keys) > 0 {
+		return nil, fmt.Errorf("(config.Load) failed to parse %s: unknown field %q", file, keys[0].String())
+	}
+	return cfg, nil
+}
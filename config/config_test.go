@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"1h", time.Hour},
+		{"500ms", 500 * time.Millisecond},
+		{"0s", 0},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := d.UnmarshalText([]byte(tt.text)); err != nil {
+			t.Errorf("UnmarshalText(%s): %v", tt.text, err)
+			continue
+		}
+		if d.Duration != tt.want {
+			t.Errorf("UnmarshalText(%s) = %v; want %v", tt.text, d.Duration, tt.want)
+		}
+		b, err := d.MarshalText()
+		if err != nil {
+			t.Errorf("MarshalText(%v): %v", d, err)
+			continue
+		}
+		var back Duration
+		if err = back.UnmarshalText(b); err != nil || back != d {
+			t.Errorf("MarshalText(%v) = %s, does not round trip", d.Duration, b)
+		}
+	}
+
+	for _, value := range []string{"30", "-1s", "soon", ""} {
+		var d Duration
+		if err := d.UnmarshalText([]byte(value)); err == nil {
+			t.Errorf("UnmarshalText(%s) = %v; want error", value, d.Duration)
+		}
+	}
+}
+
+// writeConfig writes configuration file into temp directory
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "godlna.toml")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+# GoDLNA
+name = "Living room"
+roots = ["/volume1/video", "/volume2/video"]
+max_transcodes = 0
+
+[network]
+port = 50003
+notify_interval = "1m"
+
+[reindex]
+delay = "11s"
+interval = "30s"
+
+[thumbnail]
+complete_leeway = "5s"
+
+[[client]]
+match = "192.168.1.20"
+name = "bedroom"
+
+[[client]]
+match = "UE40C7000"
+name = "kitchen"
+
+[[profile]]
+name = "old tv"
+max_width = 1280
+only_8bit = true
+[profile.match]
+user_agent = "UE40C7000"
+[profile.mime_types]
+matroska = "video/avi"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "Living room" || len(cfg.Roots) != 2 || cfg.MaxTranscodes == nil || *cfg.MaxTranscodes != 0 {
+		t.Errorf("Load(): %+v", cfg)
+	}
+	if cfg.Network.Port != 50003 || cfg.Network.NotifyInterval.Duration != time.Minute {
+		t.Errorf("Load(): network %+v", cfg.Network)
+	}
+	if cfg.Reindex.Delay.Duration != 11*time.Second || cfg.Reindex.Interval.Duration != 30*time.Second {
+		t.Errorf("Load(): reindex %+v", cfg.Reindex)
+	}
+	if cfg.Thumbnail.CompleteLeeway.Duration != 5*time.Second {
+		t.Errorf("Load(): complete leeway %v", cfg.Thumbnail.CompleteLeeway)
+	}
+	if len(cfg.Clients) != 2 || cfg.Clients[1] != (Client{Match: "UE40C7000", Name: "kitchen"}) {
+		t.Errorf("Load(): clients %+v", cfg.Clients)
+	}
+	if len(cfg.Profiles) != 1 || cfg.Profiles[0].Name != "old tv" || cfg.Profiles[0].MaxWidth != 1280 || !cfg.Profiles[0].Only8Bit ||
+		cfg.Profiles[0].Match.UserAgent != "UE40C7000" || cfg.Profiles[0].MimeTypes["matroska"] != "video/avi" {
+		t.Errorf("Load(): profiles %+v", cfg.Profiles)
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	cfg, err := Load("../scripts/godlna.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name == "" || cfg.Reindex.Interval.Duration == 0 {
+		t.Errorf("Load(): %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if cfg, err := Load(""); err != nil || cfg.Name != "" {
+		t.Errorf("Load(\"\") = %+v, %v; want empty configuration", cfg, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Errorf("Load() of missing file: no error")
+	}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"name = \"x\"\n\n[network]\nport = ", "line 4 (last key \"network.port\")"},
+		{"[reindex]\ninterval = \"30\"", "invalid duration: 30"},
+		{"[reindex]\ninterval = 30", "invalid duration: 30"},
+		{"[network]\nprot = 50003", `unknown field "network.prot"`},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), "(config.Load) failed to parse") {
+			t.Errorf("Load(%q) = %v; want error containing %q", tt.content, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package dlna
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/pkg/upnp/events"
+)
+
+const (
+	// apiDefaultLimit is amount of objects returned by list endpoints when limit is not specified
+	apiDefaultLimit = 100
+	// apiMaxLimit is max amount of objects returned by list endpoints, bigger limit is reduced to it
+	apiMaxLimit = 1000
+)
+
+type (
+	// APIController serves admin JSON API under /api/, all operations are done by backend methods
+	APIController struct {
+		back *backend.Backend
+		srv  *Server
+
+		// services are event managers of UPnP services by service name
+		services map[string]*events.Manager
+	}
+
+	apiObject struct {
+		ID              int              `json:"id"`
+		ParentID        *int             `json:"parent_id,omitempty"`
+		Path            string           `json:"path"`
+		Title           string           `json:"title"`
+		Type            string           `json:"type"`
+		Status          string           `json:"status"`
+		Format          string           `json:"format,omitempty"`
+		FileSize        int64            `json:"file_size,omitempty"`
+		VideoCodec      string           `json:"video_codec,omitempty"`
+		AudioCodec      string           `json:"audio_codec,omitempty"`
+		Width           int              `json:"width,omitempty"`
+		Height          int              `json:"height,omitempty"`
+		Channels        int              `json:"channels,omitempty"`
+		Bitrate         int              `json:"bitrate,omitempty"`
+		Frequency       int              `json:"frequency,omitempty"`
+		Duration        int64            `json:"duration,omitempty"`
+		Bookmark        *int64           `json:"bookmark,omitempty"`
+		BookmarkAt      *time.Time       `json:"bookmark_at,omitempty"`
+		Client          string           `json:"client,omitempty"`
+		Date            int64            `json:"date,omitempty"`
+		Online          bool             `json:"online"`
+		AddedAt         *time.Time       `json:"added_at,omitempty"`
+		ReindexAt       *time.Time       `json:"reindex_at,omitempty"`
+		ReindexFailures int              `json:"reindex_failures,omitempty"`
+		ReindexError    string           `json:"reindex_error,omitempty"`
+		Streams         []backend.Stream `json:"streams,omitempty"`
+	}
+
+	apiObjectList struct {
+		Items        []*apiObject `json:"items"`
+		TotalMatches int          `json:"total_matches"`
+	}
+
+	apiSubscriber struct {
+		Service string    `json:"service"`
+		SID     string    `json:"sid"`
+		URLs    []string  `json:"urls"`
+		Timeout time.Time `json:"timeout"`
+		Seq     uint32    `json:"seq"`
+		Expired bool      `json:"expired"`
+	}
+
+	apiError struct {
+		Error string `json:"error"`
+	}
+)
+
+func NewAPIController(srv *Server, services map[string]*events.Manager) *APIController {
+	return &APIController{
+		back:     srv.back,
+		srv:      srv,
+		services: services,
+	}
+}
+
+// HandleNotFound responds to unknown /api/ endpoints
+func (ctl *APIController) HandleNotFound(w http.ResponseWriter, _ *http.Request) {
+	sendJSON(w, http.StatusNotFound, &apiError{Error: "unknown endpoint"})
+}
+
+// HandleObjects lists objects of the library: GET /api/objects?status=public|dirty|broken|all&path=&limit=&offset=
+func (ctl *APIController) HandleObjects(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := backend.ObjectSearchFilter{
+		PathPrefix:       q.Get("path"),
+		Sort:             backend.SortById,
+		WithTotalMatches: true,
+	}
+
+	var err error
+	if filter.Status, err = apiObjectStatus(q.Get("status")); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	if filter.Limit, filter.Offset, err = apiPaging(r); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	res, err := ctl.back.Objects(filter)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, makeAPIObjectList(res))
+}
+
+// HandleObject returns object with streams and parent id: GET /api/objects/{id}?client=
+func (ctl *APIController) HandleObject(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	o, ok := ctl.object(w, r)
+	if !ok {
+		return
+	}
+
+	out := makeAPIObject(o)
+	if parentID, err := ctl.back.ParentId(o); err == nil {
+		out.ParentID = &parentID
+	}
+	if o.Typ == backend.ObjectVideo {
+		streams, err := ctl.back.Streams(o)
+		if err != nil {
+			sendAPIError(w, err)
+			return
+		}
+		out.Streams = streams
+	}
+	sendJSON(w, http.StatusOK, out)
+}
+
+// HandleChildren lists content of folder, exactly as it is seen by TV: GET /api/objects/{id}/children?client=&limit=&offset=
+func (ctl *APIController) HandleChildren(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	o, ok := ctl.object(w, r)
+	if !ok {
+		return
+	}
+	if o.Typ != backend.ObjectFolder {
+		sendAPIError(w, backend.ErrNotFolder)
+		return
+	}
+
+	limit, offset, err := apiPaging(r)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	res, err := ctl.back.Children(o, r.URL.Query().Get("client"), nil, limit, offset)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, makeAPIObjectList(res))
+}
+
+// HandleBookmark sets bookmark of the video: PUT /api/objects/{id}/bookmark?position={ms}&client=,
+// position equal to duration marks video as watched, or clears it: DELETE /api/objects/{id}/bookmark?client=
+func (ctl *APIController) HandleBookmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "PUT, DELETE")
+		sendJSON(w, http.StatusMethodNotAllowed, &apiError{Error: "method not allowed"})
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	client := r.URL.Query().Get("client")
+	if r.Method == http.MethodDelete {
+		err = ctl.back.ClearBookmark(id, client)
+	} else {
+		var position int64
+		if position, err = strconv.ParseInt(r.URL.Query().Get("position"), 10, 64); err != nil || position < 0 {
+			sendAPIError(w, errAPIBadRequest("invalid position"))
+			return
+		}
+		err = ctl.back.SetBookmark(id, position, client, client != "")
+	}
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestoreBookmark sets bookmark of the video to the latest non-zero position from watch history,
+// it is undo of accidental reset of bookmark: POST /api/objects/{id}/bookmark/restore?client=
+func (ctl *APIController) HandleRestoreBookmark(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	client := r.URL.Query().Get("client")
+	position, err := ctl.back.RestoreBookmark(id, client, client != "")
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, map[string]int64{"position": position})
+}
+
+// HandleReindex reindexes video right now: POST /api/objects/{id}/reindex
+func (ctl *APIController) HandleReindex(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	if err = ctl.back.ForceReindex(id); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleThumbnail serves thumbnail of the video: GET /api/objects/{id}/thumbnail?client=,
+// or regenerates it: POST /api/objects/{id}/thumbnail?client=
+func (ctl *APIController) HandleThumbnail(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		o, ok := ctl.object(w, r)
+		if !ok {
+			return
+		}
+		if o.Typ != backend.ObjectVideo {
+			sendAPIError(w, backend.ErrNotVideo)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, o.ThumbPath())
+
+	case http.MethodPost:
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			sendAPIError(w, err)
+			return
+		}
+		if err = ctl.back.RegenerateThumbnail(id, r.URL.Query().Get("client")); err != nil {
+			sendAPIError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		sendJSON(w, http.StatusMethodNotAllowed, &apiError{Error: "method not allowed"})
+	}
+}
+
+// HandleRescan synchronizes folder with file system in background, 0 is for all folders:
+// POST /api/objects/{id}/rescan
+func (ctl *APIController) HandleRescan(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	if err = ctl.back.Rescan(id); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleBookmarks lists partially watched videos ("Continue Watching"): GET /api/bookmarks?client=&limit=&offset=
+func (ctl *APIController) HandleBookmarks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	limit, offset, err := apiPaging(r)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	client := r.URL.Query().Get("client")
+	o, err := ctl.back.Object(backend.ContinueWatchingID, client)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	res, err := ctl.back.Children(o, client, nil, limit, offset)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, makeAPIObjectList(res))
+}
+
+// HandleHistory lists watch history (calls of X_SetBookmark), the newest records first:
+// GET /api/history?id=&client=&since=&until=&limit=&offset=, since and until are RFC 3339 time or date (2006-01-02)
+func (ctl *APIController) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := backend.HistoryFilter{Client: q.Get("client")}
+
+	var err error
+	if v := q.Get("id"); v != "" {
+		if filter.ObjectID, err = strconv.Atoi(v); err != nil {
+			sendAPIError(w, err)
+			return
+		}
+	}
+	if filter.Since, err = apiTime(q.Get("since")); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	if filter.Until, err = apiTime(q.Get("until")); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	if filter.Limit, filter.Offset, err = apiPaging(r); err != nil {
+		sendAPIError(w, err)
+		return
+	}
+
+	list, err := ctl.back.History(filter)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, list)
+}
+
+// HandleReindexQueue returns state of reindexing: GET /api/reindex
+func (ctl *APIController) HandleReindexQueue(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	status, err := ctl.back.ReindexQueue()
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, status)
+}
+
+// HandleBroken lists videos quarantined after too many failed reindexing: GET /api/reindex/broken
+func (ctl *APIController) HandleBroken(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	list, err := ctl.back.BrokenObjects()
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, makeAPIObjectList(&backend.ObjectSearchResponse{Items: list, TotalMatches: len(list)}))
+}
+
+// HandleRequeue returns broken videos to reindexing, all of them when id is not specified:
+// POST /api/reindex/requeue?id=
+func (ctl *APIController) HandleRequeue(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	id := 0
+	if v := r.URL.Query().Get("id"); v != "" {
+		var err error
+		if id, err = strconv.Atoi(v); err != nil {
+			sendAPIError(w, err)
+			return
+		}
+	}
+
+	count, err := ctl.back.Requeue(id)
+	if err != nil {
+		sendAPIError(w, err)
+		return
+	}
+	sendJSON(w, http.StatusOK, map[string]int{"requeued": count})
+}
+
+// HandleSubscribers lists subscribers of UPnP events of all services: GET /api/subscribers
+func (ctl *APIController) HandleSubscribers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	list := make([]apiSubscriber, 0)
+	for _, service := range []string{"ContentDirectory", "ConnectionManager", "X_MS_MediaReceiverRegistrar"} {
+		m, ok := ctl.services[service]
+		if !ok {
+			continue
+		}
+		for _, s := range m.Subscribers() {
+			urls := make([]string, len(s.URLs))
+			for i, u := range s.URLs {
+				urls[i] = u.String()
+			}
+			list = append(list, apiSubscriber{
+				Service: service,
+				SID:     s.SID,
+				URLs:    urls,
+				Timeout: s.Timeout,
+				Seq:     s.Seq,
+				Expired: s.IsExpired(),
+			})
+		}
+	}
+	sendJSON(w, http.StatusOK, list)
+}
+
+// HandleClients lists clients (TVs) connected since start of the server: GET /api/clients
+func (ctl *APIController) HandleClients(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	sendJSON(w, http.StatusOK, ctl.srv.Clients())
+}
+
+// object loads object by {id} path value, regardless of its status, sends error response when failed
+func (ctl *APIController) object(w http.ResponseWriter, r *http.Request) (*backend.Object, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendAPIError(w, err)
+		return nil, false
+	}
+	o, err := ctl.back.AnyObject(id, r.URL.Query().Get("client"))
+	if err != nil {
+		sendAPIError(w, err)
+		return nil, false
+	}
+	return o, true
+}
+
+func makeAPIObject(o *backend.Object) *apiObject {
+	out := &apiObject{
+		ID:              o.ID,
+		Path:            o.Path,
+		Title:           o.Title(),
+		Type:            "video",
+		Status:          "public",
+		Format:          o.Format,
+		FileSize:        o.FileSize,
+		VideoCodec:      o.VideoCodec,
+		AudioCodec:      o.AudioCodec,
+		Width:           o.Width,
+		Height:          o.Height,
+		Channels:        o.Channels,
+		Bitrate:         o.Bitrate,
+		Frequency:       o.Frequency,
+		Duration:        o.Duration,
+		Client:          o.Client,
+		Date:            o.Date,
+		Online:          o.Online,
+		ReindexFailures: o.ReindexFailures,
+		ReindexError:    o.ReindexError,
+	}
+	if o.Typ == backend.ObjectFolder {
+		out.Type = "folder"
+	}
+	if o.Bookmark.Valid {
+		out.Bookmark = &o.Bookmark.Int64
+	}
+	if o.BookmarkAt.Valid {
+		out.BookmarkAt = &o.BookmarkAt.Time
+	}
+	if o.AddedAt.Valid {
+		out.AddedAt = &o.AddedAt.Time
+	}
+	if o.ReindexAt.Valid {
+		out.ReindexAt = &o.ReindexAt.Time
+		out.Status = "dirty"
+		if o.Broken {
+			out.Status = "broken"
+		}
+	}
+	return out
+}
+
+func makeAPIObjectList(res *backend.ObjectSearchResponse) *apiObjectList {
+	out := &apiObjectList{Items: make([]*apiObject, 0, len(res.Items)), TotalMatches: res.TotalMatches}
+	for _, o := range res.Items {
+		out.Items = append(out.Items, makeAPIObject(o))
+	}
+	return out
+}
+
+// apiObjectStatus converts status query parameter to backend.ObjectStatus, default is all objects
+func apiObjectStatus(status string) (backend.ObjectStatus, error) {
+	switch status {
+	case "", "all":
+		return backend.StatusAll, nil
+	case "public":
+		return backend.StatusPublic, nil
+	case "dirty":
+		return backend.StatusDirty, nil
+	case "broken":
+		return backend.StatusBroken, nil
+	}
+	return 0, errAPIBadRequest("unknown status '" + status + "'")
+}
+
+// apiPaging returns limit (1..apiMaxLimit) and offset query parameters
+func apiPaging(r *http.Request) (limit int, offset int, err error) {
+	limit = apiDefaultLimit
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, errAPIBadRequest("invalid limit")
+		}
+		limit = min(limit, apiMaxLimit)
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, errAPIBadRequest("invalid offset")
+		}
+	}
+	return limit, offset, nil
+}
+
+// apiTime parses time query parameter: RFC 3339 time or date (local time), empty value is zero time
+func apiTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errAPIBadRequest("invalid time '" + v + "'")
+}
+
+// errAPIBadRequest is an error caused by invalid request parameters
+type errAPIBadRequest string
+
+func (e errAPIBadRequest) Error() string {
+	return string(e)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	sendJSON(w, http.StatusMethodNotAllowed, &apiError{Error: "method not allowed"})
+	return false
+}
+
+func sendAPIError(w http.ResponseWriter, err error) {
+	var badRequest errAPIBadRequest
+	var numError *strconv.NumError
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, backend.ErrNoRows):
+		status = http.StatusNotFound
+	case errors.Is(err, backend.ErrNotVideo), errors.Is(err, backend.ErrNotFolder),
+		errors.As(err, &badRequest), errors.As(err, &numError):
+		status = http.StatusBadRequest
+	case errors.Is(err, backend.ErrRescanRunning):
+		status = http.StatusConflict
+	}
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		// details (paths, sql) are logged, not exposed to the client
+		slog.Error("api request failed", "err", err)
+		msg = http.StatusText(status)
+	}
+	sendJSON(w, status, &apiError{Error: msg})
+}
+
+func sendJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,142 @@
+package dlna
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+func TestAPIPaging(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		ok     bool
+	}{
+		{"", apiDefaultLimit, 0, true},
+		{"limit=10&offset=20", 10, 20, true},
+		{"limit=1", 1, 0, true},
+		{"limit=100000", apiMaxLimit, 0, true},
+		{"limit=0", 0, 0, false},
+		{"limit=-1", 0, 0, false},
+		{"limit=x", 0, 0, false},
+		{"offset=-5", 0, 0, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/objects?"+tt.query, nil)
+		limit, offset, err := apiPaging(r)
+		if (err == nil) != tt.ok || limit != tt.limit || offset != tt.offset {
+			t.Errorf("apiPaging(%q) = %d, %d, %v; want %d, %d, ok=%v", tt.query, limit, offset, err, tt.limit, tt.offset, tt.ok)
+		}
+	}
+}
+
+func TestGuardAPI(t *testing.T) {
+	srv := &Server{
+		APIToken: "secret",
+		APIAllow: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("127.0.0.1/32")},
+	}
+	handler := srv.guardAPI(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		remote string
+		header map[string]string
+		status int
+	}{
+		{"get without token", "GET", "192.168.1.10:1000", nil, http.StatusNoContent},
+		{"get from other network", "GET", "10.0.0.1:1000", nil, http.StatusForbidden},
+		{"post without token", "POST", "192.168.1.10:1000", nil, http.StatusUnauthorized},
+		{"post with wrong token", "POST", "127.0.0.1:1000", map[string]string{"Authorization": "Bearer other"}, http.StatusUnauthorized},
+		{"post with token", "POST", "127.0.0.1:1000", map[string]string{"Authorization": "Bearer secret"}, http.StatusNoContent},
+		{"cross-origin post", "POST", "127.0.0.1:1000", map[string]string{"Authorization": "Bearer secret", "Origin": "http://evil.example"}, http.StatusForbidden},
+		{"cross-site fetch", "DELETE", "127.0.0.1:1000", map[string]string{"Authorization": "Bearer secret", "Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same-origin post", "PUT", "127.0.0.1:1000", map[string]string{"Authorization": "Bearer secret", "Origin": "http://example.com"}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "http://example.com/api/objects/1/reindex", nil)
+		r.RemoteAddr = tt.remote
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d; want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestSendAPIErrorHidesInternalErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	sendAPIError(w, errors.New("pq: relation \"objects\" does not exist"))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d; want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "objects") {
+		t.Errorf("internal error is exposed: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	sendAPIError(w, errAPIBadRequest("invalid position"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid position") {
+		t.Errorf("bad request: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIHistoryAndRestoreBookmark(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"movie.mkv": 600000})
+	id := strconv.Itoa(ts.object(t, "movie.mkv").ID)
+
+	// watched till 120s, then bookmark is accidentally reset
+	for _, position := range []int64{60000, 120000, 0} {
+		if err := ts.back.SetBookmark(ts.object(t, "movie.mkv").ID, position, "bedroom", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := ts.do("GET", "/api/history?client=bedroom&id="+id, "", "", nil)
+	var history []backend.HistoryRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("history: %d %s", w.Code, w.Body.String())
+	}
+	if len(history) != 3 || history[0].Position != 0 || history[2].Position != 60000 {
+		t.Errorf("history is not the newest first: %+v", history)
+	}
+
+	w = ts.do("GET", "/api/history?client=other", "", "", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("history of other client: %d %s", w.Code, w.Body.String())
+	}
+	w = ts.do("GET", "/api/history?since="+time.Now().Add(time.Hour).Format(time.RFC3339), "", "", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("history since future: %d %s", w.Code, w.Body.String())
+	}
+	if w = ts.do("GET", "/api/history?since=yesterday", "", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: %d", w.Code)
+	}
+
+	w = ts.do("POST", "/api/objects/"+id+"/bookmark/restore?client=bedroom", "", "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"position": 120000`) {
+		t.Fatalf("restore: %d %s", w.Code, w.Body.String())
+	}
+	o, err := ts.back.Object(ts.object(t, "movie.mkv").ID, "bedroom")
+	if err != nil || o.Bookmark.Int64 != 120000 {
+		t.Errorf("restored bookmark %v, %v; want 120000", o.Bookmark, err)
+	}
+
+	if w = ts.do("POST", "/api/objects/"+id+"/bookmark/restore?client=other", "", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("restore without history: %d", w.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+)
+
+var (
+	// ErrNotVideo is returned by operations available for video objects only
+	ErrNotVideo = errors.New("object is not a video")
+
+	// ErrNotFolder is returned by operations available for folders only
+	ErrNotFolder = errors.New("object is not a folder")
+)
+
+// ReindexQueueStatus is a state of reindexing
+type ReindexQueueStatus struct {
+	// Workers is amount of objects reindexed in parallel
+	Workers int `json:"workers"`
+
+	// Queued is amount of objects waiting in the queue of workers
+	Queued int `json:"queued"`
+
+	// Running ids of objects being reindexed right now
+	Running []int `json:"running"`
+
+	// Dirty is amount of objects hidden from end user until reindexing (queued, postponed and not scanned yet)
+	Dirty int `json:"dirty"`
+
+	// Broken is amount of objects quarantined after too many failed reindexing
+	Broken int `json:"broken"`
+}
+
+// Objects returns objects from the database without any virtual containers, used by admin API
+func (b *Backend) Objects(filter ObjectSearchFilter) (*ObjectSearchResponse, error) {
+	return b.d.GetObjects(filter)
+}
+
+// AnyObject returns object by ID regardless of its status (public, dirty or broken)
+func (b *Backend) AnyObject(id int, client string) (*Object, error) {
+	if id <= 0 || b.virtualContainer(id) != nil {
+		return b.Object(id, client)
+	}
+	return b.getOneObject(ObjectSearchFilter{ID: id, Status: StatusAll, Sort: SortNone, Client: client})
+}
+
+// ClearBookmark removes bookmark of the video, own bookmark of the client when client is not empty,
+// otherwise shared bookmark, thumbnail is regenerated without progress
+func (b *Backend) ClearBookmark(id int, client string) error {
+	o, err := b.AnyObject(id, client)
+	if err != nil {
+		return err
+	}
+	if o.Typ != ObjectVideo {
+		return ErrNotVideo
+	}
+
+	// bookmark moves object out of "Continue Watching" container
+	defer b.notifyVirtualChange(ContinueWatchingID)
+
+	if client != "" {
+		if err = b.d.SetClientBookmark(o.ID, client, sql.NullInt64{}); err != nil {
+			return err
+		}
+		// shared thumbnail is used when own thumbnail of the client does not exist
+		if err = os.Remove(clientThumbnailFile(o.Path, client)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	bmi := &BookmarkInfo{}
+	if err = b.d.UpdateObject(o, nil, bmi); err != nil {
+		return err
+	}
+	if err = SetBookmarkInfo(o.Path, bmi); err != nil {
+		return err
+	}
+	return b.makeThumbnail(o.Path, thumbnailFile(o.Path), o.Duration, bmi.Bookmark)
+}
+
+// ForceReindex reindexes video right now regardless of its status, broken video is taken out of quarantine
+// when reindexing succeeds
+func (b *Backend) ForceReindex(id int) error {
+	o, err := b.AnyObject(id, "")
+	if err != nil {
+		return err
+	}
+	if o.Typ != ObjectVideo {
+		return ErrNotVideo
+	}
+
+	hidden := o.ReindexAt.Valid
+	if err = b.Reindex(o); err != nil {
+		return err
+	}
+
+	b.notifyParentChange(o.Path)
+	if hidden {
+		// object becomes visible for end user
+		b.notifyVirtualChange(RecentlyAddedID)
+	}
+	return nil
+}
+
+// RegenerateThumbnail makes thumbnail of the video again, own thumbnail of the client when client is not empty
+func (b *Backend) RegenerateThumbnail(id int, client string) error {
+	o, err := b.AnyObject(id, client)
+	if err != nil {
+		return err
+	}
+	if o.Typ != ObjectVideo {
+		return ErrNotVideo
+	}
+
+	if client != "" {
+		return b.makeThumbnail(o.Path, clientThumbnailFile(o.Path, client), o.Duration, o.Bookmark)
+	}
+	return b.makeThumbnail(o.Path, thumbnailFile(o.Path), o.Duration, o.Bookmark)
+}
+
+// ReindexQueue returns state of reindexing
+func (b *Backend) ReindexQueue() (*ReindexQueueStatus, error) {
+	status := &ReindexQueueStatus{Workers: b.reindexWorkers, Running: make([]int, 0)}
+	if b.queue != nil {
+		status.Queued, status.Running = b.queue.stat()
+	}
+
+	res, err := b.d.GetObjects(ObjectSearchFilter{Status: StatusDirty, Sort: SortNone, Limit: 1, WithTotalMatches: true})
+	if err != nil {
+		return nil, err
+	}
+	status.Dirty = res.TotalMatches
+
+	if res, err = b.d.GetObjects(ObjectSearchFilter{Status: StatusBroken, Sort: SortNone, Limit: 1, WithTotalMatches: true}); err != nil {
+		return nil, err
+	}
+	status.Broken = res.TotalMatches
+
+	return status, nil
+}
@@ -3,35 +3,54 @@ package backend
 import (
 	"database/sql"
 	"errors"
+	"io"
 	"log/slog"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/szonov/godlna/pkg/ffmpeg"
 	"github.com/szonov/godlna/pkg/fswatcher"
 )
 
-var videoExtensions = []string{
+// DefaultVideoExtensions are extensions of files indexed as videos
+var DefaultVideoExtensions = []string{
 	".mpg", ".mpeg", ".avi", ".mkv", ".mp4", ".m4v",
 	".divx", ".asf", ".wmv", ".mts", ".m2ts", ".m2t",
 	".vob", ".ts", ".flv", ".xvid", ".mov", ".3gp", ".rm", ".rmvb", ".webm",
 }
 
-func ignoreFn(name string, isDir bool) bool {
-	if isDir {
-		return strings.HasSuffix(name, "/@eaDir") || strings.Contains(name, "/@eaDir/")
-	}
-	if strings.Contains(name, "/@eaDir/") {
-		return true
+var videoExtensions = DefaultVideoExtensions
+
+// SetVideoExtensions sets extensions of files indexed as videos (with leading dot, case-insensitive),
+// should be called before NewBackend
+func SetVideoExtensions(list []string) {
+	videoExtensions = make([]string, 0, len(list))
+	for _, ext := range list {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		videoExtensions = append(videoExtensions, strings.ToLower(ext))
 	}
-	fileExt := strings.ToLower(filepath.Ext(name))
-	for _, ext := range videoExtensions {
-		if fileExt == ext {
-			return false
+}
+
+// ignoreFn reports whether file or directory should be excluded from index: service directories,
+// files which are not videos and paths matched by user's ignore patterns
+func (b *Backend) ignoreFn(name string, isDir bool) bool {
+	if isDir {
+		if strings.HasSuffix(name, "/@eaDir") || strings.Contains(name, "/@eaDir/") {
+			return true
 		}
+	} else if strings.Contains(name, "/@eaDir/") {
+		return true
+	} else if !isVideoFile(name) && !isSubtitleFile(name) {
+		// subtitles are not indexed, but their changes trigger reindex of video
+		return true
 	}
-	return true
+	return b.ignore.Load().match(name)
 }
 
 var (
@@ -48,7 +67,8 @@ const (
 type ObjectSort int
 
 const (
-	// SortPublic (default) sorting objects in way, how it should be visible for end user
+	// SortPublic (default) sorting objects in way, how it should be visible for end user:
+	// folders first, then natural order of titles (look at titleSortKey), path is used for stable paging
 	SortPublic ObjectSort = iota
 
 	// SortById sorting objects by id, actual when getting dirty objects in chunks
@@ -56,21 +76,48 @@ const (
 
 	// SortNone skip sorting, actual when getting one record by id
 	SortNone
+
+	// SortBookmarkTime sorting objects by time of the last bookmark change, the newest first
+	SortBookmarkTime
+
+	// SortAddedTime sorting objects by time when object was found first time, the newest first
+	SortAddedTime
+)
+
+// SortField is a property of object available for sorting with SortKey
+type SortField int
+
+const (
+	SortByTitle SortField = iota
+	SortByDate
+	SortBySize
+	SortByDuration
 )
 
+// SortKey is one key of requested sorting (SortCriteria of Browse and Search actions)
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
 type ObjectStatus int
 
 const (
 	// StatusPublic (default)  objects visible for end user: `WHERE reindex_at IS NULL`
 	StatusPublic ObjectStatus = iota
 
-	// StatusDirty objects not visible for end user: `WHERE reindex_at IS NOT NULL`
+	// StatusDirty objects not visible for end user and waiting for reindexing:
+	// `WHERE reindex_at IS NOT NULL AND NOT broken`
 	StatusDirty
 
 	// StatusReindex objects not visible for end user and ready for reindexing:
-	// `WHERE reindex_at IS NOT NULL AND reindex_at <= now()`
+	// `WHERE reindex_at IS NOT NULL AND reindex_at <= now() AND NOT broken`
 	StatusReindex
 
+	// StatusBroken objects not visible for end user and quarantined after too many failed reindexing:
+	// `WHERE reindex_at IS NOT NULL AND broken`
+	StatusBroken
+
 	// StatusAll all objects without restrictions for `reindex_at` field
 	StatusAll
 )
@@ -90,13 +137,30 @@ type Object struct {
 	Frequency  int
 	Duration   int64
 	Bookmark   sql.NullInt64
+	BookmarkAt sql.NullTime
 	Date       int64
 	Online     bool
 	ReindexAt  sql.NullTime
+	AddedAt    sql.NullTime
+
+	// ReindexFailures amount of failed reindexing in a row, ReindexError is the last error,
+	// Broken object is quarantined, reindexer does not touch it until Requeue or until the file is replaced
+	ReindexFailures int
+	ReindexError    string
+	Broken          bool
+
+	// Client is not empty when Bookmark loaded from per client bookmarks (ObjectSearchFilter.Client)
+	Client string `json:"-"`
+
+	// streams are loaded by LoadStreams, nil when not loaded (pointer keeps Object comparable)
+	streams *[]Stream
 }
 
 func (o *Object) ThumbPath() string {
 	if o.Typ == ObjectVideo {
+		if o.Client != "" && isFileExists(clientThumbnailFile(o.Path, o.Client)) {
+			return clientThumbnailFile(o.Path, o.Client)
+		}
 		return thumbnailFile(o.Path)
 	}
 	return ""
@@ -124,6 +188,9 @@ type ObjectSearchFilter struct {
 	// ParentPath should add `WHERE path LIKE '{value}/%' AND path NOT LIKE {value}/%/%`
 	ParentPath string
 
+	// PathPrefix should add `WHERE starts_with(path, {value})`, used for recursive search inside of folder
+	PathPrefix string
+
 	// OwnPaths should add `WHERE path IN ('{value1}', '{value2}', ..., '{valueN}')`
 	OwnPaths []string
 
@@ -138,6 +205,30 @@ type ObjectSearchFilter struct {
 
 	// Sort define sort mode, default is SortPublic
 	Sort ObjectSort
+
+	// SortKeys used with SortPublic only, should add `ORDER BY typ, {key1}, ..., {keyN}, sort_key, path`,
+	// folders always go first
+	SortKeys []SortKey
+
+	// InProgress should add `WHERE typ = ObjectVideo AND bookmark > 0 AND bookmark < duration * 0.95`,
+	// partially watched videos, not near the end
+	InProgress bool
+
+	// RecentlyAdded should add `WHERE typ = ObjectVideo AND added_at IS NOT NULL`
+	RecentlyAdded bool
+
+	// AddedAfter if not zero should add `WHERE added_at >= {value}`
+	AddedAfter time.Time
+
+	// Search if not nil should add conditions tree to WHERE clause
+	Search *SearchCondition
+
+	// SearchPathBases prefixes of path, the first matched one is removed from path compared by SearchPath condition
+	SearchPathBases []string
+
+	// Client if not empty, the Bookmark of the objects is loaded from bookmarks of this client,
+	// instead of shared bookmark
+	Client string
 }
 
 type ObjectSearchResponse struct {
@@ -146,15 +237,40 @@ type ObjectSearchResponse struct {
 }
 
 type Backend struct {
-	roots     []string
-	d         DatabaseDriver
-	w         *fswatcher.Watcher
-	done      chan struct{}
-	dirtyFlag uint32
+	roots         []string
+	d             DatabaseDriver
+	w             *fswatcher.Watcher
+	done          chan struct{}
+	dirtyFlag     uint32
+	walking       uint32
+	rescanning    uint32
+	recentlyAdded RecentlyAddedWindow
+	onChange      atomic.Pointer[ChangeHandler]
+	ignore        atomic.Pointer[ignorePatterns]
+	thumbnailOpts atomic.Pointer[[]ffmpeg.ThumbnailOption]
+
+	reindexDelay    time.Duration
+	reindexInterval time.Duration
+
+	reindexWorkers int
+	queue          *reindexQueue
+	wg             sync.WaitGroup
 }
 
 func NewBackend(roots []string, d DatabaseDriver) (*Backend, error) {
-	b := &Backend{d: d}
+	b := &Backend{
+		d:               d,
+		done:            make(chan struct{}),
+		queue:           newReindexQueue(),
+		recentlyAdded:   DefaultRecentlyAddedWindow,
+		reindexWorkers:  DefaultReindexWorkers,
+		reindexDelay:    DefaultReindexDelay,
+		reindexInterval: DefaultReindexInterval,
+	}
+	b.ignore.Store(&ignorePatterns{})
+	if err := b.SetThumbnailStyle(DefaultThumbnailStyle); err != nil {
+		return nil, err
+	}
 
 	watcher, err := fswatcher.New(roots...)
 	if err != nil {
@@ -163,7 +279,7 @@ func NewBackend(roots []string, d DatabaseDriver) (*Backend, error) {
 
 	watcher.WithErrorHandler(b.onError)
 	watcher.WithEventHandler(b.onWatcherEvent)
-	watcher.WithIgnoreFn(ignoreFn)
+	watcher.WithIgnoreFn(b.ignoreFn)
 
 	b.w = watcher
 
@@ -175,13 +291,39 @@ func NewBackend(roots []string, d DatabaseDriver) (*Backend, error) {
 }
 
 func (b *Backend) Start() error {
-	b.done = make(chan struct{})
+	if b.isStopped() {
+		return errors.New("(Backend.Start) backend is stopped")
+	}
+	b.startReindexWorkers()
 	return b.w.Start()
 }
 
+// Stop stops backend, it is safe to call it before Start (signal received during start) and more than once
 func (b *Backend) Stop() error {
+	if b.isStopped() {
+		return nil
+	}
 	close(b.done)
-	return b.w.Stop()
+	b.queue.close()
+	err := b.w.Stop()
+	// wait for objects being reindexed right now
+	b.wg.Wait()
+	// embedded storage should flush changes to disk
+	if c, ok := b.d.(io.Closer); ok {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (b *Backend) isStopped() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
 }
 
 func (b *Backend) onError(err error) {
@@ -192,23 +334,54 @@ func (b *Backend) onError(err error) {
 
 func (b *Backend) onWatcherEvent(e fswatcher.Event) {
 	slog.Debug("EVENT", "e", e.String())
+	watcherEvents.Inc(e.Op.String())
 	var err error
+
+	if !e.IsDir && (isSubtitleFile(e.Name) || isSubtitleFile(e.RenamedFrom)) {
+		// during full scan all videos are reindexed anyway
+		if !b.isWalking() {
+			if e.Op == fswatcher.Rename && e.RenamedFrom != "" {
+				err = b.onSubtitleEvent(e.RenamedFrom)
+			}
+			if err == nil {
+				err = b.onSubtitleEvent(e.Name)
+			}
+		}
+		b.onError(err)
+		return
+	}
+
 	switch e.Op {
 	case fswatcher.WalkStart:
+		atomic.StoreUint32(&b.walking, 1)
 		err = b.d.AllObjectsToOffline()
 	case fswatcher.WalkComplete:
+		atomic.StoreUint32(&b.walking, 0)
 		err = b.d.DeleteOfflineObjects()
 		if err == nil {
 			go b.startReindexer()
 		}
+		// full scan may change anything, publish change of the root only instead of every found object
+		b.notifyChange(0)
 	case fswatcher.Index:
 		err = b.d.Index(e.IsDir, e.Name)
 		// setup dirtyFlag when something new
 		atomic.StoreUint32(&b.dirtyFlag, 1)
+		if err == nil && !b.isWalking() {
+			b.notifyParentChange(e.Name)
+		}
 	case fswatcher.Remove:
+		// notify before removing, parent can be removed together with object
+		if !e.IsDir {
+			b.notifyVirtualChange(ContinueWatchingID, RecentlyAddedID)
+		}
+		b.notifyParentChange(e.Name)
 		err = b.d.Remove(e.IsDir, e.Name)
 	case fswatcher.Rename:
 		err = b.d.Rename(e.IsDir, e.RenamedFrom, e.Name)
+		if err == nil {
+			b.notifyParentChange(e.RenamedFrom, e.Name)
+		}
 	}
 	b.onError(err)
 }
@@ -225,7 +398,12 @@ func (b *Backend) getOneObject(filter ObjectSearchFilter) (*Object, error) {
 	return nil, ErrNoRows
 }
 
-func (b *Backend) Object(id int) (*Object, error) {
+// Object returns object by ID, with bookmark of the client (shared bookmark when client is empty)
+func (b *Backend) Object(id int, client string) (*Object, error) {
+	if vc := b.virtualContainer(id); vc != nil {
+		return vc.object(), nil
+	}
+
 	if id <= 0 { // root object
 		return &Object{
 			ID:     0,
@@ -235,15 +413,23 @@ func (b *Backend) Object(id int) (*Object, error) {
 		}, nil
 	}
 
-	return b.getOneObject(ObjectSearchFilter{ID: id, Sort: SortNone})
+	return b.getOneObject(ObjectSearchFilter{ID: id, Sort: SortNone, Client: client})
 }
 
-func (b *Backend) Children(o *Object, limit int, offset int) (*ObjectSearchResponse, error) {
+// Children returns children of the object, with bookmarks of the client (shared bookmarks when client is empty),
+// sortKeys are ignored by virtual containers, they have own order
+func (b *Backend) Children(o *Object, client string, sortKeys []SortKey, limit int, offset int) (*ObjectSearchResponse, error) {
+	if vc := b.virtualContainer(o.ID); vc != nil {
+		return b.virtualChildren(vc, client, limit, offset)
+	}
+
 	filter := ObjectSearchFilter{
 		ParentPath:       o.Path,
 		Limit:            limit,
 		Offset:           offset,
 		WithTotalMatches: true,
+		Client:           client,
+		SortKeys:         sortKeys,
 	}
 	if o.ID <= 0 { // root children
 		switch len(b.roots) {
@@ -257,12 +443,20 @@ func (b *Backend) Children(o *Object, limit int, offset int) (*ObjectSearchRespo
 			filter.ParentPath = ""
 			filter.OwnPaths = b.roots
 		}
+		return b.rootChildren(filter)
 	}
 
+	// client is browsing folder, videos inside of it should be reindexed first
+	b.prioritizeFolder(o.Path)
+
 	return b.d.GetObjects(filter)
 }
 
 func (b *Backend) ParentId(o *Object) (int, error) {
+	if b.virtualContainer(o.ID) != nil {
+		return 0, nil
+	}
+
 	if o.ID <= 0 {
 		return -1, nil
 	}
@@ -292,8 +486,52 @@ func (b *Backend) ParentId(o *Object) (int, error) {
 	return parent.ID, nil
 }
 
-// SetBookmark for given ID sets bookmark time in milliseconds
-func (b *Backend) SetBookmark(id int, bookmark int64) error {
+// ParentIds returns parent IDs of the objects by their IDs, as ParentId does, parent folders are loaded by one query
+func (b *Backend) ParentIds(list []*Object) (map[int]int, error) {
+	out := make(map[int]int, len(list))
+	dirs := make([]string, 0, len(list))
+	for _, o := range list {
+		switch {
+		case b.virtualContainer(o.ID) != nil:
+			out[o.ID] = 0
+		case o.ID <= 0:
+			out[o.ID] = -1
+		case len(b.roots) > 1 && slices.Contains(b.roots, o.Path):
+			out[o.ID] = 0
+		case len(b.roots) == 1 && filepath.Dir(o.Path) == b.roots[0]:
+			out[o.ID] = 0
+		case !slices.Contains(dirs, filepath.Dir(o.Path)):
+			dirs = append(dirs, filepath.Dir(o.Path))
+		}
+	}
+	if len(dirs) == 0 {
+		return out, nil
+	}
+
+	res, err := b.d.GetObjects(ObjectSearchFilter{OwnPaths: dirs, Sort: SortNone})
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[string]int, len(res.Items))
+	for _, parent := range res.Items {
+		parents[parent.Path] = parent.ID
+	}
+	for _, o := range list {
+		if _, ok := out[o.ID]; ok {
+			continue
+		}
+		id, ok := parents[filepath.Dir(o.Path)]
+		if !ok {
+			return nil, ErrNoRows
+		}
+		out[o.ID] = id
+	}
+	return out, nil
+}
+
+// SetBookmark for given ID sets bookmark time in milliseconds, every call is recorded to the watch history.
+// client is name of the client (TV), when ownBookmark is false bookmark is shared between all clients
+func (b *Backend) SetBookmark(id int, bookmark int64, client string, ownBookmark bool) error {
 	if bookmark < 0 {
 		return nil
 	}
@@ -301,10 +539,37 @@ func (b *Backend) SetBookmark(id int, bookmark int64) error {
 		return ErrNoRows
 	}
 
-	o, err := b.getOneObject(ObjectSearchFilter{ID: id, Sort: SortNone})
+	filter := ObjectSearchFilter{ID: id, Sort: SortNone}
+	if ownBookmark {
+		filter.Client = client
+	}
+
+	o, err := b.getOneObject(filter)
 	if err != nil {
+		if errors.Is(err, ErrNoRows) {
+			// object is hidden while waiting for reindex, client needs it right now
+			b.prioritizeObject(id)
+		}
+		return err
+	}
+
+	if err := b.d.AddHistory(&HistoryRecord{
+		ObjectID: o.ID,
+		Path:     o.Path,
+		Client:   client,
+		Position: bookmark,
+		Duration: o.Duration,
+	}); err != nil {
 		return err
 	}
+
+	// bookmark moves object in (or out) of "Continue Watching" container
+	defer b.notifyVirtualChange(ContinueWatchingID)
+
+	if ownBookmark {
+		return b.setClientBookmark(o, bookmark)
+	}
+
 	bmi := &BookmarkInfo{
 		Bookmark: sql.NullInt64{Int64: bookmark, Valid: true},
 	}
@@ -319,7 +584,7 @@ func (b *Backend) SetBookmark(id int, bookmark int64) error {
 	}
 
 	// Create thumbnail
-	if err := makeThumbnail(o.Path, o.Duration, bmi.Bookmark); err != nil {
+	if err := b.makeThumbnail(o.Path, thumbnailFile(o.Path), o.Duration, bmi.Bookmark); err != nil {
 		return err
 	}
 
@@ -331,6 +596,21 @@ func (b *Backend) SetBookmark(id int, bookmark int64) error {
 	return nil
 }
 
+func (b *Backend) setClientBookmark(o *Object, bookmark int64) error {
+	bm := sql.NullInt64{Int64: bookmark, Valid: true}
+	if o.Bookmark == bm {
+		// nothing changed
+		return nil
+	}
+
+	if err := b.d.SetClientBookmark(o.ID, o.Client, bm); err != nil {
+		return err
+	}
+
+	// Create own thumbnail of the client
+	return b.makeThumbnail(o.Path, clientThumbnailFile(o.Path, o.Client), o.Duration, bm)
+}
+
 func (b *Backend) Reindex(o *Object) error {
 	if o == nil || o.ID <= 0 || o.Typ != ObjectVideo {
 		return nil
@@ -350,8 +630,13 @@ func (b *Backend) Reindex(o *Object) error {
 		return err
 	}
 
+	streams := append(slices.Clone(videoInfo.Streams), findSubtitles(o.Path)...)
+	if err := b.d.SetStreams(o.ID, streams); err != nil {
+		return err
+	}
+
 	if !isThumbnailExists(o.Path) {
-		return makeThumbnail(o.Path, o.Duration, bmi.Bookmark)
+		return b.makeThumbnail(o.Path, thumbnailFile(o.Path), o.Duration, bmi.Bookmark)
 	}
 
 	return nil
@@ -361,18 +646,25 @@ func (b *Backend) startReindexer() {
 	select {
 	case <-b.done:
 		return
-	case <-time.After(11 * time.Second):
+	case <-time.After(b.reindexDelay):
 		b.reindexDirty()
 	}
 
 	slog.Info("video folders re-indexed", "dirs", b.roots)
 
-	// circle, every 30 seconds try to run reindexer
+	// circle, periodically try to run reindexer
+	lastPoll := time.Now()
 	for {
 		select {
 		case <-b.done:
 			return
-		case <-time.After(30 * time.Second):
+		case <-time.After(b.reindexInterval):
+			// objects can be made dirty by another process (`godlna db requeue`),
+			// it does not touch dirtyFlag, so look into database from time to time anyway
+			if time.Since(lastPoll) >= ReindexPollInterval {
+				atomic.StoreUint32(&b.dirtyFlag, 1)
+				lastPoll = time.Now()
+			}
 			b.reindexDirty()
 		}
 	}
@@ -416,11 +708,8 @@ func (b *Backend) reindexDirty() {
 			}
 			filter.LastVisitedId = o.ID
 
-			if err := b.Reindex(o); err != nil {
-				slog.Error("ReindexDirty :: Reindex", "err", err, "o", o)
-			} else {
-				slog.Debug("ReindexDirty", "id", o.ID, "path", o.Path)
-			}
+			// reindexed by workers, objects already queued with higher priority keep their place
+			b.queue.push(o.ID, reindexBacklog)
 		}
 	}
 	slog.Debug(">>>>>>> ReindexDirty :: stop")
@@ -436,3 +725,13 @@ func (b *Backend) reindexDirty() {
 		}
 	}
 }
+
+// Property returns value of named application property, ErrNoRows if property is not set
+func (b *Backend) Property(name string) (string, error) {
+	return b.d.GetProperty(name)
+}
+
+// SetProperty stores value of named application property, it survives restart
+func (b *Backend) SetProperty(name string, value string) error {
+	return b.d.SetProperty(name, value)
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStopBeforeStart(t *testing.T) {
+	b, err := NewBackend([]string{t.TempDir()}, NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signal can be received while backend is starting
+	if err = b.Stop(); err != nil {
+		t.Errorf("Stop() before Start() = %v", err)
+	}
+	if err = b.Stop(); err != nil {
+		t.Errorf("second Stop() = %v", err)
+	}
+	if err = b.Start(); err == nil {
+		t.Errorf("Start() after Stop() is accepted")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	b, err := NewBackend([]string{t.TempDir()}, NewMemoryDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+	if err = b.Stop(); err != nil {
+		t.Errorf("second Stop() = %v", err)
+	}
+}
+
+// objectsCountingDriver counts queries of objects
+type objectsCountingDriver struct {
+	DatabaseDriver
+	queries int
+}
+
+func (d *objectsCountingDriver) GetObjects(filter ObjectSearchFilter) (*ObjectSearchResponse, error) {
+	d.queries++
+	return d.DatabaseDriver.GetObjects(filter)
+}
+
+func TestParentIds(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	video, films := filepath.Join(base, "video"), filepath.Join(base, "films")
+	for _, dir := range []string{video, films} {
+		if err = os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, roots := range [][]string{{video}, {video, films}} {
+		mem := NewMemoryDriver()
+		for _, root := range roots {
+			if err = mem.Index(true, root); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range []string{"a.mkv", "sub", "sub/b.mkv", "sub/c.mkv", "sub/deep", "sub/deep/d.mkv"} {
+			if err = mem.Index(filepath.Ext(name) == "", filepath.Join(video, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d := &objectsCountingDriver{DatabaseDriver: mem}
+		b, err := NewBackend(roots, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := mem.GetObjects(ObjectSearchFilter{Status: StatusAll, Sort: SortById})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// hidden root of single root library is never listed
+		list := slices.DeleteFunc(res.Items, func(o *Object) bool { return len(roots) == 1 && o.Path == video })
+		list = append(list, b.virtualContainer(ContinueWatchingID).object())
+
+		d.queries = 0
+		got, err := b.ParentIds(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.queries != 1 {
+			t.Errorf("%d roots: %d queries; want 1", len(roots), d.queries)
+		}
+		for _, o := range list {
+			want, err := b.ParentId(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[o.ID] != want {
+				t.Errorf("%d roots: parent of %s is %d; want %d", len(roots), o.Path, got[o.ID], want)
+			}
+		}
+	}
+}
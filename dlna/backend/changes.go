@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+)
+
+// ChangeHandler receives ids of containers whose list of children (or children's metadata) was changed
+type ChangeHandler func(containerIds []int)
+
+// OnChange sets handler of library changes, handler is called synchronously from fs events handler
+// and reindexer, so it should not block
+func (b *Backend) OnChange(fn ChangeHandler) {
+	b.onChange.Store(&fn)
+}
+
+func (b *Backend) notifyChange(ids ...int) {
+	fn := b.onChange.Load()
+	if fn == nil || len(ids) == 0 {
+		return
+	}
+	(*fn)(ids)
+}
+
+// notifyParentChange publishes changes of containers holding objects with given paths
+func (b *Backend) notifyParentChange(paths ...string) {
+	if b.onChange.Load() == nil {
+		return
+	}
+	ids := make([]int, 0, len(paths))
+	for _, p := range paths {
+		id, err := b.parentContainerId(p)
+		if err != nil {
+			slog.Debug("container not found", "path", p, "err", err)
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	b.notifyChange(ids...)
+}
+
+// notifyVirtualChange publishes changes of visible virtual containers
+func (b *Backend) notifyVirtualChange(ids ...int) {
+	visible := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if b.virtualContainer(id) != nil {
+			visible = append(visible, id)
+		}
+	}
+	b.notifyChange(visible...)
+}
+
+// parentContainerId returns ID of container holding object with given path,
+// it is root for root folders and for children of single root folder
+func (b *Backend) parentContainerId(fullPath string) (int, error) {
+	dir := filepath.Dir(fullPath)
+	if slices.Contains(b.roots, fullPath) || (len(b.roots) == 1 && dir == b.roots[0]) {
+		return 0, nil
+	}
+	o, err := b.getOneObject(ObjectSearchFilter{
+		OwnPaths: []string{dir},
+		Status:   StatusAll,
+		Sort:     SortNone,
+		Limit:    1,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return o.ID, nil
+}
+
+func (b *Backend) isWalking() bool {
+	return atomic.LoadUint32(&b.walking) == 1
+}
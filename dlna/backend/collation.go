@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"bytes"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// collator for natural sort order of titles: numbers compared as numbers ("Episode 2" < "Episode 10"),
+// case-insensitive, Unicode aware; it is not safe for concurrent use, so protected by collatorMu
+var (
+	collatorMu  sync.Mutex
+	collator    = collate.New(language.Und, collate.Numeric, collate.IgnoreCase)
+	collatorBuf collate.Buffer
+)
+
+// titleSortKey returns natural sort key of title, keys are compared byte by byte (bytes.Compare, BYTEA in postgres)
+func titleSortKey(title string) []byte {
+	collatorMu.Lock()
+	defer collatorMu.Unlock()
+
+	collatorBuf.Reset()
+	return bytes.Clone(collator.KeyFromString(&collatorBuf, norm.NFC.String(title)))
+}
+
+// sortKey returns natural sort key of object title
+func (o *Object) sortKey() []byte {
+	return titleSortKey(o.Title())
+}
+
+func objectType(isDir bool) ObjectType {
+	if isDir {
+		return ObjectFolder
+	}
+	return ObjectVideo
+}
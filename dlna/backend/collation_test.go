@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestTitleSortKeyOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"numbers", []string{"Episode 1", "Episode 2", "Episode 9", "Episode 10", "Episode 11", "Episode 100"}},
+		{"leading zeros", []string{"Part 1", "Part 02", "Part 3", "Part 010"}},
+		{"numbers inside words", []string{"S01E02", "S01E10", "S02E01", "S10E01"}},
+		{"case", []string{"alpha", "Beta", "gamma", "Zeta"}},
+		{"accents", []string{"cote", "côte", "coter", "Éclair", "elephant"}},
+		{"cyrillic", []string{"Ёлка", "Жук", "Серия 2", "Серия 10", "Яблоко"}},
+		{"prefix", []string{"Film", "Film 2", "Film Extended"}},
+	}
+	for _, tt := range tests {
+		got := slices.Clone(tt.want)
+		slices.Reverse(got)
+		slices.SortStableFunc(got, func(a, b string) int {
+			return bytes.Compare(titleSortKey(a), titleSortKey(b))
+		})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: sorted %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTitleSortKeyEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Matrix", "MATRIX"},
+		{"matrix", "Matrix"},
+		// precomposed and decomposed forms of é
+		{"Caf\u00e9", "Cafe\u0301"},
+		{"Ёж", "ёж"},
+	}
+	for _, tt := range tests {
+		if a, b := titleSortKey(tt.a), titleSortKey(tt.b); !bytes.Equal(a, b) {
+			t.Errorf("titleSortKey(%q) = %x, titleSortKey(%q) = %x; want equal", tt.a, a, tt.b, b)
+		}
+	}
+}
@@ -1,8 +1,28 @@
 package backend
 
+import (
+	"database/sql"
+	"time"
+)
+
 type DatabaseDriver interface {
 	GetObjects(filter ObjectSearchFilter) (result *ObjectSearchResponse, err error)
 	UpdateObject(item *Object, videoInfo *VideoInfo, bookmarkInfo *BookmarkInfo) (err error)
+	SetClientBookmark(objectID int, client string, bookmark sql.NullInt64) (err error)
+
+	// SetReindexFailure stores result of failed reindexing, failures are reset by UpdateObject with VideoInfo,
+	// RequeueBroken returns broken objects (all when objectID is 0) to reindexing, count is amount of requeued objects
+	SetReindexFailure(objectID int, failure ReindexFailure) (err error)
+	RequeueBroken(objectID int) (count int, err error)
+
+	// SetStreams replaces all streams of the object, GetStreams returns them ordered by index,
+	// GetStreamsByObjects returns streams of several objects at once, objects without streams are missing in result
+	SetStreams(objectID int, streams []Stream) (err error)
+	GetStreams(objectID int) (streams []Stream, err error)
+	GetStreamsByObjects(objectIDs []int) (streams map[int][]Stream, err error)
+
+	AddHistory(record *HistoryRecord) (err error)
+	GetHistory(filter HistoryFilter) (records []*HistoryRecord, err error)
 
 	AllObjectsToOffline() (err error)
 	DeleteOfflineObjects() (err error)
@@ -10,4 +30,28 @@ type DatabaseDriver interface {
 	Index(isDir bool, fullPath string) (err error)
 	Remove(isDir bool, fullPath string) (err error)
 	Rename(isDir bool, oldFullPath string, newFullPath string) (err error)
+
+	// GetProperty returns value of named application property (for example SystemUpdateID),
+	// ErrNoRows if property is not set yet
+	GetProperty(name string) (value string, err error)
+	SetProperty(name string, value string) (err error)
+}
+
+// ReindexFailure is a state of object after failed reindexing
+type ReindexFailure struct {
+	// Failures amount of failed reindexing in a row
+	Failures int
+
+	// Error is the text of the last error
+	Error string
+
+	// RetryAt time of the next attempt (stored to reindex_at)
+	RetryAt time.Time
+
+	// Broken object is quarantined and hidden from reindexer
+	Broken bool
+
+	// FileSize and Date (modification time) of the file which failed, rescan reindexes the file when they change
+	FileSize int64
+	Date     int64
 }
@@ -0,0 +1,729 @@
+package backend
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbeddedDriver keeps all objects in memory and writes every change to the journal file (if any).
+// It is pure go, does not require CGO and any external database server,
+// semantic of all methods is the same as PostgresDriver (and procedures from the migrations)
+type EmbeddedDriver struct {
+	mu        sync.RWMutex
+	objects   map[int]*Object
+	paths     map[string]int
+	sortKeys  map[int][]byte
+	bookmarks map[int]map[string]*ClientBookmark
+	history   []*HistoryRecord
+	histCount map[historyKey]int
+	props     map[string]string
+	streams   map[int][]Stream
+	lastId    int
+	journal   *journal
+}
+
+// historyKey is a pair of object and client, amount of history records is limited per pair
+type historyKey struct {
+	objectID int
+	client   string
+}
+
+// ClientBookmark is a bookmark of one client for one object
+type ClientBookmark struct {
+	ObjectID  int           `json:"id"`
+	Client    string        `json:"client"`
+	Bookmark  sql.NullInt64 `json:"bm"`
+	UpdatedAt time.Time     `json:"at"`
+}
+
+// NewEmbeddedDriver opens (or creates) journal file and loads all objects from it
+func NewEmbeddedDriver(file string) (*EmbeddedDriver, error) {
+	d := newEmbeddedDriver()
+	j, err := openJournal(file, d.replay)
+	if err != nil {
+		return nil, fmt.Errorf("(embedded) failed to open '%s': %w", file, err)
+	}
+	j.size = func() int { return len(d.objects) + len(d.bookmarks) + len(d.history) + len(d.props) + len(d.streams) }
+	j.snapshot = d.snapshot
+	if err = j.compact(d.snapshot()); err != nil {
+		return nil, fmt.Errorf("(embedded) failed to compact '%s': %w", file, err)
+	}
+	d.journal = j
+	return d, nil
+}
+
+// newEmbeddedDriver creates empty driver without journal
+func newEmbeddedDriver() *EmbeddedDriver {
+	return &EmbeddedDriver{
+		objects:   make(map[int]*Object),
+		paths:     make(map[string]int),
+		sortKeys:  make(map[int][]byte),
+		bookmarks: make(map[int]map[string]*ClientBookmark),
+		histCount: make(map[historyKey]int),
+		props:     make(map[string]string),
+		streams:   make(map[int][]Stream),
+	}
+}
+
+// Close flushes and closes the journal file
+func (d *EmbeddedDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.journal.close()
+}
+
+func (d *EmbeddedDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse, error) {
+	out := &ObjectSearchResponse{
+		Items: make([]*Object, 0),
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := time.Now()
+	matched := make([]*Object, 0)
+
+	if f.ID > 0 {
+		if o, ok := d.objects[f.ID]; ok {
+			if o = d.clientView(o, f.Client); f.match(o, now) {
+				matched = append(matched, o)
+			}
+		}
+	} else {
+		for _, o := range d.objects {
+			if o = d.clientView(o, f.Client); f.match(o, now) {
+				matched = append(matched, o)
+			}
+		}
+	}
+
+	if f.WithTotalMatches {
+		out.TotalMatches = len(matched)
+		if out.TotalMatches == 0 {
+			return out, nil
+		}
+	}
+
+	switch f.Sort {
+	case SortPublic:
+		sort.Slice(matched, func(i, j int) bool {
+			if matched[i].Typ != matched[j].Typ {
+				return matched[i].Typ < matched[j].Typ
+			}
+			for _, key := range f.SortKeys {
+				if c := d.compare(key, matched[i], matched[j]); c != 0 {
+					return c < 0
+				}
+			}
+			if c := bytes.Compare(d.sortKeys[matched[i].ID], d.sortKeys[matched[j].ID]); c != 0 {
+				return c < 0
+			}
+			return matched[i].Path < matched[j].Path
+		})
+	case SortById:
+		sort.Slice(matched, func(i, j int) bool {
+			return matched[i].ID < matched[j].ID
+		})
+	case SortBookmarkTime:
+		sort.Slice(matched, func(i, j int) bool {
+			a, b := matched[i].BookmarkAt, matched[j].BookmarkAt
+			if a.Valid != b.Valid {
+				return a.Valid
+			}
+			if !a.Time.Equal(b.Time) {
+				return a.Time.After(b.Time)
+			}
+			return matched[i].ID < matched[j].ID
+		})
+	case SortAddedTime:
+		sort.Slice(matched, func(i, j int) bool {
+			a, b := matched[i].AddedAt, matched[j].AddedAt
+			if a.Valid != b.Valid {
+				return a.Valid
+			}
+			if !a.Time.Equal(b.Time) {
+				return a.Time.After(b.Time)
+			}
+			return matched[i].ID > matched[j].ID
+		})
+	case SortNone:
+		// no sorting
+	}
+
+	if f.Offset > 0 {
+		if f.Offset >= len(matched) {
+			return out, nil
+		}
+		matched = matched[f.Offset:]
+	}
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+
+	for _, o := range matched {
+		item := *o
+		out.Items = append(out.Items, &item)
+	}
+
+	return out, nil
+}
+
+// compare compares objects by sort key, in-memory version of ORDER BY built by PostgresDriver
+func (d *EmbeddedDriver) compare(key SortKey, a, b *Object) int {
+	var c int
+	switch key.Field {
+	case SortByTitle:
+		c = bytes.Compare(d.sortKeys[a.ID], d.sortKeys[b.ID])
+	case SortByDate:
+		c = cmpInt64(a.Date, b.Date)
+	case SortBySize:
+		c = cmpInt64(a.FileSize, b.FileSize)
+	case SortByDuration:
+		c = cmpInt64(a.Duration, b.Duration)
+	}
+	if key.Desc {
+		return -c
+	}
+	return c
+}
+
+// clientView returns copy of object with bookmark of given client, or object itself when client is empty
+func (d *EmbeddedDriver) clientView(o *Object, client string) *Object {
+	if client == "" {
+		return o
+	}
+	item := *o
+	item.Client = client
+	item.Bookmark = sql.NullInt64{}
+	item.BookmarkAt = sql.NullTime{}
+	if cb, ok := d.bookmarks[o.ID][client]; ok {
+		item.Bookmark = cb.Bookmark
+		item.BookmarkAt = sql.NullTime{Time: cb.UpdatedAt, Valid: true}
+	}
+	return &item
+}
+
+func (d *EmbeddedDriver) UpdateObject(o *Object, v *VideoInfo, b *BookmarkInfo) error {
+	if o == nil || o.ID == 0 {
+		return fmt.Errorf("(embedded.UpdateObject) nil object")
+	}
+
+	if v == nil && b == nil {
+		// nothing to update
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stored, ok := d.objects[o.ID]
+	if !ok {
+		// same as UPDATE without matched rows
+		return nil
+	}
+
+	item := *stored
+	for _, target := range []*Object{o, &item} {
+		if v != nil {
+			target.Format = v.Format
+			target.FileSize = v.FileSize
+			target.VideoCodec = v.VideoCodec
+			target.AudioCodec = v.AudioCodec
+			target.Width = v.Width
+			target.Height = v.Height
+			target.Channels = v.Channels
+			target.Bitrate = v.Bitrate
+			target.Frequency = v.Frequency
+			target.Duration = v.Duration
+			target.Date = v.Date
+			target.ReindexAt = sql.NullTime{}
+			target.ReindexFailures = 0
+			target.ReindexError = ""
+			target.Broken = false
+		}
+		if b != nil && target.Bookmark != b.Bookmark {
+			target.Bookmark = b.Bookmark
+			target.BookmarkAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+
+	if item == *stored {
+		// nothing to update
+		return nil
+	}
+
+	return d.put(&item)
+}
+
+func (d *EmbeddedDriver) SetClientBookmark(objectID int, client string, bookmark sql.NullInt64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.objects[objectID]; !ok {
+		return fmt.Errorf("(embedded.SetClientBookmark) object %d not found", objectID)
+	}
+
+	cb := &ClientBookmark{
+		ObjectID:  objectID,
+		Client:    client,
+		Bookmark:  bookmark,
+		UpdatedAt: time.Now(),
+	}
+	d.storeBookmark(cb)
+	return d.journal.write(journalRecord{Op: journalBookmark, Bookmark: cb})
+}
+
+func (d *EmbeddedDriver) SetReindexFailure(objectID int, f ReindexFailure) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stored, ok := d.objects[objectID]
+	if !ok {
+		// same as UPDATE without matched rows
+		return nil
+	}
+
+	item := *stored
+	item.ReindexFailures = f.Failures
+	item.ReindexError = f.Error
+	item.ReindexAt = sql.NullTime{Time: f.RetryAt, Valid: true}
+	item.Broken = f.Broken
+	item.FileSize = f.FileSize
+	item.Date = f.Date
+	return d.put(&item)
+}
+
+func (d *EmbeddedDriver) RequeueBroken(objectID int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ids := make([]int, 0)
+	for id, o := range d.objects {
+		if o.Broken && o.ReindexAt.Valid && (objectID <= 0 || id == objectID) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	now := time.Now()
+	for _, id := range ids {
+		item := *d.objects[id]
+		item.Broken = false
+		item.ReindexFailures = 0
+		item.ReindexError = ""
+		item.ReindexAt = sql.NullTime{Time: now, Valid: true}
+		if err := d.put(&item); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func (d *EmbeddedDriver) AddHistory(h *HistoryRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h.ID = 1
+	if len(d.history) > 0 {
+		h.ID = d.history[len(d.history)-1].ID + 1
+	}
+	h.CreatedAt = time.Now()
+
+	item := *h
+	d.appendHistory(&item)
+	return d.journal.write(journalRecord{Op: journalHistory, History: &item})
+}
+
+// appendHistory adds record and deletes the oldest record of the same object and client above MaxHistoryRecords,
+// deleted records are dropped from the journal file on the next compaction
+func (d *EmbeddedDriver) appendHistory(h *HistoryRecord) {
+	d.history = append(d.history, h)
+	key := historyKey{h.ObjectID, h.Client}
+	if d.histCount[key]++; d.histCount[key] <= MaxHistoryRecords {
+		return
+	}
+	d.histCount[key]--
+	i := slices.IndexFunc(d.history, func(r *HistoryRecord) bool { return r.ObjectID == key.objectID && r.Client == key.client })
+	d.history = slices.Delete(d.history, i, i+1)
+}
+
+func (d *EmbeddedDriver) GetHistory(f HistoryFilter) ([]*HistoryRecord, error) {
+	out := make([]*HistoryRecord, 0)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	// history is ordered by creation, walk from the newest records
+	skip := f.Offset
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+		if !f.match(d.history[i]) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		item := *d.history[i]
+		out = append(out, &item)
+	}
+
+	return out, nil
+}
+
+func (d *EmbeddedDriver) SetStreams(objectID int, streams []Stream) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.objects[objectID]; !ok {
+		return fmt.Errorf("(embedded.SetStreams) object %d not found", objectID)
+	}
+
+	list := slices.Clone(streams)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+	d.streams[objectID] = list
+	return d.journal.write(journalRecord{Op: journalStreams, ObjectID: objectID, Streams: list})
+}
+
+func (d *EmbeddedDriver) GetStreams(objectID int) ([]Stream, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]Stream, len(d.streams[objectID]))
+	copy(list, d.streams[objectID])
+	return list, nil
+}
+
+func (d *EmbeddedDriver) GetStreamsByObjects(objectIDs []int) (map[int][]Stream, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	out := make(map[int][]Stream)
+	for _, id := range objectIDs {
+		if list, ok := d.streams[id]; ok && len(list) > 0 {
+			out[id] = slices.Clone(list)
+		}
+	}
+	return out, nil
+}
+
+func (d *EmbeddedDriver) GetProperty(name string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	value, ok := d.props[name]
+	if !ok {
+		return "", ErrNoRows
+	}
+	return value, nil
+}
+
+func (d *EmbeddedDriver) SetProperty(name string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.props[name]; ok && prev == value {
+		return nil
+	}
+	d.props[name] = value
+	return d.journal.write(journalRecord{Op: journalProperty, Name: name, Value: value})
+}
+
+func (d *EmbeddedDriver) AllObjectsToOffline() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.allToOffline()
+	return d.journal.write(journalRecord{Op: journalOffline})
+}
+
+func (d *EmbeddedDriver) DeleteOfflineObjects() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deleteOffline()
+	return d.journal.write(journalRecord{Op: journalPurge})
+}
+
+func (d *EmbeddedDriver) Index(isDir bool, fullPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	item := &Object{Path: fullPath, AddedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	if id, ok := d.paths[fullPath]; ok {
+		item = new(Object)
+		*item = *d.objects[id]
+	}
+
+	item.Online = true
+	// file found again gets fresh attempts of reindexing
+	item.ReindexFailures = 0
+	item.ReindexError = ""
+	item.Broken = false
+	if isDir {
+		item.Typ = ObjectFolder
+		item.ReindexAt = sql.NullTime{}
+	} else {
+		// give 10 second gap for new objects to start it indexing after find in file system
+		// (look at index_add procedure in migrations/0011_index_add_reset_failures.sql)
+		item.Typ = ObjectVideo
+		item.ReindexAt = sql.NullTime{Time: time.Now().Add(10 * time.Second), Valid: true}
+	}
+
+	return d.put(item)
+}
+
+func (d *EmbeddedDriver) Remove(isDir bool, fullPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.remove(isDir, fullPath)
+	return d.journal.write(journalRecord{Op: journalRemove, IsDir: isDir, Path: fullPath})
+}
+
+func (d *EmbeddedDriver) Rename(isDir bool, oldFullPath string, newFullPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.rename(isDir, oldFullPath, newFullPath); err != nil {
+		return err
+	}
+	return d.journal.write(journalRecord{Op: journalRename, IsDir: isDir, Path: newFullPath, OldPath: oldFullPath})
+}
+
+// put stores object and writes it to journal, new objects (ID = 0) get next ID
+func (d *EmbeddedDriver) put(o *Object) error {
+	if o.ID == 0 {
+		d.lastId++
+		o.ID = d.lastId
+	}
+	d.store(o)
+	return d.journal.write(journalRecord{Op: journalPut, Object: o})
+}
+
+func (d *EmbeddedDriver) store(o *Object) {
+	prev, ok := d.objects[o.ID]
+	if ok && prev.Path != o.Path {
+		delete(d.paths, prev.Path)
+	}
+	if !ok || prev.Path != o.Path || prev.Typ != o.Typ {
+		d.sortKeys[o.ID] = o.sortKey()
+	}
+	if o.ID > d.lastId {
+		d.lastId = o.ID
+	}
+	d.objects[o.ID] = o
+	d.paths[o.Path] = o.ID
+}
+
+func (d *EmbeddedDriver) storeBookmark(cb *ClientBookmark) {
+	if _, ok := d.bookmarks[cb.ObjectID]; !ok {
+		d.bookmarks[cb.ObjectID] = make(map[string]*ClientBookmark)
+	}
+	d.bookmarks[cb.ObjectID][cb.Client] = cb
+}
+
+func (d *EmbeddedDriver) delete(o *Object) {
+	delete(d.objects, o.ID)
+	delete(d.paths, o.Path)
+	delete(d.sortKeys, o.ID)
+	delete(d.bookmarks, o.ID)
+	delete(d.streams, o.ID)
+}
+
+func (d *EmbeddedDriver) allToOffline() {
+	for _, o := range d.objects {
+		o.Online = false
+	}
+}
+
+func (d *EmbeddedDriver) deleteOffline() {
+	for _, o := range d.objects {
+		if !o.Online {
+			d.delete(o)
+		}
+	}
+}
+
+func (d *EmbeddedDriver) remove(isDir bool, fullPath string) {
+	if id, ok := d.paths[fullPath]; ok {
+		d.delete(d.objects[id])
+	}
+	if isDir {
+		prefix := fullPath + "/"
+		for _, o := range d.objects {
+			if strings.HasPrefix(o.Path, prefix) {
+				d.delete(o)
+			}
+		}
+	}
+}
+
+func (d *EmbeddedDriver) rename(isDir bool, oldPath string, newPath string) error {
+	if _, exists := d.paths[newPath]; exists && oldPath != newPath {
+		return fmt.Errorf("(embedded.Rename) duplicate path '%s'", newPath)
+	}
+	if id, ok := d.paths[oldPath]; ok {
+		delete(d.paths, oldPath)
+		d.objects[id].Path = newPath
+		d.paths[newPath] = id
+		d.sortKeys[id] = d.objects[id].sortKey()
+	}
+	if isDir {
+		prefix := oldPath + "/"
+		for _, o := range d.objects {
+			if strings.HasPrefix(o.Path, prefix) {
+				delete(d.paths, o.Path)
+				o.Path = newPath + "/" + o.Path[len(prefix):]
+				d.paths[o.Path] = o.ID
+			}
+		}
+	}
+	return nil
+}
+
+// replay applies one journal record to the in-memory state, used during loading the journal file
+func (d *EmbeddedDriver) replay(rec journalRecord) error {
+	switch rec.Op {
+	case journalPut:
+		if rec.Object == nil || rec.Object.ID <= 0 {
+			return fmt.Errorf("invalid object in '%s' record", rec.Op)
+		}
+		d.store(rec.Object)
+	case journalBookmark:
+		if rec.Bookmark == nil {
+			return fmt.Errorf("invalid bookmark in '%s' record", rec.Op)
+		}
+		if _, ok := d.objects[rec.Bookmark.ObjectID]; ok {
+			d.storeBookmark(rec.Bookmark)
+		}
+	case journalHistory:
+		if rec.History == nil {
+			return fmt.Errorf("invalid history in '%s' record", rec.Op)
+		}
+		d.appendHistory(rec.History)
+	case journalProperty:
+		if rec.Name == "" {
+			return fmt.Errorf("invalid property in '%s' record", rec.Op)
+		}
+		d.props[rec.Name] = rec.Value
+	case journalStreams:
+		if _, ok := d.objects[rec.ObjectID]; ok {
+			d.streams[rec.ObjectID] = rec.Streams
+		}
+	case journalOffline:
+		d.allToOffline()
+	case journalPurge:
+		d.deleteOffline()
+	case journalRemove:
+		d.remove(rec.IsDir, rec.Path)
+	case journalRename:
+		return d.rename(rec.IsDir, rec.OldPath, rec.Path)
+	default:
+		return fmt.Errorf("unknown operation '%s'", rec.Op)
+	}
+	return nil
+}
+
+// snapshot returns records enough to restore current state, used during journal compaction
+func (d *EmbeddedDriver) snapshot() []journalRecord {
+	records := make([]journalRecord, 0, len(d.objects))
+	for _, o := range d.objects {
+		records = append(records, journalRecord{Op: journalPut, Object: o})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Object.ID < records[j].Object.ID
+	})
+	for _, list := range d.bookmarks {
+		for _, cb := range list {
+			records = append(records, journalRecord{Op: journalBookmark, Bookmark: cb})
+		}
+	}
+	for _, h := range d.history {
+		records = append(records, journalRecord{Op: journalHistory, History: h})
+	}
+	for id, streams := range d.streams {
+		records = append(records, journalRecord{Op: journalStreams, ObjectID: id, Streams: streams})
+	}
+	for name, value := range d.props {
+		records = append(records, journalRecord{Op: journalProperty, Name: name, Value: value})
+	}
+	return records
+}
+
+// match reports whether object satisfies filter, it is in-memory version of WHERE clause built by PostgresDriver
+func (f *ObjectSearchFilter) match(o *Object, now time.Time) bool {
+	if f.ID > 0 && o.ID != f.ID {
+		return false
+	}
+
+	if f.LastVisitedId > 0 && o.ID <= f.LastVisitedId {
+		return false
+	}
+
+	if f.ParentPath != "" {
+		prefix := f.ParentPath + "/"
+		if !strings.HasPrefix(o.Path, prefix) || strings.Contains(o.Path[len(prefix):], "/") {
+			return false
+		}
+	}
+
+	if f.PathPrefix != "" && !strings.HasPrefix(o.Path, f.PathPrefix) {
+		return false
+	}
+
+	if len(f.OwnPaths) > 0 {
+		found := false
+		for _, p := range f.OwnPaths {
+			if o.Path == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.InProgress {
+		if o.Typ != ObjectVideo || !o.Bookmark.Valid || o.Bookmark.Int64 <= 0 ||
+			float64(o.Bookmark.Int64) >= float64(o.Duration)*0.95 {
+			return false
+		}
+	}
+
+	if f.RecentlyAdded && (o.Typ != ObjectVideo || !o.AddedAt.Valid) {
+		return false
+	}
+
+	if !f.AddedAfter.IsZero() && (!o.AddedAt.Valid || o.AddedAt.Time.Before(f.AddedAfter)) {
+		return false
+	}
+
+	if f.Search != nil && !f.Search.match(o, f.SearchPathBases) {
+		return false
+	}
+
+	switch f.Status {
+	case StatusPublic:
+		return !o.ReindexAt.Valid
+	case StatusDirty:
+		return o.ReindexAt.Valid && !o.Broken
+	case StatusReindex:
+		return o.ReindexAt.Valid && !o.ReindexAt.Time.After(now) && !o.Broken
+	case StatusBroken:
+		return o.ReindexAt.Valid && o.Broken
+	}
+
+	return true
+}
@@ -0,0 +1,199 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type journalOp string
+
+const (
+	journalPut      journalOp = "put"
+	journalRemove   journalOp = "rm"
+	journalRename   journalOp = "mv"
+	journalOffline  journalOp = "offline"
+	journalPurge    journalOp = "purge"
+	journalBookmark journalOp = "bm"
+	journalHistory  journalOp = "hist"
+	journalProperty journalOp = "prop"
+	journalStreams  journalOp = "streams"
+)
+
+// journalRecord is one line of journal file
+type journalRecord struct {
+	Op      journalOp `json:"op"`
+	Object  *Object   `json:"obj,omitempty"`
+	IsDir   bool      `json:"dir,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	OldPath string    `json:"old,omitempty"`
+
+	Bookmark *ClientBookmark `json:"bm,omitempty"`
+	History  *HistoryRecord  `json:"hist,omitempty"`
+
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	ObjectID int      `json:"id,omitempty"`
+	Streams  []Stream `json:"streams,omitempty"`
+}
+
+// journal is an append only file with json encoded records (one per line),
+// on opening all records replayed and file compacted to the list of `put` records
+type journal struct {
+	file    string
+	fp      *os.File
+	w       *bufio.Writer
+	records int
+
+	// size returns amount of live objects, snapshot returns records enough to restore current state
+	size     func() int
+	snapshot func() []journalRecord
+}
+
+func openJournal(file string, replay func(journalRecord) error) (*journal, error) {
+	j := &journal{file: file}
+
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create dir: %w", err)
+	}
+
+	fp, err := os.Open(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if fp != nil {
+		scanner := bufio.NewScanner(fp)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		line := 0
+		var brokenErr error
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			if brokenErr != nil {
+				_ = fp.Close()
+				return nil, brokenErr
+			}
+			var rec journalRecord
+			if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				// last line can be broken after power failure, skip it, but fail on broken line in the middle
+				brokenErr = fmt.Errorf("line %d: %w", line, err)
+				continue
+			}
+			if err = replay(rec); err != nil {
+				_ = fp.Close()
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		err = scanner.Err()
+		_ = fp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return j, nil
+}
+
+// compact rewrites journal file with given records only, and reopens it for appending
+func (j *journal) compact(records []journalRecord) error {
+	if j == nil {
+		return nil
+	}
+
+	if j.fp != nil {
+		if err := j.close(); err != nil {
+			return err
+		}
+	}
+
+	tmpFile := j.file + ".tmp"
+	fp, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(fp)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err = enc.Encode(rec); err != nil {
+			_ = fp.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = fp.Close()
+		return err
+	}
+	if err = fp.Sync(); err != nil {
+		_ = fp.Close()
+		return err
+	}
+	if err = fp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile, j.file); err != nil {
+		return err
+	}
+
+	if j.fp, err = os.OpenFile(j.file, os.O_APPEND|os.O_WRONLY, 0666); err != nil {
+		return err
+	}
+	j.w = bufio.NewWriter(j.fp)
+	j.records = len(records)
+	return nil
+}
+
+// write appends record to the journal, journal compacted when amount of records
+// much bigger than amount of live objects
+func (j *journal) write(rec journalRecord) error {
+	if j == nil {
+		return nil
+	}
+	if j.fp == nil {
+		return fmt.Errorf("(embedded) journal is closed")
+	}
+
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("(embedded) failed to marshal: %w", err)
+	}
+	body = append(body, '\n')
+
+	if _, err = j.w.Write(body); err != nil {
+		return fmt.Errorf("(embedded) failed to write: %w", err)
+	}
+	if err = j.w.Flush(); err != nil {
+		return fmt.Errorf("(embedded) failed to write: %w", err)
+	}
+	j.records++
+
+	if j.size != nil && j.snapshot != nil && j.records > 2*j.size()+1000 {
+		if err = j.compact(j.snapshot()); err != nil {
+			return fmt.Errorf("(embedded) failed to compact: %w", err)
+		}
+	}
+	return nil
+}
+
+func (j *journal) close() error {
+	if j == nil || j.fp == nil {
+		return nil
+	}
+	err := j.w.Flush()
+	if err == nil {
+		err = j.fp.Sync()
+	}
+	if closeErr := j.fp.Close(); err == nil {
+		err = closeErr
+	}
+	j.fp = nil
+	j.w = nil
+	return err
+}
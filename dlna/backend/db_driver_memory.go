@@ -0,0 +1,7 @@
+package backend
+
+// NewMemoryDriver creates EmbeddedDriver without journal file, all objects live only in memory
+// and disappear after restart. Useful for tests and throwaway (demo) instances.
+func NewMemoryDriver() *EmbeddedDriver {
+	return newEmbeddedDriver()
+}
@@ -6,12 +6,30 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// objectColumns is list of columns in order of scanning to Object structure,
+// 'bookmark' and 'bookmark_at' columns are replaced by client's bookmark when ObjectSearchFilter.Client is set
+var objectColumns = []string{
+	"id", "path", "typ", "format", "file_size", "video_codec", "audio_codec", "width", "height",
+	"channels", "bitrate", "frequency", "duration", "bookmark", "bookmark_at", "date", "online", "reindex_at", "added_at",
+	"reindex_failures", "reindex_error", "broken",
+}
+
+// sortKeyColumns is expressions for ORDER BY by SortKey
+var sortKeyColumns = map[SortField]string{
+	SortByTitle:    "o.sort_key",
+	SortByDate:     "o.date",
+	SortBySize:     "o.file_size",
+	SortByDuration: "o.duration",
+}
+
 type PostgresDriver struct {
 	db *pgxpool.Pool
 }
@@ -30,23 +48,45 @@ func (d *PostgresDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse
 	where := make([]string, 0)
 	params := make([]any, 0)
 
+	columns := make([]string, len(objectColumns))
+	for i, c := range objectColumns {
+		columns[i] = "o." + c
+	}
+
+	from := " FROM objects o"
+	if f.Client != "" {
+		from += fmt.Sprintf(" LEFT JOIN client_bookmarks cb ON cb.object_id = o.id AND cb.client = $%d", idx)
+		params = append(params, f.Client)
+		idx++
+		columns[slices.Index(objectColumns, "bookmark")] = "cb.bookmark"
+		columns[slices.Index(objectColumns, "bookmark_at")] = "cb.updated_at"
+	}
+	bookmarkColumn := columns[slices.Index(objectColumns, "bookmark")]
+	bookmarkAtColumn := columns[slices.Index(objectColumns, "bookmark_at")]
+
 	if f.ID > 0 {
-		where = append(where, fmt.Sprintf("id = %d", f.ID))
+		where = append(where, fmt.Sprintf("o.id = %d", f.ID))
 	}
 
 	if f.LastVisitedId > 0 {
-		where = append(where, fmt.Sprintf("id > %d", f.LastVisitedId))
+		where = append(where, fmt.Sprintf("o.id > %d", f.LastVisitedId))
 	}
 
 	if f.ParentPath != "" {
-		where = append(where, fmt.Sprintf("path LIKE $%d", idx))
+		where = append(where, fmt.Sprintf("o.path LIKE $%d", idx))
 		params = append(params, f.ParentPath+"/%")
 		idx++
-		where = append(where, fmt.Sprintf("path NOT LIKE $%d", idx))
+		where = append(where, fmt.Sprintf("o.path NOT LIKE $%d", idx))
 		params = append(params, f.ParentPath+"/%/%")
 		idx++
 	}
 
+	if f.PathPrefix != "" {
+		where = append(where, fmt.Sprintf("starts_with(o.path, $%d)", idx))
+		params = append(params, f.PathPrefix)
+		idx++
+	}
+
 	if f.OwnPaths != nil && len(f.OwnPaths) > 0 {
 		q := make([]string, len(f.OwnPaths))
 		for i, p := range f.OwnPaths {
@@ -54,16 +94,36 @@ func (d *PostgresDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse
 			params = append(params, p)
 			idx++
 		}
-		where = append(where, "path IN ("+strings.Join(q, ",")+")")
+		where = append(where, "o.path IN ("+strings.Join(q, ",")+")")
+	}
+
+	if f.InProgress {
+		where = append(where, fmt.Sprintf("o.typ = %d AND %[2]s > 0 AND %[2]s < o.duration * 0.95", ObjectVideo, bookmarkColumn))
+	}
+
+	if f.RecentlyAdded {
+		where = append(where, fmt.Sprintf("o.typ = %d AND o.added_at IS NOT NULL", ObjectVideo))
+	}
+
+	if !f.AddedAfter.IsZero() {
+		where = append(where, fmt.Sprintf("o.added_at >= $%d", idx))
+		params = append(params, f.AddedAfter)
+		idx++
+	}
+
+	if f.Search != nil {
+		where = append(where, searchWhere(f.Search, f.SearchPathBases, &params, &idx))
 	}
 
 	switch f.Status {
 	case StatusPublic:
-		where = append(where, "reindex_at IS NULL")
+		where = append(where, "o.reindex_at IS NULL")
 	case StatusDirty:
-		where = append(where, "reindex_at IS NOT NULL")
+		where = append(where, "o.reindex_at IS NOT NULL AND NOT o.broken")
 	case StatusReindex:
-		where = append(where, "reindex_at IS NOT NULL AND reindex_at <= now()")
+		where = append(where, "o.reindex_at IS NOT NULL AND o.reindex_at <= now() AND NOT o.broken")
+	case StatusBroken:
+		where = append(where, "o.reindex_at IS NOT NULL AND o.broken")
 	case StatusAll:
 		// no restrictions
 	}
@@ -74,7 +134,7 @@ func (d *PostgresDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse
 	}
 
 	if f.WithTotalMatches {
-		q := "SELECT" + " count(*) FROM objects" + whereString
+		q := "SELECT" + " count(*)" + from + whereString
 		if err := d.db.QueryRow(context.Background(), q, params...).Scan(&out.TotalMatches); err != nil {
 			return nil, fmt.Errorf("(psql.Objects) failed getting total matches: %w", err)
 		}
@@ -86,14 +146,25 @@ func (d *PostgresDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse
 	var orderBy string
 	switch f.Sort {
 	case SortPublic:
-		orderBy = " ORDER BY typ, path"
+		orderBy = " ORDER BY o.typ"
+		for _, key := range f.SortKeys {
+			orderBy += ", " + sortKeyColumns[key.Field]
+			if key.Desc {
+				orderBy += " DESC"
+			}
+		}
+		orderBy += ", o.sort_key, o.path"
 	case SortById:
-		orderBy = " ORDER BY id"
+		orderBy = " ORDER BY o.id"
+	case SortBookmarkTime:
+		orderBy = fmt.Sprintf(" ORDER BY %s DESC NULLS LAST, o.id", bookmarkAtColumn)
+	case SortAddedTime:
+		orderBy = " ORDER BY o.added_at DESC NULLS LAST, o.id DESC"
 	case SortNone:
 		// no sorting
 	}
 
-	q := "SELECT" + " * FROM objects" + whereString + orderBy
+	q := "SELECT " + strings.Join(columns, ", ") + from + whereString + orderBy
 
 	if f.Limit > 0 {
 		q += fmt.Sprintf(" LIMIT %d", f.Limit)
@@ -130,12 +201,18 @@ func (d *PostgresDriver) GetObjects(f ObjectSearchFilter) (*ObjectSearchResponse
 			&item.Frequency,
 			&item.Duration,
 			&item.Bookmark,
+			&item.BookmarkAt,
 			&item.Date,
 			&item.Online,
 			&item.ReindexAt,
+			&item.AddedAt,
+			&item.ReindexFailures,
+			&item.ReindexError,
+			&item.Broken,
 		); err != nil {
 			return nil, fmt.Errorf("(psql.Objects) failed scan row: %w", err)
 		}
+		item.Client = f.Client
 		out.Items = append(out.Items, item)
 	}
 
@@ -212,16 +289,22 @@ func (d *PostgresDriver) UpdateObject(o *Object, v *VideoInfo, b *BookmarkInfo)
 			o.ReindexAt = sql.NullTime{}
 			updates = append(updates, "reindex_at = NULL")
 		}
+		if o.ReindexFailures != 0 || o.ReindexError != "" || o.Broken {
+			o.ReindexFailures, o.ReindexError, o.Broken = 0, "", false
+			updates = append(updates, "reindex_failures = 0", "reindex_error = ''", "broken = false")
+		}
 	}
 	if b != nil {
 		// bookmarkInfo
 		if o.Bookmark != b.Bookmark {
 			o.Bookmark = b.Bookmark
+			o.BookmarkAt = sql.NullTime{Time: time.Now(), Valid: true}
 			if b.Bookmark.Valid {
 				updates = append(updates, fmt.Sprintf("bookmark = %d", b.Bookmark.Int64))
 			} else {
 				updates = append(updates, fmt.Sprintf("bookmark = NULL"))
 			}
+			updates = append(updates, "bookmark_at = now()")
 		}
 	}
 
@@ -242,6 +325,37 @@ func (d *PostgresDriver) UpdateObject(o *Object, v *VideoInfo, b *BookmarkInfo)
 	return nil
 }
 
+func (d *PostgresDriver) SetClientBookmark(objectID int, client string, bookmark sql.NullInt64) error {
+	q := "INSERT INTO client_bookmarks (object_id, client, bookmark, updated_at) VALUES ($1, $2, $3, now())" +
+		" ON CONFLICT (object_id, client) DO UPDATE SET bookmark = EXCLUDED.bookmark, updated_at = EXCLUDED.updated_at"
+	if _, err := d.db.Exec(context.Background(), q, objectID, client, bookmark); err != nil {
+		return fmt.Errorf("(psql.SetClientBookmark) failed query: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresDriver) SetReindexFailure(objectID int, f ReindexFailure) error {
+	q := "UPDATE objects SET reindex_failures = $1, reindex_error = $2, reindex_at = $3, broken = $4, file_size = $5, date = $6" +
+		" WHERE id = $7"
+	if _, err := d.db.Exec(context.Background(), q, f.Failures, f.Error, f.RetryAt, f.Broken, f.FileSize, f.Date, objectID); err != nil {
+		return fmt.Errorf("(psql.SetReindexFailure) failed query: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresDriver) RequeueBroken(objectID int) (int, error) {
+	q := "UPDATE objects SET broken = false, reindex_failures = 0, reindex_error = '', reindex_at = now()" +
+		" WHERE broken AND reindex_at IS NOT NULL"
+	if objectID > 0 {
+		q += fmt.Sprintf(" AND id = %d", objectID)
+	}
+	tag, err := d.db.Exec(context.Background(), q)
+	if err != nil {
+		return 0, fmt.Errorf("(psql.RequeueBroken) failed query: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
 func (d *PostgresDriver) AllObjectsToOffline() error {
 	_, err := d.db.Exec(context.Background(), "UPDATE objects SET online = false WHERE online")
 	return err
@@ -253,7 +367,8 @@ func (d *PostgresDriver) DeleteOfflineObjects() error {
 }
 
 func (d *PostgresDriver) Index(isDir bool, fullPath string) error {
-	_, err := d.db.Exec(context.Background(), "CALL index_add($1, $2)", isDir, fullPath)
+	sortKey := (&Object{Path: fullPath, Typ: objectType(isDir)}).sortKey()
+	_, err := d.db.Exec(context.Background(), "CALL index_add($1, $2, $3)", isDir, fullPath, sortKey)
 	return err
 }
 
@@ -263,6 +378,179 @@ func (d *PostgresDriver) Remove(isDir bool, fullPath string) error {
 }
 
 func (d *PostgresDriver) Rename(isDir bool, oldFullPath string, newFullPath string) error {
-	_, err := d.db.Exec(context.Background(), "CALL index_rename($1, $2, $3)", isDir, oldFullPath, newFullPath)
+	sortKey := (&Object{Path: newFullPath, Typ: objectType(isDir)}).sortKey()
+	_, err := d.db.Exec(context.Background(), "CALL index_rename($1, $2, $3, $4)", isDir, oldFullPath, newFullPath, sortKey)
 	return err
 }
+
+var streamColumns = "idx, typ, codec, profile, language, title, is_default, is_forced, pixel_format, frame_rate," +
+	" width, height, channels, sample_rate, bitrate, hdr, rotation, file"
+
+func (d *PostgresDriver) SetStreams(objectID int, streams []Stream) error {
+	ctx := context.Background()
+
+	tx, err := d.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("(psql.SetStreams) failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, "DELETE FROM streams WHERE object_id = $1", objectID); err != nil {
+		return fmt.Errorf("(psql.SetStreams) failed to delete: %w", err)
+	}
+
+	q := "INSERT INTO streams (object_id, " + streamColumns + ")" +
+		" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)"
+	batch := &pgx.Batch{}
+	for _, s := range streams {
+		batch.Queue(q, objectID, s.Index, s.Type, s.Codec, s.Profile, s.Language, s.Title, s.Default, s.Forced,
+			s.PixelFormat, s.FrameRate, s.Width, s.Height, s.Channels, s.SampleRate, s.Bitrate, s.HDR, s.Rotation, s.File)
+	}
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("(psql.SetStreams) failed to insert: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("(psql.SetStreams) failed to commit: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresDriver) GetStreams(objectID int) ([]Stream, error) {
+	q := "SELECT " + streamColumns + " FROM streams WHERE object_id = $1 ORDER BY idx"
+	rows, err := d.db.Query(context.Background(), q, objectID)
+	if err != nil {
+		return nil, fmt.Errorf("(psql.GetStreams) failed query: %w", err)
+	}
+	defer rows.Close()
+
+	streams := make([]Stream, 0)
+	for rows.Next() {
+		var s Stream
+		if err = rows.Scan(&s.Index, &s.Type, &s.Codec, &s.Profile, &s.Language, &s.Title, &s.Default, &s.Forced,
+			&s.PixelFormat, &s.FrameRate, &s.Width, &s.Height, &s.Channels, &s.SampleRate, &s.Bitrate, &s.HDR, &s.Rotation, &s.File); err != nil {
+			return nil, fmt.Errorf("(psql.GetStreams) failed scan row: %w", err)
+		}
+		streams = append(streams, s)
+	}
+	return streams, rows.Err()
+}
+
+func (d *PostgresDriver) GetStreamsByObjects(objectIDs []int) (map[int][]Stream, error) {
+	out := make(map[int][]Stream)
+	if len(objectIDs) == 0 {
+		return out, nil
+	}
+	q := "SELECT object_id, " + streamColumns + " FROM streams WHERE object_id = ANY($1) ORDER BY object_id, idx"
+	rows, err := d.db.Query(context.Background(), q, objectIDs)
+	if err != nil {
+		return nil, fmt.Errorf("(psql.GetStreamsByObjects) failed query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var objectID int
+		var s Stream
+		if err = rows.Scan(&objectID, &s.Index, &s.Type, &s.Codec, &s.Profile, &s.Language, &s.Title, &s.Default, &s.Forced,
+			&s.PixelFormat, &s.FrameRate, &s.Width, &s.Height, &s.Channels, &s.SampleRate, &s.Bitrate, &s.HDR, &s.Rotation, &s.File); err != nil {
+			return nil, fmt.Errorf("(psql.GetStreamsByObjects) failed scan row: %w", err)
+		}
+		out[objectID] = append(out[objectID], s)
+	}
+	return out, rows.Err()
+}
+
+func (d *PostgresDriver) GetProperty(name string) (string, error) {
+	var value string
+	err := d.db.QueryRow(context.Background(), "SELECT value FROM properties WHERE name = $1", name).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNoRows
+	}
+	if err != nil {
+		return "", fmt.Errorf("(psql.GetProperty) failed query: %w", err)
+	}
+	return value, nil
+}
+
+func (d *PostgresDriver) SetProperty(name string, value string) error {
+	q := "INSERT INTO properties (name, value, updated_at) VALUES ($1, $2, now())" +
+		" ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at"
+	if _, err := d.db.Exec(context.Background(), q, name, value); err != nil {
+		return fmt.Errorf("(psql.SetProperty) failed query: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresDriver) AddHistory(h *HistoryRecord) error {
+	q := "INSERT INTO watch_history (object_id, path, client, position, duration) VALUES ($1, $2, $3, $4, $5)" +
+		" RETURNING id, created_at"
+	err := d.db.QueryRow(context.Background(), q, h.ObjectID, h.Path, h.Client, h.Position, h.Duration).
+		Scan(&h.ID, &h.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("(psql.AddHistory) failed query: %w", err)
+	}
+	q = "DELETE FROM watch_history WHERE object_id = $1 AND client = $2 AND id NOT IN" +
+		" (SELECT id FROM watch_history WHERE object_id = $1 AND client = $2 ORDER BY id DESC LIMIT $3)"
+	if _, err = d.db.Exec(context.Background(), q, h.ObjectID, h.Client, MaxHistoryRecords); err != nil {
+		return fmt.Errorf("(psql.AddHistory) failed to delete old records: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresDriver) GetHistory(f HistoryFilter) ([]*HistoryRecord, error) {
+	out := make([]*HistoryRecord, 0)
+
+	idx := 1
+	where := make([]string, 0)
+	params := make([]any, 0)
+
+	if f.ObjectID > 0 {
+		where = append(where, fmt.Sprintf("object_id = %d", f.ObjectID))
+	}
+	if f.Client != "" {
+		where = append(where, fmt.Sprintf("client = $%d", idx))
+		params = append(params, f.Client)
+		idx++
+	}
+	if !f.Since.IsZero() {
+		where = append(where, fmt.Sprintf("created_at >= $%d", idx))
+		params = append(params, f.Since)
+		idx++
+	}
+	if !f.Until.IsZero() {
+		where = append(where, fmt.Sprintf("created_at < $%d", idx))
+		params = append(params, f.Until)
+		idx++
+	}
+	if f.NonZero {
+		where = append(where, "position > 0")
+	}
+
+	q := "SELECT id, object_id, path, client, position, duration, created_at FROM watch_history"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY created_at DESC, id DESC"
+	if f.Limit > 0 {
+		q += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	if f.Offset > 0 {
+		q += fmt.Sprintf(" OFFSET %d", f.Offset)
+	}
+
+	rows, err := d.db.Query(context.Background(), q, params...)
+	if err != nil {
+		return nil, fmt.Errorf("(psql.GetHistory) failed query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		h := new(HistoryRecord)
+		if err = rows.Scan(&h.ID, &h.ObjectID, &h.Path, &h.Client, &h.Position, &h.Duration, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("(psql.GetHistory) failed scan row: %w", err)
+		}
+		out = append(out, h)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,202 @@
+package backend
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var embedMigrationsFS embed.FS
+
+// migrationsLockId is a key for pg_advisory_xact_lock, protects from running migrations by two instances at once
+const migrationsLockId = 7436471
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns all migrations embedded to the binary sorted by version,
+// migration file name format is "{version}_{name}.sql", for example "0001_init.sql"
+func Migrations() ([]Migration, error) {
+	entries, err := embedMigrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("(migrations) failed to read embedded fs: %w", err)
+	}
+
+	list := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		version, title, found := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("(migrations) invalid file name '%s'", name)
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("(migrations) invalid version in file name '%s'", name)
+		}
+		body, err := embedMigrationsFS.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("(migrations) failed to read '%s': %w", name, err)
+		}
+		list = append(list, Migration{Version: v, Name: title, SQL: string(body)})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("(migrations) duplicate version %d", list[i].Version)
+		}
+	}
+
+	return list, nil
+}
+
+// SchemaVersion returns version of the last applied migration and version of the latest embedded migration
+func (d *PostgresDriver) SchemaVersion() (current int, latest int, err error) {
+	var list []Migration
+	if list, err = Migrations(); err != nil {
+		return
+	}
+	if len(list) > 0 {
+		latest = list[len(list)-1].Version
+	}
+	if err = d.createMigrationsTable(); err != nil {
+		return
+	}
+	err = d.db.QueryRow(context.Background(), "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		err = fmt.Errorf("(psql.SchemaVersion) failed query: %w", err)
+	}
+	return
+}
+
+// Migrate applies all not applied yet migrations, each migration in own transaction.
+// Returns error if database schema is newer than the latest embedded migration.
+func (d *PostgresDriver) Migrate() error {
+	ctx := context.Background()
+
+	list, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+	latest := list[len(list)-1].Version
+
+	if err = d.createMigrationsTable(); err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		tx, err := d.db.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("(psql.Migrate) failed to begin transaction: %w", err)
+		}
+
+		applied, err := func() (bool, error) {
+			if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationsLockId); err != nil {
+				return false, fmt.Errorf("failed to get lock: %w", err)
+			}
+
+			var current int
+			if err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+				return false, fmt.Errorf("failed to get schema version: %w", err)
+			}
+
+			if current > latest {
+				return false, fmt.Errorf("database schema version %d is newer than supported by this binary (%d)", current, latest)
+			}
+
+			if m.Version <= current {
+				return false, nil
+			}
+
+			if _, err := tx.Exec(ctx, m.SQL); err != nil {
+				return false, fmt.Errorf("failed to apply %04d_%s: %w", m.Version, m.Name, err)
+			}
+
+			if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+				return false, fmt.Errorf("failed to register %04d_%s: %w", m.Version, m.Name, err)
+			}
+			return true, nil
+		}()
+
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return fmt.Errorf("(psql.Migrate) %w", err)
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return fmt.Errorf("(psql.Migrate) failed to commit %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		if applied {
+			slog.Info("database migration applied", "version", m.Version, "name", m.Name)
+		}
+	}
+
+	return d.fillSortKeys()
+}
+
+// fillSortKeys calculates natural sort keys for objects without it (indexed before 0006_sort_key migration),
+// sort key can not be calculated by postgres itself
+func (d *PostgresDriver) fillSortKeys() error {
+	ctx := context.Background()
+
+	rows, err := d.db.Query(ctx, "SELECT id, path, typ FROM objects WHERE sort_key IS NULL")
+	if err != nil {
+		return fmt.Errorf("(psql.Migrate) failed to query objects without sort key: %w", err)
+	}
+	objects, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Object, error) {
+		o := new(Object)
+		return o, row.Scan(&o.ID, &o.Path, &o.Typ)
+	})
+	if err != nil {
+		return fmt.Errorf("(psql.Migrate) failed to scan objects without sort key: %w", err)
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, o := range objects {
+		batch.Queue("UPDATE objects SET sort_key = $1 WHERE id = $2", o.sortKey(), o.ID)
+	}
+	if err = d.db.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("(psql.Migrate) failed to update sort keys: %w", err)
+	}
+
+	slog.Info("sort keys calculated", "objects", len(objects))
+	return nil
+}
+
+func (d *PostgresDriver) createMigrationsTable() error {
+	_, err := d.db.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INT       NOT NULL PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("(psql.Migrate) failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
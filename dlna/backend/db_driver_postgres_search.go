@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// titleExpression is sql version of Object.Title(): base name of path, without extension for videos
+var titleExpression = fmt.Sprintf(
+	`lower(CASE WHEN o.typ = %d THEN regexp_replace(o.path, '^.*/', '') ELSE regexp_replace(o.path, '^.*/|\.[^./]*$', '', 'g') END)`,
+	ObjectFolder,
+)
+
+var searchOperators = map[SearchOperator]string{
+	SearchEqual:          "=",
+	SearchNotEqual:       "<>",
+	SearchLess:           "<",
+	SearchLessOrEqual:    "<=",
+	SearchGreater:        ">",
+	SearchGreaterOrEqual: ">=",
+}
+
+// pathExpression is sql version of searchPath(), bases are added to params
+func pathExpression(bases []string, params *[]any, idx *int) string {
+	dir := `regexp_replace(o.path, '[^/]*$', '')`
+	if len(bases) == 0 {
+		return "lower(" + dir + ")"
+	}
+	cases := make([]string, len(bases))
+	for i, base := range bases {
+		*params = append(*params, base)
+		cases[i] = fmt.Sprintf("WHEN starts_with(%[1]s, $%[2]d) THEN substr(%[1]s, length($%[2]d) + 1)", dir, *idx)
+		*idx++
+	}
+	return fmt.Sprintf("lower(CASE %s ELSE %s END)", strings.Join(cases, " "), dir)
+}
+
+// searchWhere builds WHERE clause for search conditions tree, values are added to params,
+// bases are prefixes removed from path for SearchPath
+func searchWhere(c *SearchCondition, bases []string, params *[]any, idx *int) string {
+	switch {
+	case len(c.And) > 0:
+		return searchWhereGroup(c.And, " AND ", bases, params, idx)
+	case len(c.Or) > 0:
+		return searchWhereGroup(c.Or, " OR ", bases, params, idx)
+	case c.Const != nil:
+		if *c.Const {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+
+	placeholder := func(value any) string {
+		*params = append(*params, value)
+		*idx++
+		return fmt.Sprintf("$%d", *idx-1)
+	}
+
+	switch c.Field {
+	case SearchTitle:
+		value, _ := c.Value.(string)
+		switch c.Operator {
+		case SearchContains:
+			return fmt.Sprintf("strpos(%s, lower(%s)) > 0", titleExpression, placeholder(value))
+		case SearchNotContains:
+			return fmt.Sprintf("strpos(%s, lower(%s)) = 0", titleExpression, placeholder(value))
+		}
+		if op, ok := searchOperators[c.Operator]; ok {
+			return fmt.Sprintf("%s %s lower(%s)", titleExpression, op, placeholder(value))
+		}
+	case SearchType:
+		value, _ := c.Value.(ObjectType)
+		if op, ok := searchOperators[c.Operator]; ok {
+			return fmt.Sprintf("o.typ %s %d", op, value)
+		}
+	case SearchDate:
+		value, _ := c.Value.(int64)
+		if op, ok := searchOperators[c.Operator]; ok {
+			return fmt.Sprintf("(o.typ = %d AND o.date %s %d)", ObjectVideo, op, value)
+		}
+	case SearchDuration:
+		value, _ := c.Value.(int64)
+		if op, ok := searchOperators[c.Operator]; ok {
+			return fmt.Sprintf("(o.typ = %d AND o.duration %s %d)", ObjectVideo, op, value)
+		}
+	case SearchPath:
+		value, _ := c.Value.(string)
+		switch c.Operator {
+		case SearchContains:
+			return fmt.Sprintf("strpos(%s, lower(%s)) > 0", pathExpression(bases, params, idx), placeholder(value))
+		case SearchNotContains:
+			return fmt.Sprintf("strpos(%s, lower(%s)) = 0", pathExpression(bases, params, idx), placeholder(value))
+		}
+	}
+	return "FALSE"
+}
+
+func searchWhereGroup(list []*SearchCondition, sep string, bases []string, params *[]any, idx *int) string {
+	parts := make([]string, len(list))
+	for i, sub := range list {
+		parts[i] = searchWhere(sub, bases, params, idx)
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"time"
+)
+
+// HistoryRecord is one X_SetBookmark call
+// MaxHistoryRecords is amount of the newest history records kept for every pair of object and client,
+// older records are deleted
+const MaxHistoryRecords = 100
+
+type HistoryRecord struct {
+	ID int `json:"id"`
+
+	// ObjectID is id of the video, Path is kept for history of deleted (or renamed) files
+	ObjectID int    `json:"object_id"`
+	Path     string `json:"path"`
+
+	// Client is alias or 'User-Agent@IP' of the TV
+	Client string `json:"client"`
+
+	// Position is bookmark in milliseconds, Duration is duration of the video in milliseconds
+	Position int64 `json:"position"`
+	Duration int64 `json:"duration"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HistoryFilter struct {
+	// ObjectID should add `WHERE object_id = {value}`
+	ObjectID int
+
+	// Client should add `WHERE client = {value}`
+	Client string
+
+	// Since should add `WHERE created_at >= {value}`
+	Since time.Time
+
+	// Until should add `WHERE created_at < {value}`
+	Until time.Time
+
+	// NonZero should add `WHERE position > 0`, skips "reset" (and watched to the end) records
+	NonZero bool
+
+	// Limit should add `LIMIT {value}`
+	Limit int
+
+	// Offset should add `OFFSET {value}`
+	Offset int
+}
+
+// match reports whether record satisfies filter, it is in-memory version of WHERE clause built by PostgresDriver
+func (f *HistoryFilter) match(h *HistoryRecord) bool {
+	if f.ObjectID > 0 && h.ObjectID != f.ObjectID {
+		return false
+	}
+	if f.Client != "" && h.Client != f.Client {
+		return false
+	}
+	if !f.Since.IsZero() && h.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !h.CreatedAt.Before(f.Until) {
+		return false
+	}
+	if f.NonZero && h.Position <= 0 {
+		return false
+	}
+	return true
+}
+
+// History returns watch history records, the newest records first.
+// Example, what did we watch last week:
+//
+//	b.History(HistoryFilter{Since: time.Now().AddDate(0, 0, -7)})
+func (b *Backend) History(filter HistoryFilter) ([]*HistoryRecord, error) {
+	return b.d.GetHistory(filter)
+}
+
+// RestoreBookmark sets bookmark to the latest non-zero position from the watch history,
+// it is undo of the accidental reset of bookmark to 0.
+// Returns restored position in milliseconds.
+func (b *Backend) RestoreBookmark(id int, client string, ownBookmark bool) (int64, error) {
+	filter := HistoryFilter{ObjectID: id, NonZero: true, Limit: 1}
+	if ownBookmark {
+		filter.Client = client
+	}
+
+	list, err := b.d.GetHistory(filter)
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, ErrNoRows
+	}
+
+	position := list[0].Position
+	return position, b.SetBookmark(id, position, client, ownBookmark)
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryLimit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "godlna.journal")
+	d, err := NewEmbeddedDriver(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Index(false, "/video/a.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range MaxHistoryRecords + 50 {
+		if err = d.AddHistory(&HistoryRecord{ObjectID: 1, Client: "bedroom", Position: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 3 {
+		if err = d.AddHistory(&HistoryRecord{ObjectID: 1, Client: "kitchen", Position: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		for client, want := range map[string]int{"bedroom": MaxHistoryRecords, "kitchen": 3} {
+			list, err := d.GetHistory(HistoryFilter{ObjectID: 1, Client: client})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != want {
+				t.Errorf("%s: %d records of %s; want %d", stage, len(list), client, want)
+				continue
+			}
+			// the oldest records are deleted
+			if oldest := list[len(list)-1].Position; client == "bedroom" && oldest != 50 {
+				t.Errorf("%s: oldest position of %s %d; want 50", stage, client, oldest)
+			}
+		}
+	}
+	check("added")
+
+	// journal is compacted on opening, deleted records are not replayed
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = NewEmbeddedDriver(file); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Close() }()
+	check("reopened")
+	body, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != 1+MaxHistoryRecords+3 {
+		t.Errorf("compacted journal has %d records; want %d", lines, 1+MaxHistoryRecords+3)
+	}
+}
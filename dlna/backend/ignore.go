@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ignorePatterns are user's rules of excluding files and directories from index
+type ignorePatterns struct {
+	// names are matched with base name of file or directory, for example "*.sample.mkv" or ".Trash*"
+	names []string
+	// paths are matched with absolute path, content of matched directory is excluded too
+	paths []string
+}
+
+// match reports whether absolute path of file or directory is matched by one of patterns
+func (ip *ignorePatterns) match(name string) bool {
+	base := filepath.Base(name)
+	for _, pattern := range ip.names {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	for _, pattern := range ip.paths {
+		if ok, _ := filepath.Match(pattern, name); ok || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIgnorePatterns sets glob patterns (filepath.Match syntax) of files and directories excluded from index,
+// pattern without slash is matched with base name, pattern with slash is matched with absolute path.
+// Safe to call while backend is running, but already indexed objects are removed only by Rescan
+func (b *Backend) SetIgnorePatterns(patterns []string) error {
+	ip := &ignorePatterns{}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("(backend.SetIgnorePatterns) invalid pattern '%s'", pattern)
+		}
+		if strings.Contains(pattern, "/") {
+			ip.paths = append(ip.paths, filepath.Clean(pattern))
+		} else {
+			ip.names = append(ip.names, pattern)
+		}
+	}
+	b.ignore.Store(ip)
+	return nil
+}
@@ -0,0 +1,19 @@
+package backend
+
+import (
+	"github.com/szonov/godlna/pkg/metrics"
+)
+
+var (
+	watcherEvents = metrics.NewCounter("godlna_fswatcher_events_total",
+		"File system events received from the watcher, by operation.",
+		"op")
+
+	reindexQueueDepth = metrics.NewGauge("godlna_reindex_queue_depth",
+		"Objects in the reindex queue, by state (queued, running).",
+		"state")
+
+	reindexResults = metrics.NewCounter("godlna_reindex_total",
+		"Objects reindexed by workers, by result (success, failure).",
+		"result")
+)
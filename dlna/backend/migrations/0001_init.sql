@@ -1,9 +1,9 @@
 -- TypeFolder int = 0
 -- TypeVideo  int = 1
 
-DROP TABLE IF EXISTS objects CASCADE;
+-- IF NOT EXISTS: databases created by old schema.psql.sql already have this table
 
-CREATE TABLE objects
+CREATE TABLE IF NOT EXISTS objects
 (
     id          BIGSERIAL PRIMARY KEY,
     path        TEXT     NOT NULL UNIQUE,
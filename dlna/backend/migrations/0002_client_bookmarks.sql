@@ -0,0 +1,10 @@
+-- per client bookmarks, client is User-Agent with remote IP or configured alias
+
+CREATE TABLE IF NOT EXISTS client_bookmarks
+(
+    object_id  BIGINT    NOT NULL REFERENCES objects (id) ON DELETE CASCADE,
+    client     TEXT      NOT NULL,
+    bookmark   BIGINT,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (object_id, client)
+);
@@ -0,0 +1,15 @@
+-- every X_SetBookmark call, object_id without foreign key: history of deleted files should be kept
+
+CREATE TABLE IF NOT EXISTS watch_history
+(
+    id         BIGSERIAL PRIMARY KEY,
+    object_id  BIGINT    NOT NULL,
+    path       TEXT      NOT NULL,
+    client     TEXT      NOT NULL,
+    position   BIGINT    NOT NULL,
+    duration   BIGINT    NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS watch_history_created_at_idx ON watch_history (created_at);
+CREATE INDEX IF NOT EXISTS watch_history_object_id_idx ON watch_history (object_id);
@@ -0,0 +1,5 @@
+-- time of the last change of shared bookmark, used for "Continue Watching" container
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS bookmark_at TIMESTAMP;
+
+UPDATE objects SET bookmark_at = now() WHERE bookmark IS NOT NULL AND bookmark_at IS NULL;
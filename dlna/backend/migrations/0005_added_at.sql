@@ -0,0 +1,31 @@
+-- time when object was found first time, used for "Recently Added" container,
+-- objects indexed before this migration have NULL and never listed as recently added
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS added_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS objects_added_at_idx ON objects (added_at) WHERE added_at IS NOT NULL;
+
+CREATE OR REPLACE PROCEDURE index_add(IN is_dir BOOLEAN, IN full_path TEXT) AS
+$$
+BEGIN
+    -- added_at is set on first insert only, ON CONFLICT keeps it untouched
+    IF is_dir THEN
+        INSERT INTO objects (typ, path, online, reindex_at, added_at)
+        VALUES (0, full_path, true, NULL, now())
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at;
+    ELSE
+        -- give 10 second gap for new objects to start it indexing after find in file system
+        -- ... synology make something after file created
+        -- ... read somewhere that macOS copy files by samba with chunks and every time do IN_CLOSE_WRITE
+        INSERT INTO objects (typ, path, online, reindex_at, added_at)
+        VALUES (1, full_path, true, now() + make_interval(secs => 10), now())
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
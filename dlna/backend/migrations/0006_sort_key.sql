@@ -0,0 +1,54 @@
+-- natural sort key of title, calculated by application (golang.org/x/text/collate),
+-- keys of existing objects are filled by application after migration
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS sort_key BYTEA;
+
+DROP PROCEDURE IF EXISTS index_add(BOOLEAN, TEXT);
+DROP PROCEDURE IF EXISTS index_rename(BOOLEAN, TEXT, TEXT);
+
+CREATE OR REPLACE PROCEDURE index_add(IN is_dir BOOLEAN, IN full_path TEXT, IN title_sort_key BYTEA) AS
+$$
+BEGIN
+    -- added_at is set on first insert only, ON CONFLICT keeps it untouched
+    IF is_dir THEN
+        INSERT INTO objects (typ, path, online, reindex_at, added_at, sort_key)
+        VALUES (0, full_path, true, NULL, now(), title_sort_key)
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at,
+                                        sort_key  = EXCLUDED.sort_key;
+    ELSE
+        -- give 10 second gap for new objects to start it indexing after find in file system
+        -- ... synology make something after file created
+        -- ... read somewhere that macOS copy files by samba with chunks and every time do IN_CLOSE_WRITE
+        INSERT INTO objects (typ, path, online, reindex_at, added_at, sort_key)
+        VALUES (1, full_path, true, now() + make_interval(secs => 10), now(), title_sort_key)
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at,
+                                        sort_key  = EXCLUDED.sort_key;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- titles of nested objects are not changed on directory rename, only sort key of renamed object is updated
+CREATE OR REPLACE PROCEDURE index_rename(IN is_dir BOOLEAN, IN old_path TEXT, new_path TEXT, IN title_sort_key BYTEA) AS
+$$
+DECLARE
+    old_path_len        INTEGER;
+    old_path_with_slash TEXT;
+BEGIN
+    UPDATE objects SET path = new_path, sort_key = title_sort_key WHERE path = old_path;
+
+    IF is_dir THEN
+        old_path_len := length(old_path) + 2;
+        old_path_with_slash := concat(old_path, '/');
+
+        UPDATE objects
+        SET path = concat(new_path, '/', SUBSTRING(path, old_path_len))
+        WHERE starts_with(path, old_path_with_slash);
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
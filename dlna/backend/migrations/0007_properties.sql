@@ -0,0 +1,8 @@
+-- named application properties, for example SystemUpdateID of ContentDirectory service
+
+CREATE TABLE IF NOT EXISTS properties
+(
+    name       TEXT      NOT NULL PRIMARY KEY,
+    value      TEXT      NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
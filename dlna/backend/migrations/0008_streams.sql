@@ -0,0 +1,24 @@
+-- all streams (tracks) of video files found by ffprobe
+
+CREATE TABLE IF NOT EXISTS streams
+(
+    object_id    BIGINT   NOT NULL REFERENCES objects (id) ON DELETE CASCADE,
+    idx          INT      NOT NULL,
+    typ          TEXT     NOT NULL DEFAULT '',
+    codec        TEXT     NOT NULL DEFAULT '',
+    profile      TEXT     NOT NULL DEFAULT '',
+    language     TEXT     NOT NULL DEFAULT '',
+    title        TEXT     NOT NULL DEFAULT '',
+    is_default   BOOLEAN  NOT NULL DEFAULT false,
+    is_forced    BOOLEAN  NOT NULL DEFAULT false,
+    pixel_format TEXT     NOT NULL DEFAULT '',
+    frame_rate   TEXT     NOT NULL DEFAULT '',
+    width        INT      NOT NULL DEFAULT 0,
+    height       INT      NOT NULL DEFAULT 0,
+    channels     INT      NOT NULL DEFAULT 0,
+    sample_rate  INT      NOT NULL DEFAULT 0,
+    bitrate      INT      NOT NULL DEFAULT 0,
+    hdr          TEXT     NOT NULL DEFAULT '',
+    rotation     SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (object_id, idx)
+);
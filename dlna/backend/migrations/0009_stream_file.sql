@@ -0,0 +1,3 @@
+-- external streams (subtitle files next to video)
+
+ALTER TABLE streams ADD COLUMN IF NOT EXISTS file TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- bookkeeping of failed reindexing: amount of failures in a row, last error and quarantine flag,
+-- broken objects keep reindex_at (hidden for end user), but reindexer does not touch them until requeue
+
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS reindex_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS reindex_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS broken BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS objects_broken_idx ON objects (id) WHERE broken;
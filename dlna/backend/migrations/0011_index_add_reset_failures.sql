@@ -0,0 +1,35 @@
+-- file found again (replaced, moved back, rescanned) gets fresh attempts of reindexing:
+-- failures bookkeeping and quarantine flag are reset together with reindex_at
+
+CREATE OR REPLACE PROCEDURE index_add(IN is_dir BOOLEAN, IN full_path TEXT, IN title_sort_key BYTEA) AS
+$$
+BEGIN
+    -- added_at is set on first insert only, ON CONFLICT keeps it untouched
+    IF is_dir THEN
+        INSERT INTO objects (typ, path, online, reindex_at, added_at, sort_key)
+        VALUES (0, full_path, true, NULL, now(), title_sort_key)
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at,
+                                        sort_key  = EXCLUDED.sort_key,
+                                        reindex_failures = 0,
+                                        reindex_error = '',
+                                        broken = false;
+    ELSE
+        -- give 10 second gap for new objects to start it indexing after find in file system
+        -- ... synology make something after file created
+        -- ... read somewhere that macOS copy files by samba with chunks and every time do IN_CLOSE_WRITE
+        INSERT INTO objects (typ, path, online, reindex_at, added_at, sort_key)
+        VALUES (1, full_path, true, now() + make_interval(secs => 10), now(), title_sort_key)
+        ON CONFLICT(path) DO UPDATE SET typ    = EXCLUDED.typ,
+                                        path   = EXCLUDED.path,
+                                        online = EXCLUDED.online,
+                                        reindex_at  = EXCLUDED.reindex_at,
+                                        sort_key  = EXCLUDED.sort_key,
+                                        reindex_failures = 0,
+                                        reindex_error = '',
+                                        broken = false;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxReindexFailures is amount of failed reindexing in a row, after which object is quarantined as broken
+	MaxReindexFailures = 5
+
+	// reindexRetryDelay is delay before the first retry, every next failure doubles it
+	reindexRetryDelay = time.Minute
+
+	// reindexMaxRetryDelay is upper limit of delay between retries
+	reindexMaxRetryDelay = 24 * time.Hour
+)
+
+// reindexBackoff returns delay before the next attempt after given amount of failures: 1m, 2m, 4m, ... up to 24h
+func reindexBackoff(failures int) time.Duration {
+	delay := reindexRetryDelay
+	for i := 1; i < failures && delay < reindexMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, reindexMaxRetryDelay)
+}
+
+// reindexFailed stores failure of reindexing, object is retried with exponential backoff
+// and quarantined after MaxReindexFailures failures in a row
+func (b *Backend) reindexFailed(o *Object, reindexErr error) {
+	f := ReindexFailure{
+		Failures: o.ReindexFailures + 1,
+		Error:    reindexErr.Error(),
+		FileSize: o.FileSize,
+		Date:     o.Date,
+	}
+	if info, err := os.Stat(o.Path); err == nil {
+		f.FileSize, f.Date = info.Size(), info.ModTime().Unix()
+	}
+	f.Broken = f.Failures >= MaxReindexFailures
+	f.RetryAt = time.Now().Add(reindexBackoff(f.Failures))
+
+	if err := b.d.SetReindexFailure(o.ID, f); err != nil {
+		slog.Error("ReindexDirty :: SetReindexFailure", "err", err, "id", o.ID)
+		return
+	}
+
+	if f.Broken {
+		slog.Warn("video is broken, reindexing stopped", "path", o.Path, "failures", f.Failures, "err", f.Error)
+	} else {
+		slog.Error("ReindexDirty :: Reindex", "err", reindexErr, "path", o.Path, "failures", f.Failures, "retry", f.RetryAt)
+	}
+}
+
+// BrokenObjects returns objects quarantined after too many failed reindexing,
+// ReindexFailures and ReindexError explain the reason
+func (b *Backend) BrokenObjects() ([]*Object, error) {
+	res, err := b.d.GetObjects(ObjectSearchFilter{Status: StatusBroken, Sort: SortById})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// Requeue returns broken object (all broken objects when id is 0) to reindexing with fresh attempts,
+// count is amount of requeued objects
+func (b *Backend) Requeue(id int) (count int, err error) {
+	if count, err = b.d.RequeueBroken(id); err != nil || count == 0 {
+		return
+	}
+
+	slog.Info("broken videos requeued for reindexing", "count", count)
+
+	if id > 0 && b.queue != nil {
+		// explicitly requested object, go ahead of the backlog
+		b.queue.push(id, reindexBookmarked)
+	}
+	// the rest is picked up by the next reindexDirty
+	atomic.StoreUint32(&b.dirtyFlag, 1)
+	return
+}
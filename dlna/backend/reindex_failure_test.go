@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReindexBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{5, 16 * time.Minute},
+		{11, 1024 * time.Minute},
+		{12, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := reindexBackoff(tt.failures); got != tt.want {
+			t.Errorf("reindexBackoff(%d) = %v; want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// objectByPath returns object of memory driver with any status
+func objectByPath(t *testing.T, d DatabaseDriver, path string) *Object {
+	t.Helper()
+	res, err := d.GetObjects(ObjectSearchFilter{OwnPaths: []string{path}, Status: StatusAll, Sort: SortNone})
+	if err != nil || len(res.Items) != 1 {
+		t.Fatalf("object %s: %v", path, err)
+	}
+	return res.Items[0]
+}
+
+// objectStatus returns the first of dirty, reindex and broken statuses matching object
+func objectStatus(t *testing.T, d DatabaseDriver, id int) ObjectStatus {
+	t.Helper()
+	for _, status := range []ObjectStatus{StatusBroken, StatusReindex, StatusDirty} {
+		res, err := d.GetObjects(ObjectSearchFilter{Status: status, Sort: SortById})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range res.Items {
+			if o.ID == id {
+				return status
+			}
+		}
+	}
+	return StatusAll
+}
+
+func TestReindexFailedQuarantine(t *testing.T) {
+	mem := NewMemoryDriver()
+	b := &Backend{d: mem}
+	const path = "/video/broken.mkv"
+	if err := mem.Index(false, path); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= MaxReindexFailures; i++ {
+		o := objectByPath(t, mem, path)
+		before := time.Now()
+		b.reindexFailed(o, errors.New("ffprobe failed"))
+
+		o = objectByPath(t, mem, path)
+		if o.ReindexFailures != i || o.ReindexError != "ffprobe failed" {
+			t.Fatalf("failure %d: stored %d failures, error %q", i, o.ReindexFailures, o.ReindexError)
+		}
+		if retry := o.ReindexAt.Time.Sub(before); retry < reindexBackoff(i) || retry > reindexBackoff(i)+time.Minute {
+			t.Errorf("failure %d: retry in %v; want %v", i, retry, reindexBackoff(i))
+		}
+
+		want := StatusDirty
+		if i == MaxReindexFailures {
+			want = StatusBroken
+		}
+		if o.Broken != (i == MaxReindexFailures) || objectStatus(t, mem, o.ID) != want {
+			t.Errorf("failure %d: broken %v, status %v; want status %v", i, o.Broken, objectStatus(t, mem, o.ID), want)
+		}
+	}
+
+	broken, err := b.BrokenObjects()
+	if err != nil || len(broken) != 1 || broken[0].Path != path {
+		t.Fatalf("BrokenObjects() = %v, %v", broken, err)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	mem := NewMemoryDriver()
+	b := &Backend{d: mem}
+	paths := []string{"/video/a.mkv", "/video/b.mkv", "/video/c.mkv"}
+	ids := make([]int, 0)
+	for _, path := range paths {
+		if err := mem.Index(false, path); err != nil {
+			t.Fatal(err)
+		}
+		o := objectByPath(t, mem, path)
+		ids = append(ids, o.ID)
+		if err := mem.SetReindexFailure(o.ID, ReindexFailure{
+			Failures: MaxReindexFailures, Error: "failed", RetryAt: time.Now().Add(time.Hour), Broken: true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// single object
+	if count, err := b.Requeue(ids[0]); err != nil || count != 1 {
+		t.Fatalf("Requeue(%d) = %d, %v; want 1", ids[0], count, err)
+	}
+	o := objectByPath(t, mem, paths[0])
+	if o.Broken || o.ReindexFailures != 0 || o.ReindexError != "" || objectStatus(t, mem, o.ID) != StatusReindex {
+		t.Errorf("requeued object: broken %v, failures %d, error %q, status %v",
+			o.Broken, o.ReindexFailures, o.ReindexError, objectStatus(t, mem, o.ID))
+	}
+	if atomic.LoadUint32(&b.dirtyFlag) != 1 {
+		t.Errorf("reindexing is not triggered")
+	}
+
+	// object which is not broken
+	if count, err := b.Requeue(ids[0]); err != nil || count != 0 {
+		t.Errorf("Requeue(%d) of not broken object = %d, %v; want 0", ids[0], count, err)
+	}
+
+	// all the rest
+	if count, err := b.Requeue(0); err != nil || count != 2 {
+		t.Errorf("Requeue(0) = %d, %v; want 2", count, err)
+	}
+	if broken, err := b.BrokenObjects(); err != nil || len(broken) != 0 {
+		t.Errorf("BrokenObjects() after requeue = %v, %v", broken, err)
+	}
+}
+
+func TestReindexSuccessResetsFailures(t *testing.T) {
+	mem := NewMemoryDriver()
+	b := &Backend{d: mem}
+	const path = "/video/a.mkv"
+	if err := mem.Index(false, path); err != nil {
+		t.Fatal(err)
+	}
+	b.reindexFailed(objectByPath(t, mem, path), errors.New("timeout"))
+	b.reindexFailed(objectByPath(t, mem, path), errors.New("timeout"))
+
+	if err := mem.UpdateObject(objectByPath(t, mem, path), &VideoInfo{Format: "matroska", Duration: 1000}, nil); err != nil {
+		t.Fatal(err)
+	}
+	o := objectByPath(t, mem, path)
+	if o.ReindexFailures != 0 || o.ReindexError != "" || o.ReindexAt.Valid || o.Broken {
+		t.Errorf("after successful reindexing: failures %d, error %q, reindex at %v, broken %v",
+			o.ReindexFailures, o.ReindexError, o.ReindexAt, o.Broken)
+	}
+}
+
+// quarantine returns object to broken state, as after MaxReindexFailures failures
+func quarantine(t *testing.T, b *Backend, path string) {
+	t.Helper()
+	for range MaxReindexFailures {
+		b.reindexFailed(objectByPath(t, b.d, path), errors.New("ffprobe failed"))
+	}
+	if o := objectByPath(t, b.d, path); !o.Broken {
+		t.Fatalf("%s is not broken", path)
+	}
+}
+
+func TestIndexResetsQuarantine(t *testing.T) {
+	mem := NewMemoryDriver()
+	b := &Backend{d: mem}
+	const path = "/video/a.mkv"
+	if err := mem.Index(false, path); err != nil {
+		t.Fatal(err)
+	}
+	quarantine(t, b, path)
+
+	// file is found again by watcher
+	if err := mem.Index(false, path); err != nil {
+		t.Fatal(err)
+	}
+	o := objectByPath(t, mem, path)
+	if o.Broken || o.ReindexFailures != 0 || o.ReindexError != "" || objectStatus(t, mem, o.ID) != StatusDirty {
+		t.Errorf("indexed again: broken %v, failures %d, error %q, status %v",
+			o.Broken, o.ReindexFailures, o.ReindexError, objectStatus(t, mem, o.ID))
+	}
+}
+
+func TestRescanReplacedBroken(t *testing.T) {
+	root := t.TempDir()
+	mem := NewMemoryDriver()
+	b, err := NewBackend([]string{root}, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = b.roots[0]
+	path := filepath.Join(root, "a.mkv")
+	if err = os.WriteFile(path, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = mem.Index(false, path); err != nil {
+		t.Fatal(err)
+	}
+	quarantine(t, b, path)
+
+	// unchanged file stays quarantined
+	b.rescan(root)
+	if o := objectByPath(t, mem, path); !o.Broken || o.ReindexFailures != MaxReindexFailures {
+		t.Errorf("unchanged broken file after rescan: broken %v, failures %d", o.Broken, o.ReindexFailures)
+	}
+
+	// replaced by good copy
+	if err = os.WriteFile(path, []byte("good copy of the video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.rescan(root)
+	o := objectByPath(t, mem, path)
+	if o.Broken || o.ReindexFailures != 0 || objectStatus(t, mem, o.ID) != StatusDirty {
+		t.Errorf("replaced broken file after rescan: broken %v, failures %d, status %v",
+			o.Broken, o.ReindexFailures, objectStatus(t, mem, o.ID))
+	}
+}
@@ -0,0 +1,280 @@
+package backend
+
+import (
+	"container/heap"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultReindexWorkers is default amount of objects reindexed in parallel
+	DefaultReindexWorkers = 2
+
+	// DefaultReindexDelay is a pause after start of the backend before first search of dirty objects
+	DefaultReindexDelay = 11 * time.Second
+
+	// DefaultReindexInterval is a period of search of dirty objects
+	DefaultReindexInterval = 30 * time.Second
+
+	// ReindexPollInterval is a period of search of dirty objects even if nothing changed in the watched folders
+	ReindexPollInterval = 5 * time.Minute
+)
+
+// reindexPriority defines order of reindexing, higher priority goes first
+type reindexPriority int
+
+const (
+	// reindexBacklog objects found by periodic scan of dirty objects
+	reindexBacklog reindexPriority = iota
+
+	// reindexBrowsed objects inside of folder which client is browsing
+	reindexBrowsed
+
+	// reindexBookmarked objects which client tried to bookmark
+	reindexBookmarked
+)
+
+type reindexItem struct {
+	id       int
+	priority reindexPriority
+	seq      uint64
+	index    int
+}
+
+// reindexHeap implements heap.Interface, higher priority first, then in order of adding
+type reindexHeap []*reindexItem
+
+func (h reindexHeap) Len() int { return len(h) }
+
+func (h reindexHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h reindexHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *reindexHeap) Push(x any) {
+	item := x.(*reindexItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *reindexHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// reindexQueue is a priority queue of object ids waiting for reindex, every id is queued once,
+// ids being reindexed by workers are not queued again
+type reindexQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	heap    reindexHeap
+	items   map[int]*reindexItem
+	running map[int]struct{}
+	seq     uint64
+	closed  bool
+}
+
+func newReindexQueue() *reindexQueue {
+	q := &reindexQueue{
+		items:   make(map[int]*reindexItem),
+		running: make(map[int]struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds object id to the queue or raises priority of already queued id
+func (q *reindexQueue) push(id int, priority reindexPriority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	if _, ok := q.running[id]; ok {
+		return
+	}
+	if item, ok := q.items[id]; ok {
+		if priority > item.priority {
+			item.priority = priority
+			heap.Fix(&q.heap, item.index)
+		}
+		return
+	}
+
+	q.seq++
+	item := &reindexItem{id: id, priority: priority, seq: q.seq}
+	q.items[id] = item
+	heap.Push(&q.heap, item)
+	q.cond.Signal()
+	q.updateDepth()
+}
+
+// pop waits for the next object id, returns false when queue is closed
+func (q *reindexQueue) pop() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.heap) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return 0, false
+	}
+
+	item := heap.Pop(&q.heap).(*reindexItem)
+	delete(q.items, item.id)
+	q.running[item.id] = struct{}{}
+	q.updateDepth()
+	return item.id, true
+}
+
+// done marks object id as processed by worker
+func (q *reindexQueue) done(id int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, id)
+	q.updateDepth()
+}
+
+// len returns amount of queued and running ids
+func (q *reindexQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.heap) + len(q.running)
+}
+
+// stat returns amount of queued ids and list of ids being reindexed right now
+func (q *reindexQueue) stat() (queued int, running []int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	running = make([]int, 0, len(q.running))
+	for id := range q.running {
+		running = append(running, id)
+	}
+	slices.Sort(running)
+	return len(q.heap), running
+}
+
+// updateDepth publishes size of the queue to metrics, should be called with locked mutex
+func (q *reindexQueue) updateDepth() {
+	reindexQueueDepth.Set(float64(len(q.heap)), "queued")
+	reindexQueueDepth.Set(float64(len(q.running)), "running")
+}
+
+// close wakes up all waiting workers, queued ids are dropped
+func (q *reindexQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// WithReindexWorkers sets amount of objects reindexed in parallel, should be called before Start
+func (b *Backend) WithReindexWorkers(n int) *Backend {
+	b.reindexWorkers = max(n, 1)
+	return b
+}
+
+// WithReindexIntervals sets pause after start before first search of dirty objects and period of the search,
+// zero values keep defaults, should be called before Start
+func (b *Backend) WithReindexIntervals(delay, interval time.Duration) *Backend {
+	if delay > 0 {
+		b.reindexDelay = delay
+	}
+	if interval > 0 {
+		b.reindexInterval = interval
+	}
+	return b
+}
+
+// startReindexWorkers starts reindex workers, they are stopped by Stop
+func (b *Backend) startReindexWorkers() {
+	for range b.reindexWorkers {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.reindexWorker()
+		}()
+	}
+}
+
+func (b *Backend) reindexWorker() {
+	for {
+		id, ok := b.queue.pop()
+		if !ok {
+			return
+		}
+		b.reindexQueued(id)
+		b.queue.done(id)
+	}
+}
+
+// reindexQueued reindexes object if it is still waiting for reindex
+func (b *Backend) reindexQueued(id int) {
+	o, err := b.getOneObject(ObjectSearchFilter{ID: id, Status: StatusReindex, Sort: SortNone, Limit: 1})
+	if err != nil {
+		// already reindexed, removed, postponed or broken
+		return
+	}
+
+	if err = b.Reindex(o); err != nil {
+		reindexResults.Inc("failure")
+		b.reindexFailed(o, err)
+		return
+	}
+	reindexResults.Inc("success")
+
+	slog.Debug("ReindexDirty", "id", o.ID, "path", o.Path)
+	// object becomes visible for end user
+	b.notifyParentChange(o.Path)
+	b.notifyVirtualChange(RecentlyAddedID)
+}
+
+// prioritizeFolder moves dirty objects inside of folder (recursive) ahead of the reindex backlog
+func (b *Backend) prioritizeFolder(folder string) {
+	if b.queue == nil || (b.queue.len() == 0 && atomic.LoadUint32(&b.dirtyFlag) == 0) {
+		// nothing is waiting for reindex
+		return
+	}
+	res, err := b.d.GetObjects(ObjectSearchFilter{
+		PathPrefix: filepath.Clean(folder) + "/",
+		Status:     StatusReindex,
+		Sort:       SortById,
+		Limit:      100,
+	})
+	if err != nil {
+		slog.Error("prioritizeFolder :: GetObjects", "err", err)
+		return
+	}
+	for _, o := range res.Items {
+		b.queue.push(o.ID, reindexBrowsed)
+	}
+}
+
+// prioritizeObject moves dirty object (if it is ready for reindexing) ahead of the reindex backlog
+func (b *Backend) prioritizeObject(id int) {
+	if b.queue == nil {
+		return
+	}
+	o, err := b.getOneObject(ObjectSearchFilter{ID: id, Status: StatusReindex, Sort: SortNone, Limit: 1})
+	if err == nil {
+		b.queue.push(o.ID, reindexBookmarked)
+	}
+}
@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// popAll pops all queued ids in order of reindexing
+func popAll(t *testing.T, q *reindexQueue) []int {
+	t.Helper()
+	ids := make([]int, 0)
+	for queued, _ := q.stat(); queued > 0; queued, _ = q.stat() {
+		id, ok := q.pop()
+		if !ok {
+			t.Fatal("pop() of open queue = false")
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestReindexQueueOrder(t *testing.T) {
+	q := newReindexQueue()
+	q.push(1, reindexBacklog)
+	q.push(2, reindexBacklog)
+	q.push(3, reindexBrowsed)
+	q.push(4, reindexBacklog)
+	q.push(5, reindexBookmarked)
+	q.push(6, reindexBrowsed)
+
+	// promoted id keeps its place among ids of the new priority
+	q.push(4, reindexBrowsed)
+	// priority is never lowered, duplicates are ignored
+	q.push(5, reindexBacklog)
+	q.push(1, reindexBacklog)
+
+	if got, want := popAll(t, q), []int{5, 3, 4, 6, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("order of ids %v; want %v", got, want)
+	}
+}
+
+func TestReindexQueueRunning(t *testing.T) {
+	q := newReindexQueue()
+	q.push(1, reindexBacklog)
+	q.push(2, reindexBacklog)
+	if id, ok := q.pop(); !ok || id != 1 {
+		t.Fatalf("pop() = %d, %v; want 1", id, ok)
+	}
+
+	// id being reindexed is not queued again
+	q.push(1, reindexBookmarked)
+	if queued, running := q.stat(); queued != 1 || !slices.Equal(running, []int{1}) || q.len() != 2 {
+		t.Errorf("stat() = %d, %v, len %d; want 1 queued, [1] running, len 2", queued, running, q.len())
+	}
+
+	q.done(1)
+	q.push(1, reindexBacklog)
+	if got, want := popAll(t, q), []int{2, 1}; !slices.Equal(got, want) {
+		t.Errorf("order of ids after done %v; want %v", got, want)
+	}
+	q.done(1)
+	q.done(2)
+	if q.len() != 0 {
+		t.Errorf("len() = %d; want 0", q.len())
+	}
+}
+
+func TestReindexQueueClose(t *testing.T) {
+	q := newReindexQueue()
+	popped := make(chan bool)
+	go func() {
+		_, ok := q.pop()
+		popped <- ok
+	}()
+
+	q.close()
+	select {
+	case ok := <-popped:
+		if ok {
+			t.Errorf("pop() of closed queue = true")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting pop() is not woken up by close()")
+	}
+
+	q.push(1, reindexBookmarked)
+	if _, ok := q.pop(); ok {
+		t.Errorf("pop() after push to closed queue = true")
+	}
+}
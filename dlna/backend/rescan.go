@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/szonov/godlna/pkg/fswatcher"
+)
+
+// ErrRescanRunning is returned by Rescan when previous rescan is not finished yet
+var ErrRescanRunning = errors.New("rescan is already running")
+
+// Rescan synchronizes folder (all roots when id is 0) with file system in background: new and changed
+// videos are indexed and picked up by reindexer, objects of removed files are deleted, unchanged videos stay visible
+func (b *Backend) Rescan(id int) error {
+	folders := b.roots
+	if id > 0 {
+		o, err := b.AnyObject(id, "")
+		if err != nil {
+			return err
+		}
+		if o.Typ != ObjectFolder {
+			return ErrNotFolder
+		}
+		folders = []string{o.Path}
+	}
+
+	if !atomic.CompareAndSwapUint32(&b.rescanning, 0, 1) {
+		return ErrRescanRunning
+	}
+	go func() {
+		defer atomic.StoreUint32(&b.rescanning, 0)
+		for _, folder := range folders {
+			b.rescan(folder)
+		}
+		slog.Info("video folders rescanned", "dirs", folders)
+	}()
+	return nil
+}
+
+// rescan sends to fs events handler the same events as watcher would send for changes in the folder
+func (b *Backend) rescan(folder string) {
+	res, err := b.d.GetObjects(ObjectSearchFilter{PathPrefix: folder + "/", Status: StatusAll, Sort: SortNone})
+	if err != nil {
+		b.onError(err)
+		return
+	}
+	known := make(map[string]*Object, len(res.Items))
+	for _, o := range res.Items {
+		known[o.Path] = o
+	}
+
+	err = filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Warn("rescan", "path", path, "err", err)
+			return nil
+		}
+		if path == folder {
+			return nil
+		}
+
+		isDir := d.IsDir()
+		if b.ignoreFn(path, isDir) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isDir && !isVideoFile(path) {
+			// subtitles are not indexed
+			return nil
+		}
+
+		o, ok := known[path]
+		delete(known, path)
+		// objects waiting for reindexing are left as is, broken objects only when file is not replaced
+		if ok && isDir == (o.Typ == ObjectFolder) && (isDir || (o.ReindexAt.Valid && !o.Broken) || !isVideoChanged(o, d)) {
+			return nil
+		}
+
+		b.onWatcherEvent(fswatcher.Event{Op: fswatcher.Index, IsDir: isDir, Name: path})
+		return nil
+	})
+	b.onError(err)
+
+	for path, o := range known {
+		b.onWatcherEvent(fswatcher.Event{Op: fswatcher.Remove, IsDir: o.Typ == ObjectFolder, Name: path})
+	}
+}
+
+// isVideoChanged reports whether video file is changed after the last reindexing
+func isVideoChanged(o *Object, d fs.DirEntry) bool {
+	info, err := d.Info()
+	if err != nil {
+		return true
+	}
+	return o.FileSize != info.Size() || o.Date != info.ModTime().Unix()
+}
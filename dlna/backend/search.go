@@ -0,0 +1,200 @@
+package backend
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SearchField is a property of object available for search
+type SearchField int
+
+const (
+	// SearchTitle is a title of object, file name without extension for videos and folder name for folders,
+	// value is string
+	SearchTitle SearchField = iota
+
+	// SearchType is a type of object, value is ObjectType
+	SearchType
+
+	// SearchDate is a date of video, value is int64 unix timestamp
+	SearchDate
+
+	// SearchDuration is a duration of video, value is int64 milliseconds
+	SearchDuration
+
+	// SearchPath is a path of folder containing object, relative to the library as user sees it
+	// (see ObjectSearchFilter.SearchPathBases), value is string, for SearchContains and SearchNotContains only
+	SearchPath
+)
+
+type SearchOperator int
+
+const (
+	SearchEqual SearchOperator = iota
+	SearchNotEqual
+	SearchLess
+	SearchLessOrEqual
+	SearchGreater
+	SearchGreaterOrEqual
+
+	// SearchContains case-insensitive substring search, for SearchTitle and SearchPath only
+	SearchContains
+
+	// SearchNotContains case-insensitive negative substring search, for SearchTitle and SearchPath only
+	SearchNotContains
+)
+
+// SearchCondition is a node of search conditions tree, exactly one of And, Or, Const or Field (with Operator
+// and Value) is used, checked in this order
+type SearchCondition struct {
+	// And matches when all conditions match
+	And []*SearchCondition
+
+	// Or matches when at least one of conditions matches
+	Or []*SearchCondition
+
+	// Const if not nil, matches all objects (true) or nothing (false)
+	Const *bool
+
+	Field    SearchField
+	Operator SearchOperator
+	Value    any
+}
+
+// SearchConst returns condition matching all objects (true) or nothing (false)
+func SearchConst(v bool) *SearchCondition {
+	return &SearchCondition{Const: &v}
+}
+
+// match reports whether object satisfies condition, in-memory version of condition built by PostgresDriver,
+// bases are prefixes removed from path for SearchPath
+func (c *SearchCondition) match(o *Object, bases []string) bool {
+	switch {
+	case len(c.And) > 0:
+		for _, sub := range c.And {
+			if !sub.match(o, bases) {
+				return false
+			}
+		}
+		return true
+	case len(c.Or) > 0:
+		for _, sub := range c.Or {
+			if sub.match(o, bases) {
+				return true
+			}
+		}
+		return false
+	case c.Const != nil:
+		return *c.Const
+	}
+
+	switch c.Field {
+	case SearchTitle:
+		value, _ := c.Value.(string)
+		title := strings.ToLower(o.Title())
+		value = strings.ToLower(value)
+		switch c.Operator {
+		case SearchContains:
+			return strings.Contains(title, value)
+		case SearchNotContains:
+			return !strings.Contains(title, value)
+		default:
+			return compareSearch(strings.Compare(title, value), c.Operator)
+		}
+	case SearchType:
+		value, _ := c.Value.(ObjectType)
+		return compareSearch(int(o.Typ)-int(value), c.Operator)
+	case SearchDate:
+		value, _ := c.Value.(int64)
+		return o.Typ == ObjectVideo && compareSearch(cmpInt64(o.Date, value), c.Operator)
+	case SearchDuration:
+		value, _ := c.Value.(int64)
+		return o.Typ == ObjectVideo && compareSearch(cmpInt64(o.Duration, value), c.Operator)
+	case SearchPath:
+		value, _ := c.Value.(string)
+		path := strings.ToLower(searchPath(o.Path, bases))
+		value = strings.ToLower(value)
+		switch c.Operator {
+		case SearchContains:
+			return strings.Contains(path, value)
+		case SearchNotContains:
+			return !strings.Contains(path, value)
+		}
+	}
+	return false
+}
+
+// searchPath returns folder of path with trailing slash, without the first matched base
+func searchPath(path string, bases []string) string {
+	dir := path[:strings.LastIndex(path, "/")+1]
+	for _, base := range bases {
+		if strings.HasPrefix(dir, base) {
+			return dir[len(base):]
+		}
+	}
+	return dir
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareSearch converts result of comparison (negative, zero, positive) to result of operator
+func compareSearch(cmp int, op SearchOperator) bool {
+	switch op {
+	case SearchEqual:
+		return cmp == 0
+	case SearchNotEqual:
+		return cmp != 0
+	case SearchLess:
+		return cmp < 0
+	case SearchLessOrEqual:
+		return cmp <= 0
+	case SearchGreater:
+		return cmp > 0
+	case SearchGreaterOrEqual:
+		return cmp >= 0
+	}
+	return false
+}
+
+// Search returns objects matched condition inside of container o (recursive), with bookmarks of the client
+func (b *Backend) Search(o *Object, cond *SearchCondition, client string, sortKeys []SortKey, limit int, offset int) (*ObjectSearchResponse, error) {
+	filter := ObjectSearchFilter{
+		Search:           cond,
+		Limit:            limit,
+		Offset:           offset,
+		WithTotalMatches: true,
+		Client:           client,
+		SortKeys:         sortKeys,
+	}
+
+	// folders are visible starting from root in multi-root mode, and below root in single root mode
+	if len(b.roots) == 1 {
+		filter.SearchPathBases = []string{b.roots[0] + "/"}
+	} else {
+		for _, root := range b.roots {
+			filter.SearchPathBases = append(filter.SearchPathBases, filepath.Dir(root)+"/")
+		}
+	}
+
+	if vc := b.virtualContainer(o.ID); vc != nil {
+		vc.filter(b, &filter)
+	} else if o.ID > 0 {
+		if o.Typ != ObjectFolder {
+			return &ObjectSearchResponse{Items: make([]*Object, 0)}, nil
+		}
+		filter.PathPrefix = filepath.Clean(o.Path) + "/"
+	} else if len(b.roots) == 1 {
+		// single root is not visible for end user, same as in Children
+		filter.PathPrefix = b.roots[0] + "/"
+	}
+
+	return b.d.GetObjects(filter)
+}
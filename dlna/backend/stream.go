@@ -0,0 +1,102 @@
+package backend
+
+import "github.com/szonov/godlna/pkg/ffprobe"
+
+const (
+	StreamVideo    = "video"
+	StreamAudio    = "audio"
+	StreamSubtitle = "subtitle"
+)
+
+// Stream is one stream (track) of video file
+type Stream struct {
+	Index       int    `json:"index"`
+	Type        string `json:"type"`
+	Codec       string `json:"codec"`
+	Profile     string `json:"profile,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Default     bool   `json:"default,omitempty"`
+	Forced      bool   `json:"forced,omitempty"`
+	PixelFormat string `json:"pix_fmt,omitempty"`
+	FrameRate   string `json:"frame_rate,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Channels    int    `json:"channels,omitempty"`
+	SampleRate  int    `json:"sample_rate,omitempty"`
+	Bitrate     int    `json:"bitrate,omitempty"`
+	HDR         string `json:"hdr,omitempty"`
+	Rotation    int    `json:"rotation,omitempty"`
+
+	// File is not empty for external streams (subtitle files next to video)
+	File string `json:"file,omitempty"`
+}
+
+func makeStream(s ffprobe.Stream) Stream {
+	return Stream{
+		Index:       s.Index,
+		Type:        s.CodecType,
+		Codec:       s.CodecName,
+		Profile:     s.Profile,
+		Language:    s.Language(),
+		Title:       s.Title(),
+		Default:     s.IsDefault(),
+		Forced:      s.IsForced(),
+		PixelFormat: s.PixFmt,
+		FrameRate:   frameRate(s),
+		Width:       int(s.Width),
+		Height:      int(s.Height),
+		Channels:    int(s.Channels),
+		SampleRate:  int(s.SampleRate),
+		Bitrate:     int(s.BitRate),
+		HDR:         s.HDR(),
+		Rotation:    s.Rotation(),
+	}
+}
+
+// frameRate returns frame rate of video stream, "0/0" reported by ffprobe for non video streams is skipped
+func frameRate(s ffprobe.Stream) string {
+	if s.CodecType != StreamVideo || s.FrameRate == "0/0" {
+		return ""
+	}
+	return s.FrameRate
+}
+
+// Streams returns all streams of the video object ordered by index, streams loaded by LoadStreams are reused
+func (b *Backend) Streams(o *Object) ([]Stream, error) {
+	if o == nil || o.ID <= 0 || o.Typ != ObjectVideo {
+		return make([]Stream, 0), nil
+	}
+	if o.streams != nil {
+		return *o.streams, nil
+	}
+	return b.d.GetStreams(o.ID)
+}
+
+// LoadStreams loads streams of all video objects of the list by one query, so following calls of Streams,
+// Subtitles and Subtitle do not query database for every object
+func (b *Backend) LoadStreams(list []*Object) error {
+	ids := make([]int, 0, len(list))
+	for _, o := range list {
+		if o != nil && o.ID > 0 && o.Typ == ObjectVideo && o.streams == nil {
+			ids = append(ids, o.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	streams, err := b.d.GetStreamsByObjects(ids)
+	if err != nil {
+		return err
+	}
+	for _, o := range list {
+		if o != nil && o.ID > 0 && o.Typ == ObjectVideo && o.streams == nil {
+			list := streams[o.ID]
+			if list == nil {
+				list = make([]Stream, 0)
+			}
+			o.streams = &list
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+)
+
+// countingDriver counts queries of streams
+type countingDriver struct {
+	DatabaseDriver
+	single int
+	batch  int
+}
+
+func (d *countingDriver) GetStreams(objectID int) ([]Stream, error) {
+	d.single++
+	return d.DatabaseDriver.GetStreams(objectID)
+}
+
+func (d *countingDriver) GetStreamsByObjects(objectIDs []int) (map[int][]Stream, error) {
+	d.batch++
+	return d.DatabaseDriver.GetStreamsByObjects(objectIDs)
+}
+
+func TestLoadStreams(t *testing.T) {
+	mem := NewMemoryDriver()
+	for _, path := range []string{"/video/a.mkv", "/video/b.mkv", "/video/c.mkv"} {
+		if err := mem.Index(false, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mem.Index(true, "/video/folder"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := mem.GetObjects(ObjectSearchFilter{Status: StatusAll, Sort: SortById})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 4 {
+		t.Fatalf("got %d objects; want 4", len(res.Items))
+	}
+	// a.mkv and b.mkv have streams, c.mkv has no streams
+	if err = mem.SetStreams(res.Items[0].ID, []Stream{{Index: 1, Type: StreamAudio}, {Index: 0, Type: StreamVideo, Profile: "High"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mem.SetStreams(res.Items[1].ID, []Stream{{Index: 0, Type: StreamVideo, Profile: "Main"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &countingDriver{DatabaseDriver: mem}
+	b := &Backend{d: d}
+	if err = b.LoadStreams(res.Items); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"/video/a.mkv":  {"High", ""},
+		"/video/b.mkv":  {"Main"},
+		"/video/c.mkv":  {},
+		"/video/folder": {},
+	}
+	for _, o := range res.Items {
+		streams, err := b.Streams(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles := make([]string, 0, len(streams))
+		for _, s := range streams {
+			profiles = append(profiles, s.Profile)
+		}
+		if !slices.Equal(profiles, want[o.Path]) {
+			t.Errorf("%s: streams %v; want %v", o.Path, profiles, want[o.Path])
+		}
+	}
+	if d.batch != 1 || d.single != 0 {
+		t.Errorf("queries: %d batch, %d single; want 1 batch, 0 single", d.batch, d.single)
+	}
+
+	// already loaded objects are not loaded again
+	if err = b.LoadStreams(res.Items); err != nil {
+		t.Fatal(err)
+	}
+	if d.batch != 1 {
+		t.Errorf("loaded objects are queried again")
+	}
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+var subtitleExtensions = []string{".srt", ".smi", ".sami", ".ass", ".ssa", ".vtt", ".sub"}
+
+// externalStreamIndex is index of the first external subtitle stream, indexes below are used by streams
+// inside of video file
+const externalStreamIndex = 1000
+
+func isSubtitleFile(name string) bool {
+	return slices.Contains(subtitleExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+func isVideoFile(name string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+func trimExt(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// findSubtitles returns external subtitle streams for video: files next to video with the same base name,
+// for example "Movie.srt", "Movie.en.srt" or "Movie.forced.en.ass" for "Movie.mkv"
+func findSubtitles(videoFile string) []Stream {
+	entries, err := os.ReadDir(filepath.Dir(videoFile))
+	if err != nil {
+		return nil
+	}
+
+	base := trimExt(filepath.Base(videoFile))
+	streams := make([]Stream, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isSubtitleFile(name) {
+			continue
+		}
+		flags, ok := subtitleFlags(base, name)
+		if !ok {
+			continue
+		}
+		s := Stream{
+			Index: externalStreamIndex + len(streams),
+			Type:  StreamSubtitle,
+			Codec: strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."),
+			File:  filepath.Join(filepath.Dir(videoFile), name),
+		}
+		for _, flag := range flags {
+			switch flag {
+			case "forced":
+				s.Forced = true
+			case "default":
+				s.Default = true
+			default:
+				s.Language = flag
+			}
+		}
+		streams = append(streams, s)
+	}
+	return streams
+}
+
+// subtitleFlags checks that subtitle file belongs to video with base name (without extension)
+// and returns middle parts of subtitle name: language and flags, "Movie.forced.en.srt" -> ["forced", "en"].
+// Other middle parts mean another video: "Movie.Part2.srt" does not belong to "Movie.mkv"
+func subtitleFlags(base string, subtitleName string) ([]string, bool) {
+	rest, ok := strings.CutPrefix(trimExt(subtitleName), base)
+	if !ok || (rest != "" && rest[0] != '.') {
+		return nil, false
+	}
+	flags := make([]string, 0)
+	for _, part := range strings.Split(rest, ".")[1:] {
+		switch p := strings.ToLower(part); {
+		case p == "forced" || p == "default" || isLanguageCode(p):
+			flags = append(flags, p)
+		default:
+			return nil, false
+		}
+	}
+	return flags, true
+}
+
+// isLanguageCode reports whether s looks like ISO 639-1 or 639-2 code: "en", "eng"
+func isLanguageCode(s string) bool {
+	if len(s) != 2 && len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// subtitleVideos returns video files which can own subtitle file: "Movie.en.srt" belongs to "Movie.mkv"
+// and "Movie.en.mkv" if they exist
+func subtitleVideos(subtitleFile string) []string {
+	entries, err := os.ReadDir(filepath.Dir(subtitleFile))
+	if err != nil {
+		return nil
+	}
+
+	name := filepath.Base(subtitleFile)
+	videos := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !isVideoFile(entry.Name()) {
+			continue
+		}
+		if _, ok := subtitleFlags(trimExt(entry.Name()), name); ok {
+			videos = append(videos, filepath.Join(filepath.Dir(subtitleFile), entry.Name()))
+		}
+	}
+	return videos
+}
+
+// onSubtitleEvent schedules reindex of videos owning the changed subtitle file
+func (b *Backend) onSubtitleEvent(subtitleFile string) error {
+	for _, video := range subtitleVideos(subtitleFile) {
+		if err := b.d.Index(false, video); err != nil {
+			return err
+		}
+		atomic.StoreUint32(&b.dirtyFlag, 1)
+		b.notifyParentChange(video)
+	}
+	return nil
+}
+
+// Subtitle returns external subtitle stream of video object by stream index
+func (b *Backend) Subtitle(o *Object, index int) (*Stream, error) {
+	streams, err := b.Streams(o)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range streams {
+		if s.Index == index && s.File != "" {
+			return &s, nil
+		}
+	}
+	return nil, ErrNoRows
+}
+
+// Subtitles returns external subtitle streams of video object, default subtitle first
+func (b *Backend) Subtitles(o *Object) ([]Stream, error) {
+	streams, err := b.Streams(o)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Stream, 0)
+	for _, s := range streams {
+		if s.File != "" && s.Type == StreamSubtitle {
+			list = append(list, s)
+		}
+	}
+	slices.SortStableFunc(list, func(a, b Stream) int {
+		if a.Default != b.Default {
+			if a.Default {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+	return list, nil
+}
@@ -0,0 +1,34 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubtitleFlags(t *testing.T) {
+	tests := []struct {
+		base     string
+		subtitle string
+		flags    []string
+		ok       bool
+	}{
+		{"Movie", "Movie.srt", []string{}, true},
+		{"Movie", "Movie.en.srt", []string{"en"}, true},
+		{"Movie", "Movie.ENG.srt", []string{"eng"}, true},
+		{"Movie", "Movie.forced.en.ass", []string{"forced", "en"}, true},
+		{"Movie", "Movie.default.ru.srt", []string{"default", "ru"}, true},
+		{"Movie", "Movie.Part2.srt", nil, false},
+		{"Movie", "Movie 2.en.srt", nil, false},
+		{"Movie", "Movie2.srt", nil, false},
+		{"Movie", "Other.srt", nil, false},
+		{"Movie", "Movie.e1.srt", nil, false},
+		{"Movie.en", "Movie.en.srt", []string{}, true},
+		{"Movie.Part2", "Movie.Part2.en.srt", []string{"en"}, true},
+	}
+	for _, tt := range tests {
+		flags, ok := subtitleFlags(tt.base, tt.subtitle)
+		if ok != tt.ok || !slices.Equal(flags, tt.flags) {
+			t.Errorf("subtitleFlags(%q, %q) = %v, %v; want %v, %v", tt.base, tt.subtitle, flags, ok, tt.flags, tt.ok)
+		}
+	}
+}
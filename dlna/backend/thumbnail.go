@@ -1,28 +1,144 @@
 package backend
 
 import (
+	"crypto/md5"
 	"database/sql"
 	"errors"
+	"fmt"
+	"image/color"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/szonov/godlna/pkg/ffmpeg"
+	"github.com/szonov/godlna/pkg/imaging"
 )
 
 func thumbnailFile(videoFile string) string {
 	return filepath.Dir(videoFile) + "/@eaDir/" + filepath.Base(videoFile) + "/SYNOVIDEO_VIDEO_SCREENSHOT.jpg"
 }
 
+// clientThumbnailFile is thumbnail with progress of the client's own bookmark
+func clientThumbnailFile(videoFile string, client string) string {
+	return fmt.Sprintf("%s/@eaDir/%s/GODLNA_SCREENSHOT_%x.jpg", filepath.Dir(videoFile), filepath.Base(videoFile), md5.Sum([]byte(client)))
+}
+
 func isThumbnailExists(videoFile string) bool {
-	f := thumbnailFile(videoFile)
+	return isFileExists(thumbnailFile(videoFile))
+}
+
+func isFileExists(f string) bool {
 	if _, err := os.Stat(f); errors.Is(err, os.ErrNotExist) {
 		return false
 	}
 	return true
 }
 
-func makeThumbnail(videoFile string, duration int64, bookmark sql.NullInt64) error {
+// ThumbnailStyle defines how thumbnails of videos look like, progress of watching is drawn as a bar
+type ThumbnailStyle struct {
+	Width   int
+	Height  int
+	Quality int
+
+	// ProgressSize is thickness of the progress bar in pixels,
+	// ProgressPosition is a side of the image: top, right, bottom or left
+	ProgressSize     int
+	ProgressPosition string
+
+	// Colors of the progress bar in "#rrggbb" format, empty means default color
+	ProgressCompleteColor   string
+	ProgressIncompleteColor string
+	ProgressFullColor       string
+
+	// CompleteLeeway video is considered as fully watched when bookmark is closer to the end
+	CompleteLeeway time.Duration
+}
+
+// DefaultThumbnailStyle is a style used when nothing configured
+var DefaultThumbnailStyle = ThumbnailStyle{
+	Width:            480,
+	Height:           300,
+	Quality:          80,
+	ProgressSize:     20,
+	ProgressPosition: "bottom",
+	CompleteLeeway:   5 * time.Second,
+}
+
+var thumbnailPositions = map[string]imaging.Position{
+	"top":    imaging.PositionTop,
+	"right":  imaging.PositionRight,
+	"bottom": imaging.PositionBottom,
+	"left":   imaging.PositionLeft,
+}
+
+// options converts style to options of ffmpeg.Thumbnail
+func (s ThumbnailStyle) options() ([]ffmpeg.ThumbnailOption, error) {
+	if s.Width <= 0 || s.Height <= 0 {
+		return nil, fmt.Errorf("(backend.ThumbnailStyle) invalid size: %dx%d", s.Width, s.Height)
+	}
+	if s.Quality < 1 || s.Quality > 100 {
+		return nil, fmt.Errorf("(backend.ThumbnailStyle) invalid quality: %d", s.Quality)
+	}
+	position, ok := thumbnailPositions[s.ProgressPosition]
+	if !ok {
+		return nil, fmt.Errorf("(backend.ThumbnailStyle) invalid progress position: %s", s.ProgressPosition)
+	}
+	opts := []ffmpeg.ThumbnailOption{
+		ffmpeg.Width(s.Width),
+		ffmpeg.Height(s.Height),
+		ffmpeg.CompleteLeeway(s.CompleteLeeway),
+		ffmpeg.JPEGQuality(s.Quality),
+		ffmpeg.ProgressSize(s.ProgressSize),
+		ffmpeg.ProgressPosition(position),
+	}
+	colors := []struct {
+		value  string
+		option func(color.Color) ffmpeg.ThumbnailOption
+	}{
+		{s.ProgressCompleteColor, ffmpeg.ProgressCompleteColor},
+		{s.ProgressIncompleteColor, ffmpeg.ProgressIncompleteColor},
+		{s.ProgressFullColor, ffmpeg.ProgressFullColor},
+	}
+	for _, c := range colors {
+		if c.value == "" {
+			continue
+		}
+		cl, err := parseColor(c.value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, c.option(cl))
+	}
+	return opts, nil
+}
+
+// parseColor parses color in "#rrggbb" format
+func parseColor(value string) (color.Color, error) {
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || len(hex) != 6 {
+		return nil, fmt.Errorf("(backend.parseColor) invalid color: %s", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("(backend.parseColor) invalid color: %s", value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+// SetThumbnailStyle changes style of thumbnails made from now on, existing thumbnails are not touched,
+// safe to call while backend is running
+func (b *Backend) SetThumbnailStyle(style ThumbnailStyle) error {
+	opts, err := style.options()
+	if err != nil {
+		return err
+	}
+	b.thumbnailOpts.Store(&opts)
+	return nil
+}
+
+func (b *Backend) makeThumbnail(videoFile string, thumbFile string, duration int64, bookmark sql.NullInt64) error {
 	var bm int64
 	if bookmark.Valid {
 		bm = bookmark.Int64
@@ -32,21 +148,11 @@ func makeThumbnail(videoFile string, duration int64, bookmark sql.NullInt64) err
 		}
 	}
 
-	//slog.Info("MakeThumbnail", "dur", duration, "bm", bm, "bookmark", bookmark)
-
 	return ffmpeg.Thumbnail(
 		videoFile,
-		thumbnailFile(videoFile),
+		thumbFile,
 		time.Duration(duration)*time.Millisecond,
 		time.Duration(bm)*time.Millisecond,
-		// --- then options ---
-		ffmpeg.Width(480),
-		ffmpeg.Height(300),
-		ffmpeg.CompleteLeeway(5*time.Second),
-		ffmpeg.JPEGQuality(80),
-		ffmpeg.ProgressSize(20),
-		//ffmpeg.ProgressPaddingX(30),
-		//ffmpeg.ProgressPaddingY(145),
-		ffmpeg.ProgressPositionBottom(),
+		*b.thumbnailOpts.Load()...,
 	)
 }
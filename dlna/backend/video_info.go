@@ -22,6 +22,7 @@ type VideoInfo struct {
 	Frequency  int
 	Duration   int64
 	Date       int64
+	Streams    []Stream `json:",omitempty"`
 }
 
 func (mi *VideoInfo) readCacheFile(file string) error {
@@ -43,21 +44,32 @@ func (mi *VideoInfo) parseVideoFile(file string) error {
 	}
 
 	vStream := ffData.FirstVideoStream()
-	aStream := ffData.FirstAudioStream()
-
-	if vStream == nil || aStream == nil {
-		return fmt.Errorf("video or audio stream is empty '%s'", file)
+	if vStream == nil {
+		return fmt.Errorf("video stream is empty '%s'", file)
 	}
 
 	mi.Format = ffData.Format.Name
 	//o.FileSize = f.Size()
 	mi.VideoCodec = vStream.CodecName
-	mi.AudioCodec = aStream.CodecName
 	mi.Width = int(vStream.Width)
 	mi.Height = int(vStream.Height)
-	mi.Channels = int(vStream.Channels)
 	mi.Bitrate = int(ffData.Format.BitRate)
-	mi.Frequency = int(aStream.SampleRate)
+
+	// video without audio is fine
+	mi.AudioCodec = ""
+	mi.Channels = 0
+	mi.Frequency = 0
+	if aStream := ffData.FirstAudioStream(); aStream != nil {
+		mi.AudioCodec = aStream.CodecName
+		mi.Channels = int(aStream.Channels)
+		mi.Frequency = int(aStream.SampleRate)
+	}
+
+	mi.Streams = make([]Stream, 0, len(ffData.Streams))
+	for _, stream := range ffData.Streams {
+		mi.Streams = append(mi.Streams, makeStream(stream))
+	}
+
 	mi.Duration = ffData.Format.Duration.Milliseconds()
 	//o.Date = f.ModTime().Unix()
 
@@ -104,7 +116,8 @@ func GetVideoInfo(videoFile string) (*VideoInfo, error) {
 	isValid := false
 	if err := mi.readCacheFile(cacheFile); err != nil {
 		//slog.Debug("(video_info) can not read cache file", "file", cacheFile, "err", err)
-	} else if mi.FileSize == videoFileSize && mi.Date == videoModTime {
+	} else if mi.FileSize == videoFileSize && mi.Date == videoModTime && len(mi.Streams) > 0 {
+		// cache files written before streams were added are parsed again
 		isValid = true
 	}
 
@@ -0,0 +1,180 @@
+package backend
+
+import "time"
+
+// Virtual containers are listed in the root container before real folders,
+// they have negative ids, so never collide with ids of objects from database.
+const (
+	// ContinueWatchingID is ID of container with partially watched videos
+	ContinueWatchingID = -100
+
+	// RecentlyAddedID is ID of container with the latest found videos
+	RecentlyAddedID = -101
+)
+
+// RecentlyAddedWindow limits content of "Recently Added" container,
+// by amount of videos (Count) and/or by age of videos (Days), zero value means no limit.
+// Container is hidden when both limits are zero.
+type RecentlyAddedWindow struct {
+	Count int
+	Days  int
+}
+
+var DefaultRecentlyAddedWindow = RecentlyAddedWindow{Count: 50}
+
+func (w RecentlyAddedWindow) enabled() bool {
+	return w.Count > 0 || w.Days > 0
+}
+
+type virtualContainer struct {
+	ID    int
+	Title string
+
+	// enabled reports whether container is visible, nil means always visible
+	enabled func(b *Backend) bool
+
+	// filter modifies search filter for the children of container
+	filter func(b *Backend, f *ObjectSearchFilter)
+
+	// maxItems returns max amount of children, zero means no limit, nil means no limit
+	maxItems func(b *Backend) int
+}
+
+var virtualContainers = []virtualContainer{
+	{
+		ID:    ContinueWatchingID,
+		Title: "Continue Watching",
+		filter: func(b *Backend, f *ObjectSearchFilter) {
+			f.InProgress = true
+			f.Sort = SortBookmarkTime
+		},
+	},
+	{
+		ID:    RecentlyAddedID,
+		Title: "Recently Added",
+		enabled: func(b *Backend) bool {
+			return b.recentlyAdded.enabled()
+		},
+		filter: func(b *Backend, f *ObjectSearchFilter) {
+			f.RecentlyAdded = true
+			f.Sort = SortAddedTime
+			if b.recentlyAdded.Days > 0 {
+				f.AddedAfter = time.Now().AddDate(0, 0, -b.recentlyAdded.Days)
+			}
+		},
+		maxItems: func(b *Backend) int {
+			return b.recentlyAdded.Count
+		},
+	},
+}
+
+// WithRecentlyAdded sets window of "Recently Added" container
+func (b *Backend) WithRecentlyAdded(w RecentlyAddedWindow) *Backend {
+	b.recentlyAdded = w
+	return b
+}
+
+// virtualContainerList returns visible virtual containers
+func (b *Backend) virtualContainerList() []*virtualContainer {
+	list := make([]*virtualContainer, 0, len(virtualContainers))
+	for i := range virtualContainers {
+		if virtualContainers[i].enabled == nil || virtualContainers[i].enabled(b) {
+			list = append(list, &virtualContainers[i])
+		}
+	}
+	return list
+}
+
+func (b *Backend) virtualContainer(id int) *virtualContainer {
+	for _, vc := range b.virtualContainerList() {
+		if vc.ID == id {
+			return vc
+		}
+	}
+	return nil
+}
+
+func (vc *virtualContainer) object() *Object {
+	return &Object{
+		ID:     vc.ID,
+		Path:   vc.Title,
+		Typ:    ObjectFolder,
+		Online: true,
+	}
+}
+
+// virtualChildren returns children of the virtual container, paging is applied inside of maxItems window
+func (b *Backend) virtualChildren(vc *virtualContainer, client string, limit int, offset int) (*ObjectSearchResponse, error) {
+	filter := ObjectSearchFilter{
+		Limit:            limit,
+		Offset:           offset,
+		WithTotalMatches: true,
+		Client:           client,
+	}
+	vc.filter(b, &filter)
+
+	maxItems := 0
+	if vc.maxItems != nil {
+		maxItems = vc.maxItems(b)
+	}
+	if maxItems <= 0 {
+		return b.d.GetObjects(filter)
+	}
+
+	outOfWindow := offset >= maxItems
+	if outOfWindow {
+		// total matches is still required, load one item and drop it
+		filter.Limit = 1
+		filter.Offset = 0
+	} else if filter.Limit <= 0 || filter.Limit > maxItems-offset {
+		filter.Limit = maxItems - offset
+	}
+
+	res, err := b.d.GetObjects(filter)
+	if err != nil {
+		return nil, err
+	}
+	if outOfWindow {
+		res.Items = make([]*Object, 0)
+	}
+	res.TotalMatches = min(res.TotalMatches, maxItems)
+	return res, nil
+}
+
+// rootChildren returns virtual containers followed by children found with filter, paging is applied
+// to the whole list as virtual containers are part of the result
+func (b *Backend) rootChildren(filter ObjectSearchFilter) (*ObjectSearchResponse, error) {
+	out := &ObjectSearchResponse{Items: make([]*Object, 0)}
+	virtual := b.virtualContainerList()
+
+	for i := filter.Offset; i < len(virtual); i++ {
+		if filter.Limit > 0 && len(out.Items) >= filter.Limit {
+			break
+		}
+		out.Items = append(out.Items, virtual[i].object())
+	}
+
+	filter.Offset = max(0, filter.Offset-len(virtual))
+	if filter.Limit > 0 {
+		filter.Limit -= len(out.Items)
+		if filter.Limit == 0 {
+			// page is filled by virtual containers, but total matches is still required,
+			// load one item and drop it
+			filter.Limit = 1
+			res, err := b.d.GetObjects(filter)
+			if err != nil {
+				return nil, err
+			}
+			out.TotalMatches = res.TotalMatches + len(virtual)
+			return out, nil
+		}
+	}
+
+	res, err := b.d.GetObjects(filter)
+	if err != nil {
+		return nil, err
+	}
+	out.Items = append(out.Items, res.Items...)
+	out.TotalMatches = res.TotalMatches + len(virtual)
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package dlna
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BookmarkMode defines how bookmarks are stored for the clients (TVs)
+type BookmarkMode int
+
+const (
+	// BookmarkShared (default) one bookmark for all clients
+	BookmarkShared BookmarkMode = iota
+
+	// BookmarkPerClient every client has own bookmarks,
+	// client is identified by configured alias or User-Agent with remote IP
+	BookmarkPerClient
+)
+
+// ClientAlias gives a name to the client, Match is compared with remote IP (exact)
+// or with User-Agent (substring), for example:
+//
+//	ClientAlias{Match: "192.168.1.20", Name: "bedroom"}
+//	ClientAlias{Match: "40C7000", Name: "living-room"}
+type ClientAlias struct {
+	Match string
+	Name  string
+}
+
+// clientIP returns remote IP of the request without port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientName returns alias of the client if configured, otherwise 'User-Agent@IP'
+func clientName(r *http.Request, aliases []ClientAlias) string {
+	if name, ok := clientAlias(r, aliases); ok {
+		return name
+	}
+	return r.Header.Get("User-Agent") + "@" + clientIP(r)
+}
+
+// clientAlias returns configured alias of the client
+func clientAlias(r *http.Request, aliases []ClientAlias) (string, bool) {
+	ip := clientIP(r)
+	agent := r.Header.Get("User-Agent")
+	for _, alias := range aliases {
+		if alias.Match == ip || (alias.Match != "" && strings.Contains(agent, alias.Match)) {
+			return alias.Name, true
+		}
+	}
+	return "", false
+}
+
+// ClientInfo is a client (TV) which sent requests to the server
+type ClientInfo struct {
+	// Name is alias of the client or 'User-Agent@IP', the same as used for own bookmarks
+	Name      string    `json:"name"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Profile   string    `json:"profile"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  int       `json:"requests"`
+}
+
+// maxClients is max amount of clients remembered by clientRegistry, least recently seen client is forgotten first
+const maxClients = 100
+
+// clientRegistry remembers clients connected since start of the server
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*ClientInfo
+}
+
+func newClientRegistry() *clientRegistry {
+	return &clientRegistry{clients: make(map[string]*ClientInfo)}
+}
+
+// seen records request of the client
+func (c *clientRegistry) seen(r *http.Request, name string, profile string) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, ok := c.clients[name]
+	if !ok {
+		if len(c.clients) >= maxClients {
+			c.forgetOldest()
+		}
+		info = &ClientInfo{Name: name, FirstSeen: now}
+		c.clients[name] = info
+	}
+	info.IP = clientIP(r)
+	info.UserAgent = r.Header.Get("User-Agent")
+	info.Profile = profile
+	info.LastSeen = now
+	info.Requests++
+}
+
+// forgetOldest removes least recently seen client, should be called with locked mutex
+func (c *clientRegistry) forgetOldest() {
+	var oldest *ClientInfo
+	for _, info := range c.clients {
+		if oldest == nil || info.LastSeen.Before(oldest.LastSeen) {
+			oldest = info
+		}
+	}
+	if oldest != nil {
+		delete(c.clients, oldest.Name)
+	}
+}
+
+// list returns copy of all clients, recently seen first
+func (c *clientRegistry) list() []ClientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]ClientInfo, 0, len(c.clients))
+	for _, info := range c.clients {
+		list = append(list, *info)
+	}
+	slices.SortFunc(list, func(a, b ClientInfo) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return list
+}
@@ -0,0 +1,52 @@
+package dlna
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientRegistryIsBounded(t *testing.T) {
+	c := newClientRegistry()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	c.seen(r, "first", "")
+	for i := 0; i < maxClients*3; i++ {
+		c.seen(r, fmt.Sprintf("client-%d", i), "")
+	}
+	c.seen(r, "last", "")
+
+	list := c.list()
+	if len(list) != maxClients {
+		t.Fatalf("registry has %d clients; want %d", len(list), maxClients)
+	}
+	if list[0].Name != "last" {
+		t.Errorf("most recent client is %q; want last", list[0].Name)
+	}
+	for _, info := range list {
+		if info.Name == "first" {
+			t.Errorf("least recently seen client is not forgotten")
+		}
+	}
+}
+
+func TestClientName(t *testing.T) {
+	aliases := []ClientAlias{{Match: "192.168.1.20", Name: "bedroom"}, {Match: "40C7000", Name: "living-room"}}
+	tests := []struct {
+		remote string
+		agent  string
+		name   string
+	}{
+		{"192.168.1.20:1234", "Samsung", "bedroom"},
+		{"192.168.1.30:1234", "SEC_HHP_[TV] UE40C7000/1.0", "living-room"},
+		{"192.168.1.30:1234", "LG", "LG@192.168.1.30"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("User-Agent", tt.agent)
+		if name := clientName(r, aliases); name != tt.name {
+			t.Errorf("clientName(%s, %s) = %q; want %q", tt.remote, tt.agent, name, tt.name)
+		}
+	}
+}
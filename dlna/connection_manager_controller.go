@@ -0,0 +1,184 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/szonov/godlna/pkg/dlnaprofile"
+	"github.com/szonov/godlna/pkg/soap"
+	"github.com/szonov/godlna/pkg/upnp/events"
+)
+
+// InvalidConnectionReferenceErrorCode is returned by GetCurrentConnectionInfo for unknown connection
+const InvalidConnectionReferenceErrorCode uint = 706
+
+type (
+	ConnectionManagerController struct {
+		serviceDescriptionXML []byte
+		eventManager          *events.Manager
+		srv                   *Server
+
+		mu                 sync.Mutex
+		sourceProtocolInfo string
+	}
+	argOutGetProtocolInfo struct {
+		Source string
+		Sink   string
+	}
+	argOutGetCurrentConnectionIDs struct {
+		ConnectionIDs string
+	}
+	argInGetCurrentConnectionInfo struct {
+		ConnectionID int
+	}
+	argOutGetCurrentConnectionInfo struct {
+		RcsID                 int
+		AVTransportID         int
+		ProtocolInfo          string
+		PeerConnectionManager string
+		PeerConnectionID      int
+		Direction             string
+		Status                string
+	}
+)
+
+func NewConnectionManagerController(srv *Server) (*ConnectionManagerController, error) {
+	var err error
+	ctl := &ConnectionManagerController{
+		eventManager: events.NewManager().WithService("ConnectionManager"),
+		srv:          srv,
+	}
+	ctl.sourceProtocolInfo = ctl.makeSourceProtocolInfo()
+
+	if ctl.serviceDescriptionXML, err = xml.Marshal(makeConnectionManagerServiceDescription()); err != nil {
+		return ctl, err
+	}
+	ctl.serviceDescriptionXML = append([]byte(xml.Header), ctl.serviceDescriptionXML...)
+
+	return ctl, nil
+}
+
+func (ctl *ConnectionManagerController) HandleSCPDURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		soap.SendXML(ctl.serviceDescriptionXML, w)
+	} else {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (ctl *ConnectionManagerController) HandleEventSubURL(w http.ResponseWriter, r *http.Request) {
+	ctl.eventManager.HandleEventSubURL(w, r, func() map[string]string {
+		return map[string]string{
+			"SourceProtocolInfo":   ctl.SourceProtocolInfo(),
+			"SinkProtocolInfo":     "",
+			"CurrentConnectionIDs": "0",
+		}
+	})
+}
+
+func (ctl *ConnectionManagerController) HandleControlURL(w http.ResponseWriter, r *http.Request) {
+	// Control URL works only with POST http method
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// resolve current action name from http header
+	soapAction := soap.DetectAction(r.Header.Get("SoapAction"))
+	if soapAction == nil || soapAction.ServiceType != ConnectionManagerServiceType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch soapAction.Name {
+	case "GetProtocolInfo":
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, &argOutGetProtocolInfo{Source: ctl.SourceProtocolInfo()}, w)
+
+	case "GetCurrentConnectionIDs":
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, &argOutGetCurrentConnectionIDs{ConnectionIDs: "0"}, w)
+
+	case "GetCurrentConnectionInfo":
+		in := &argInGetCurrentConnectionInfo{}
+		if err := soap.UnmarshalEnvelopeRequest(r.Body, in); err != nil {
+			soap.SendError(err, w)
+			return
+		}
+		// PrepareForConnection is not implemented, so the only connection is 0 (UPnP-av-ConnectionManager 2.2.4)
+		if in.ConnectionID != 0 {
+			soap.SendUPnPError(InvalidConnectionReferenceErrorCode, "invalid connection reference", w, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, &argOutGetCurrentConnectionInfo{
+			RcsID:            -1,
+			AVTransportID:    -1,
+			PeerConnectionID: -1,
+			Direction:        "Output",
+			Status:           "OK",
+		}, w)
+
+	default:
+		err := fmt.Errorf("unknown action '%s'", soapAction.Name)
+		soap.SendUPnPError(soap.InvalidActionErrorCode, err.Error(), w, http.StatusUnauthorized)
+	}
+}
+
+// SourceProtocolInfo returns comma separated list of protocols and formats served by server
+func (ctl *ConnectionManagerController) SourceProtocolInfo() string {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.sourceProtocolInfo
+}
+
+// UpdateSourceProtocolInfo rebuilds list of served formats (for example after reload of client profiles)
+// and sends event to subscribers if it is changed
+func (ctl *ConnectionManagerController) UpdateSourceProtocolInfo() {
+	value := ctl.makeSourceProtocolInfo()
+
+	ctl.mu.Lock()
+	changed := value != ctl.sourceProtocolInfo
+	ctl.sourceProtocolInfo = value
+	ctl.mu.Unlock()
+
+	if changed {
+		ctl.eventManager.NotifyAll(map[string]string{"SourceProtocolInfo": value})
+	}
+}
+
+// makeSourceProtocolInfo builds list of formats from all DLNA profiles of videos, mime types overridden
+// by client profiles, thumbnails and subtitles
+func (ctl *ConnectionManagerController) makeSourceProtocolInfo() string {
+	list := make([]string, 0)
+	add := func(v string) {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+
+	for _, p := range dlnaprofile.All() {
+		add(p.Source())
+	}
+	for _, p := range ctl.srv.profiles().Profiles() {
+		for _, mime := range p.MimeTypes {
+			add(dlnaprofile.Profile{MimeType: mime}.Source())
+		}
+	}
+	add(dlnaprofile.Thumbnail().Source())
+
+	exts := make([]string, 0, len(subtitleMimeTypes))
+	for ext := range subtitleMimeTypes {
+		exts = append(exts, ext)
+	}
+	slices.Sort(exts)
+	for _, ext := range exts {
+		add(dlnaprofile.Profile{MimeType: subtitleMimeTypes[ext]}.Source())
+	}
+
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,133 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/profiles"
+)
+
+// cmSoap sends action of ConnectionManager service, args are XML elements of action
+func (ts *testServer) cmSoap(action string, args string) *httptest.ResponseRecorder {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + ConnectionManagerServiceType + `">` + args + `</u:` + action + `></s:Body></s:Envelope>`
+	return ts.do(http.MethodPost, "/cm/ctl", otherTV.userAgent, body, map[string]string{
+		"Content-Type": `text/xml; charset="utf-8"`,
+		"SoapAction":   `"` + ConnectionManagerServiceType + "#" + action + `"`,
+	})
+}
+
+func TestConnectionManagerActions(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000})
+
+	w := ts.cmSoap("GetProtocolInfo", "")
+	var info struct {
+		Source string `xml:"Body>GetProtocolInfoResponse>Source"`
+		Sink   string `xml:"Body>GetProtocolInfoResponse>Sink"`
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetProtocolInfo: status %d: %s", w.Code, w.Body.String())
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	source := strings.Split(info.Source, ",")
+	for _, want := range []string{
+		"http-get:*:video/mpeg:DLNA.ORG_PN=AVC_TS_HP_HD_AC3_ISO",
+		"http-get:*:video/x-matroska:DLNA.ORG_PN=MKV",
+		// mime type overridden by profile of Samsung TVs
+		"http-get:*:video/avi:*",
+		"http-get:*:image/jpeg:DLNA.ORG_PN=JPEG_TN",
+		"http-get:*:text/srt:*",
+	} {
+		if !slices.Contains(source, want) {
+			t.Errorf("GetProtocolInfo: Source has no %s", want)
+		}
+	}
+	if info.Sink != "" {
+		t.Errorf("GetProtocolInfo: Sink %q; want empty", info.Sink)
+	}
+
+	w = ts.cmSoap("GetCurrentConnectionIDs", "")
+	var ids struct {
+		ConnectionIDs string `xml:"Body>GetCurrentConnectionIDsResponse>ConnectionIDs"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &ids); err != nil || w.Code != http.StatusOK || ids.ConnectionIDs != "0" {
+		t.Errorf("GetCurrentConnectionIDs: status %d, ids %q, err %v; want 0", w.Code, ids.ConnectionIDs, err)
+	}
+
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{"0", http.StatusOK},
+		{"1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w = ts.cmSoap("GetCurrentConnectionInfo", "<ConnectionID>"+tt.id+"</ConnectionID>")
+		if w.Code != tt.status {
+			t.Errorf("GetCurrentConnectionInfo %s: status %d; want %d", tt.id, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK && (!strings.Contains(w.Body.String(), "<Direction>Output</Direction>") ||
+			!strings.Contains(w.Body.String(), "<Status>OK</Status>")) {
+			t.Errorf("GetCurrentConnectionInfo %s: %s", tt.id, w.Body.String())
+		}
+		if tt.status != http.StatusOK && !strings.Contains(w.Body.String(), "<errorCode>706</errorCode>") {
+			t.Errorf("GetCurrentConnectionInfo %s: %s; want error 706", tt.id, w.Body.String())
+		}
+	}
+
+	if w = ts.cmSoap("PrepareForConnection", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("PrepareForConnection: status %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w = ts.do(http.MethodGet, "/cm/ctl", otherTV.userAgent, "", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /cm/ctl: status %d; want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSetProfilesUpdatesSourceProtocolInfo(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000})
+
+	events := make([]string, 0)
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		events = append(events, string(body))
+	}))
+	defer subscriber.Close()
+	cm := ts.connectionManager
+	if res := cm.eventManager.Subscribe("", "upnp:event", "<"+subscriber.URL+">", "Second-300"); !res.Success {
+		t.Fatalf("subscribe: status %d", res.StatusCode)
+	}
+
+	mkv := "http-get:*:video/x-mkv:*"
+	reg := profiles.NewRegistry([]*profiles.Profile{{Name: "lg", MimeTypes: map[string]string{"matroska": "video/x-mkv"}}})
+	ts.SetProfiles(reg)
+	if !slices.Contains(strings.Split(cm.SourceProtocolInfo(), ","), mkv) {
+		t.Errorf("SourceProtocolInfo has no %s after SetProfiles", mkv)
+	}
+	if len(events) != 1 || !strings.Contains(events[0], mkv) {
+		t.Errorf("events %q; want one event with %s", events, mkv)
+	}
+	if p := ts.profile(httptest.NewRequest(http.MethodGet, "/", nil)); p.Name != profiles.DefaultName {
+		t.Errorf("profile() = %s; want %s", p.Name, profiles.DefaultName)
+	}
+
+	// the same mime types, no event
+	ts.SetProfiles(profiles.NewRegistry([]*profiles.Profile{{Name: "lg", MimeTypes: map[string]string{"matroska": "video/x-mkv"}}}))
+	if len(events) != 1 {
+		t.Errorf("%d events after SetProfiles without changes; want 1", len(events))
+	}
+
+	ts.SetProfiles(profiles.NewRegistry(nil))
+	if slices.Contains(strings.Split(cm.SourceProtocolInfo(), ","), mkv) || len(events) != 2 {
+		t.Errorf("SourceProtocolInfo %s and %d events after removal of profile; want no %s and 2 events",
+			cm.SourceProtocolInfo(), len(events), mkv)
+	}
+}
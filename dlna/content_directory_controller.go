@@ -1,28 +1,46 @@
 package dlna
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"image/jpeg"
 	"log/slog"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/dlna/profiles"
+	"github.com/szonov/godlna/pkg/dlnaprofile"
 	"github.com/szonov/godlna/pkg/ffmpeg"
+	"github.com/szonov/godlna/pkg/imaging"
 	"github.com/szonov/godlna/pkg/soap"
 	"github.com/szonov/godlna/pkg/upnp/events"
 	"github.com/szonov/godlna/pkg/upnpav"
+	"github.com/szonov/godlna/pkg/upnpav/searchcriteria"
 )
 
 type (
 	ContentDirectoryController struct {
 		serviceDescriptionXML []byte
 		eventManager          *events.Manager
-		systemUpdateId        string
 		back                  *backend.Backend
+		srv                   *Server
+
+		mu                 sync.Mutex
+		systemUpdateId     uint32
+		containerUpdateIds map[int]uint32
+		changedContainers  map[int]struct{}
+		eventTimer         *time.Timer
+		eventMu            sync.Mutex
+
+		// transcodes is a semaphore of running transcodes, nil when transcoding is disabled
+		transcodes chan struct{}
 	}
 	argInBrowse struct {
 		ObjectID       int
@@ -39,6 +57,14 @@ type (
 		TotalMatches   int
 		UpdateID       string
 	}
+	argInSearch struct {
+		ContainerID    string
+		SearchCriteria string
+		Filter         string
+		StartingIndex  int
+		RequestedCount int
+		SortCriteria   string
+	}
 	argOutGetFeatureList struct {
 		FeatureList soap.XMLLite
 	}
@@ -50,13 +76,20 @@ type (
 	}
 )
 
-func NewContentDirectoryController(back *backend.Backend) (*ContentDirectoryController, error) {
+func NewContentDirectoryController(srv *Server) (*ContentDirectoryController, error) {
 	var err error
 	ctl := &ContentDirectoryController{
-		eventManager:   events.NewManager(),
-		systemUpdateId: "1",
-		back:           back,
+		eventManager:       events.NewManager().WithService("ContentDirectory"),
+		back:               srv.back,
+		srv:                srv,
+		containerUpdateIds: make(map[int]uint32),
+		changedContainers:  make(map[int]struct{}),
 	}
+	if srv.MaxTranscodes > 0 {
+		ctl.transcodes = make(chan struct{}, srv.MaxTranscodes)
+	}
+	ctl.loadSystemUpdateId()
+	ctl.back.OnChange(ctl.onLibraryChange)
 
 	if ctl.serviceDescriptionXML, err = xml.Marshal(makeContentDirectoryServiceDescription()); err != nil {
 		return ctl, err
@@ -77,7 +110,7 @@ func (ctl *ContentDirectoryController) HandleSCPDURL(w http.ResponseWriter, r *h
 func (ctl *ContentDirectoryController) HandleEventSubURL(w http.ResponseWriter, r *http.Request) {
 	ctl.eventManager.HandleEventSubURL(w, r, func() map[string]string {
 		return map[string]string{
-			"SystemUpdateID":     ctl.systemUpdateId,
+			"SystemUpdateID":     ctl.updateId(),
 			"ContainerUpdateIDs": "",
 		}
 	})
@@ -105,7 +138,8 @@ func (ctl *ContentDirectoryController) HandleControlURL(w http.ResponseWriter, r
 			return
 		}
 
-		o, err := ctl.back.Object(in.ObjectID)
+		client := ctl.srv.bookmarkClient(r)
+		o, err := ctl.back.Object(in.ObjectID, client)
 		if err != nil {
 			soap.SendUPnPError(upnpav.NoSuchObjectErrorCode, "no such object", w, http.StatusBadRequest)
 			return
@@ -113,18 +147,23 @@ func (ctl *ContentDirectoryController) HandleControlURL(w http.ResponseWriter, r
 
 		out := &argOutBrowse{
 			Result: &soap.DIDLLite{
-				Debug: r.Header.Get("X-Debug") == "1",
+				Debug:         r.Header.Get("X-Debug") == "1",
+				NamedEntities: ctl.srv.profile(r).NamedEntities,
 			},
-			UpdateID: ctl.systemUpdateId,
+			UpdateID: ctl.containerUpdateId(o.ID),
 		}
 
 		switch in.BrowseFlag {
 		case "BrowseDirectChildren":
-			children, err := ctl.back.Children(o, in.RequestedCount, in.StartingIndex)
+			children, err := ctl.back.Children(o, client, parseSortCriteria(in.SortCriteria), in.RequestedCount, in.StartingIndex)
 			if err != nil {
 				soap.SendError(err, w)
 				return
 			}
+			if err = ctl.back.LoadStreams(children.Items); err != nil {
+				soap.SendError(err, w)
+				return
+			}
 			out.TotalMatches = children.TotalMatches
 			for _, child := range children.Items {
 				out.Result.Append(ctl.upnpavObj(child, o.ID, r))
@@ -135,6 +174,10 @@ func (ctl *ContentDirectoryController) HandleControlURL(w http.ResponseWriter, r
 				soap.SendError(err, w)
 				return
 			}
+			if err = ctl.back.LoadStreams([]*backend.Object{o}); err != nil {
+				soap.SendError(err, w)
+				return
+			}
 			out.TotalMatches = 1
 			out.Result.Append(ctl.upnpavObj(o, parentId, r))
 
@@ -149,17 +192,83 @@ func (ctl *ContentDirectoryController) HandleControlURL(w http.ResponseWriter, r
 		w.Header().Set("EXT", "")
 		soap.SendActionResponse(soapAction, out, w)
 
+	case "Search":
+		in := &argInSearch{}
+		if err := soap.UnmarshalEnvelopeRequest(r.Body, in); err != nil {
+			soap.SendError(err, w)
+			return
+		}
+
+		criteria, err := searchcriteria.Parse(in.SearchCriteria)
+		if err != nil {
+			soap.SendUPnPError(upnpav.InvalidSearchCriteriaErrorCode, err.Error(), w, http.StatusBadRequest)
+			return
+		}
+		cond, err := searchCondition(criteria)
+		if err != nil {
+			soap.SendUPnPError(upnpav.InvalidSearchCriteriaErrorCode, err.Error(), w, http.StatusBadRequest)
+			return
+		}
+
+		containerID, exists, err := resolveContainerID(in.ContainerID, ctl.srv.profile(r))
+		if err != nil {
+			soap.SendUPnPError(upnpav.NoSuchContainerErrorCode, "no such container", w, http.StatusBadRequest)
+			return
+		}
+		if !exists {
+			ctl.sendEmptyResult(soapAction, w)
+			return
+		}
+
+		client := ctl.srv.bookmarkClient(r)
+		o, err := ctl.back.Object(containerID, client)
+		if err != nil || o.Typ != backend.ObjectFolder {
+			soap.SendUPnPError(upnpav.NoSuchContainerErrorCode, "no such container", w, http.StatusBadRequest)
+			return
+		}
+
+		found, err := ctl.back.Search(o, cond, client, parseSortCriteria(in.SortCriteria), in.RequestedCount, in.StartingIndex)
+		if err != nil {
+			soap.SendError(err, w)
+			return
+		}
+		if err = ctl.back.LoadStreams(found.Items); err != nil {
+			soap.SendError(err, w)
+			return
+		}
+
+		out := &argOutBrowse{
+			Result: &soap.DIDLLite{
+				Debug:         r.Header.Get("X-Debug") == "1",
+				NamedEntities: ctl.srv.profile(r).NamedEntities,
+			},
+			TotalMatches: found.TotalMatches,
+			UpdateID:     ctl.containerUpdateId(o.ID),
+		}
+		parentIds, err := ctl.back.ParentIds(found.Items)
+		if err != nil {
+			soap.SendError(err, w)
+			return
+		}
+		for _, item := range found.Items {
+			out.Result.Append(ctl.upnpavObj(item, parentIds[item.ID], r))
+		}
+		out.NumberReturned = len(out.Result.Items)
+
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, out, w)
+
 	case "GetSearchCapabilities":
 		w.Header().Set("EXT", "")
-		soap.SendActionResponse(soapAction, "<SearchCaps></SearchCaps>", w)
+		soap.SendActionResponse(soapAction, "<SearchCaps>"+SearchCapabilities+"</SearchCaps>", w)
 
 	case "GetSortCapabilities":
 		w.Header().Set("EXT", "")
-		soap.SendActionResponse(soapAction, "<SortCaps></SortCaps>", w)
+		soap.SendActionResponse(soapAction, "<SortCaps>"+SortCapabilities+"</SortCaps>", w)
 
 	case "GetSystemUpdateID":
 		w.Header().Set("EXT", "")
-		soap.SendActionResponse(soapAction, fmt.Sprintf("<Id>%s</Id>", ctl.systemUpdateId), w)
+		soap.SendActionResponse(soapAction, fmt.Sprintf("<Id>%s</Id>", ctl.updateId()), w)
 
 	case "X_GetFeatureList":
 		out := &argOutGetFeatureList{
@@ -180,12 +289,15 @@ func (ctl *ContentDirectoryController) HandleControlURL(w http.ResponseWriter, r
 			soap.SendError(err, w)
 			return
 		}
-		if useSecondsInBookmark(r) {
+		if ctl.srv.profile(r).BookmarkInSeconds() {
 			in.PosSecond *= 1000
 		}
 
-		if err := ctl.back.SetBookmark(in.ObjectID, in.PosSecond); err != nil {
+		client := clientName(r, ctl.srv.ClientAliases)
+		ownBookmark := ctl.srv.BookmarkMode == BookmarkPerClient
+		if err := ctl.back.SetBookmark(in.ObjectID, in.PosSecond, client, ownBookmark); err != nil {
 			soap.SendError(err, w)
+			return
 		}
 		soap.SendActionResponse(soapAction, nil, w)
 
@@ -207,7 +319,7 @@ func (ctl *ContentDirectoryController) HandleContentURL(w http.ResponseWriter, r
 		return
 	}
 
-	o, err := ctl.back.Object(objectID)
+	o, err := ctl.back.Object(objectID, ctl.srv.bookmarkClient(r))
 	if err != nil {
 		slog.Error("Object not found", "objectID", objectID)
 		w.WriteHeader(http.StatusNotFound)
@@ -215,32 +327,53 @@ func (ctl *ContentDirectoryController) HandleContentURL(w http.ResponseWriter, r
 	}
 
 	w.Header().Set("EXT", "")
+	p := ctl.srv.profile(r)
 
 	if ext == ".jpg" {
 		w.Header().Set("transferMode.dlna.org", "Interactive")
-		w.Header().Set("contentFeatures.dlna.org", ctl.thumbProtocolInfo(o))
+		w.Header().Set("contentFeatures.dlna.org", ctl.thumbProtocolInfo(o).ContentFeatures())
 		w.Header().Set("Content-Type", "image/jpeg")
-		http.ServeFile(w, r, o.ThumbPath())
+		serveThumbnail(countContent(w, "thumbnail"), r, o.ThumbPath(), p)
 		return
 	}
 
+	if !handleTimeSeek(w, r, o) {
+		return
+	}
+	ctl.setCaptionInfoHeader(w, r, o, p)
 	w.Header().Set("transferMode.dlna.org", "Streaming")
-	w.Header().Set("contentFeatures.dlna.org", ctl.videoProtocolInfo(o))
-	w.Header().Set("Content-Type", ctl.mimeType(o))
-	http.ServeFile(w, r, o.Path)
+	protocolInfo := ctl.videoProtocolInfo(o, p)
+	w.Header().Set("contentFeatures.dlna.org", protocolInfo.ContentFeatures())
+	w.Header().Set("Content-Type", protocolInfo.MimeType)
+	http.ServeFile(countContent(w, "video"), r, o.Path)
+}
+
+// sendEmptyResult answers Browse or Search with empty result, for containers which are always empty
+func (ctl *ContentDirectoryController) sendEmptyResult(soapAction *soap.Action, w http.ResponseWriter) {
+	out := &argOutBrowse{
+		Result:   &soap.DIDLLite{},
+		UpdateID: ctl.updateId(),
+	}
+	w.Header().Set("EXT", "")
+	soap.SendActionResponse(soapAction, out, w)
 }
 
 func (ctl *ContentDirectoryController) upnpavObj(o *backend.Object, parentID int, r *http.Request) any {
 	if o.Typ == backend.ObjectFolder {
-		return upnpav.Container{
+		c := upnpav.Container{
 			Object: upnpav.Object{
 				ID:         strconv.Itoa(o.ID),
 				Restricted: 1,
 				ParentID:   strconv.Itoa(parentID),
-				Class:      "object.container.storageFolder",
+				Class:      upnpav.ClassStorageFolder,
 				Title:      o.Title(),
 			},
 		}
+		// Windows Media clients search only in containers marked as searchable
+		if ctl.srv.profile(r).WindowsMedia {
+			c.Searchable = 1
+		}
+		return c
 	}
 
 	thumbURL := fmt.Sprintf("http://%s/ct/t/%d.jpg", r.Host, o.ID)
@@ -248,56 +381,138 @@ func (ctl *ContentDirectoryController) upnpavObj(o *backend.Object, parentID int
 
 	// bookmark
 	var bookmark upnpav.Bookmark
-	if useSecondsInBookmark(r) {
+	p := ctl.srv.profile(r)
+	if p.BookmarkInSeconds() {
 		bookmark = upnpav.Bookmark(o.Bookmark.Int64 / 1000)
 	} else {
 		bookmark = upnpav.Bookmark(o.Bookmark.Int64)
 	}
 
+	captions, captionResources := ctl.captions(o, r, p)
+	duration := ffmpeg.DurationToString(time.Duration(o.Duration) * time.Millisecond)
+	resolution := fmt.Sprintf("%dx%d", o.Width, o.Height)
+
+	resources := make([]upnpav.Resource, 0, 3+len(captionResources))
+
+	// transcoded stream goes first, so client picks it instead of unsupported original
+	if video, audio, needed := ctl.transcodeNeeded(o, p); needed {
+		resources = append(resources, upnpav.Resource{
+			URL:           transcodeURL(o, r),
+			ProtocolInfo:  ctl.transcodeProtocolInfo(o, video, audio).String(),
+			Duration:      duration,
+			Resolution:    resolution,
+			AudioChannels: o.Channels,
+		})
+	}
+
+	resources = append(resources,
+		upnpav.Resource{
+			URL:             videoURL,
+			ProtocolInfo:    ctl.videoProtocolInfo(o, p).String(),
+			Bitrate:         o.Bitrate,
+			SampleFrequency: o.Frequency,
+			Duration:        duration,
+			Size:            o.FileSize,
+			Resolution:      resolution,
+			AudioChannels:   o.Channels,
+		},
+		upnpav.Resource{
+			URL:          thumbURL,
+			ProtocolInfo: ctl.thumbProtocolInfo(o).String(),
+		},
+	)
+	resources = append(resources, captionResources...)
+
 	return upnpav.Item{
 		Object: upnpav.Object{
 			ID:          strconv.Itoa(o.ID),
 			Restricted:  1,
 			ParentID:    strconv.Itoa(parentID),
-			Class:       "object.item.videoItem",
+			Class:       upnpav.ClassVideoItem,
 			Title:       o.Title(),
 			Date:        time.Unix(o.Date, 0).Format("2006-01-02T15:04:05"),
 			AlbumArtURI: &upnpav.AlbumArtURI{Value: thumbURL, Profile: "JPEG_TN"},
 		},
 		Bookmark: bookmark,
-		Res: []upnpav.Resource{
-			{
-				URL:             videoURL,
-				ProtocolInfo:    ctl.videoProtocolInfo(o),
-				Bitrate:         o.Bitrate,
-				SampleFrequency: o.Frequency,
-				Duration:        ffmpeg.DurationToString(time.Duration(o.Duration) * time.Millisecond),
-				Size:            o.FileSize,
-				Resolution:      fmt.Sprintf("%dx%d", o.Width, o.Height),
-				AudioChannels:   o.Channels,
-			},
-			{
-				URL:          thumbURL,
-				ProtocolInfo: ctl.thumbProtocolInfo(o),
-			},
-		},
+		Res:      resources,
+		Captions: captions,
+	}
+}
+
+// videoStream returns the first video stream inside of video file, nil if streams are unknown
+func (ctl *ContentDirectoryController) videoStream(o *backend.Object) *backend.Stream {
+	streams, err := ctl.back.Streams(o)
+	if err != nil {
+		return nil
 	}
+	for i := range streams {
+		if streams[i].Type == backend.StreamVideo && streams[i].File == "" {
+			return &streams[i]
+		}
+	}
+	return nil
 }
 
-func (ctl *ContentDirectoryController) mimeType(o *backend.Object) string {
-	if strings.Contains(o.Format, "matroska") || strings.Contains(o.Format, "avi") {
-		return "video/avi"
+// mediaProfile returns mime type and DLNA profile name of video, mime type may be overridden by client profile,
+// DLNA profile name is not sent with overridden mime type
+func (ctl *ContentDirectoryController) mediaProfile(o *backend.Object, p *profiles.Profile) dlnaprofile.Profile {
+	if mime := p.MimeType(o.Format); mime != "" {
+		return dlnaprofile.Profile{MimeType: mime}
+	}
+	m := dlnaprofile.Media{
+		Format:     o.Format,
+		Ext:        filepath.Ext(o.Path),
+		VideoCodec: o.VideoCodec,
+		AudioCodec: o.AudioCodec,
+		Width:      o.Width,
+		Height:     o.Height,
+		Bitrate:    o.Bitrate,
+	}
+	if s := ctl.videoStream(o); s != nil {
+		m.VideoProfile = s.Profile
 	}
-	return "video/x-msvideo"
+	return dlnaprofile.Detect(m)
 }
 
-func (ctl *ContentDirectoryController) videoProtocolInfo(o *backend.Object) string {
-	return fmt.Sprintf(
-		"http-get:*:%s:DLNA.ORG_OP=01;DLNA.ORG_FLAGS=01700000000000000000000000000000",
-		ctl.mimeType(o),
-	)
+func (ctl *ContentDirectoryController) videoProtocolInfo(o *backend.Object, p *profiles.Profile) dlnaprofile.ProtocolInfo {
+	return dlnaprofile.Video(ctl.mediaProfile(o, p), timeSeekSupported(o), true)
+}
+
+func (ctl *ContentDirectoryController) thumbProtocolInfo(o *backend.Object) dlnaprofile.ProtocolInfo {
+	return dlnaprofile.Thumbnail()
 }
 
-func (ctl *ContentDirectoryController) thumbProtocolInfo(o *backend.Object) string {
-	return "http-get:*:image/jpeg:DLNA.ORG_PN=JPEG_TN;DLNA.ORG_FLAGS=00f00000000000000000000000000000"
+// serveThumbnail serves thumbnail file, scaled to the size from client profile if it is set
+func serveThumbnail(w http.ResponseWriter, r *http.Request, file string, p *profiles.Profile) {
+	if p.ThumbWidth <= 0 || p.ThumbHeight <= 0 {
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		slog.Error("Failed to decode thumbnail", "file", file, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if b := img.Bounds(); b.Dx() != p.ThumbWidth || b.Dy() != p.ThumbHeight {
+		img = imaging.Thumbnail(img, p.ThumbWidth, p.ThumbHeight)
+	}
+	buf := new(bytes.Buffer)
+	if err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		slog.Error("Failed to encode thumbnail", "file", file, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(buf.Bytes())
+	}
 }
@@ -0,0 +1,277 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+type browseResult struct {
+	NumberReturned int
+	TotalMatches   int
+	Containers     []didlObject
+	Items          []didlObject
+}
+
+type didlObject struct {
+	ID       string         `xml:"id,attr"`
+	ParentID string         `xml:"parentID,attr"`
+	Title    string         `xml:"title"`
+	Bookmark string         `xml:"dcmInfo"`
+	Res      []didlResource `xml:"res"`
+}
+
+type didlResource struct {
+	URL          string `xml:",chardata"`
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+}
+
+// titles returns titles of containers followed by titles of items
+func (res *browseResult) titles() []string {
+	list := make([]string, 0)
+	for _, o := range append(slices.Clone(res.Containers), res.Items...) {
+		list = append(list, o.Title)
+	}
+	return list
+}
+
+// browse sends Browse action and parses DIDL-Lite result
+func (ts *testServer) browse(t *testing.T, c testClient, objectID int, flag string, start, count int, sort string) *browseResult {
+	t.Helper()
+	w := ts.soap(t, c, "Browse", fmt.Sprintf(
+		"<ObjectID>%d</ObjectID><BrowseFlag>%s</BrowseFlag><Filter>*</Filter>"+
+			"<StartingIndex>%d</StartingIndex><RequestedCount>%d</RequestedCount><SortCriteria>%s</SortCriteria>",
+		objectID, flag, start, count, sort))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Browse %d: status %d: %s", objectID, w.Code, w.Body.String())
+	}
+
+	var env struct {
+		Response struct {
+			Result         string
+			NumberReturned int
+			TotalMatches   int
+		} `xml:"Body>BrowseResponse"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("Browse %d: %v", objectID, err)
+	}
+	var didl struct {
+		Containers []didlObject `xml:"container"`
+		Items      []didlObject `xml:"item"`
+	}
+	if err := xml.Unmarshal([]byte(env.Response.Result), &didl); err != nil {
+		t.Fatalf("Browse %d: invalid DIDL-Lite: %v\n%s", objectID, err, env.Response.Result)
+	}
+	return &browseResult{
+		NumberReturned: env.Response.NumberReturned,
+		TotalMatches:   env.Response.TotalMatches,
+		Containers:     didl.Containers,
+		Items:          didl.Items,
+	}
+}
+
+// setBookmark sends X_SetBookmark action, position is in units of the client
+func (ts *testServer) setBookmark(t *testing.T, c testClient, objectID int, position int64) {
+	t.Helper()
+	w := ts.soap(t, c, "X_SetBookmark", fmt.Sprintf(
+		"<CategoryType>VIDEO</CategoryType><RID>0</RID><ObjectID>%d</ObjectID><PosSecond>%d</PosSecond>",
+		objectID, position))
+	if w.Code != http.StatusOK {
+		t.Fatalf("X_SetBookmark %d: status %d: %s", objectID, w.Code, w.Body.String())
+	}
+}
+
+var testLibrary = map[string]int64{
+	"Films/a.mkv":   600000,
+	"Films/b.mkv":   300000,
+	"Films/c.mkv":   900000,
+	"Series/s1.mkv": 100000,
+}
+
+func TestBrowseRoot(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+
+	res := ts.browse(t, otherTV, 0, "BrowseDirectChildren", 0, 0, "")
+	want := []string{"Continue Watching", "Recently Added", "Films", "Series"}
+	if !slices.Equal(res.titles(), want) || res.TotalMatches != 4 || res.NumberReturned != 4 {
+		t.Errorf("root: %v (%d of %d); want %v", res.titles(), res.NumberReturned, res.TotalMatches, want)
+	}
+
+	// page crosses border of virtual containers and folders
+	res = ts.browse(t, otherTV, 0, "BrowseDirectChildren", 1, 2, "")
+	want = []string{"Recently Added", "Films"}
+	if !slices.Equal(res.titles(), want) || res.TotalMatches != 4 || res.NumberReturned != 2 {
+		t.Errorf("root page: %v (%d of %d); want %v", res.titles(), res.NumberReturned, res.TotalMatches, want)
+	}
+
+	// page after the last object
+	res = ts.browse(t, otherTV, 0, "BrowseDirectChildren", 10, 2, "")
+	if len(res.titles()) != 0 || res.TotalMatches != 4 {
+		t.Errorf("root page out of range: %v (total %d)", res.titles(), res.TotalMatches)
+	}
+}
+
+func TestBrowsePagingAndSort(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+	films := ts.object(t, "Films").ID
+
+	tests := []struct {
+		start int
+		count int
+		sort  string
+		want  []string
+	}{
+		{0, 0, "", []string{"a", "b", "c"}},
+		{0, 2, "", []string{"a", "b"}},
+		{2, 2, "", []string{"c"}},
+		{0, 0, "-dc:title", []string{"c", "b", "a"}},
+		{0, 0, "+res@duration", []string{"b", "a", "c"}},
+		{1, 1, "-res@duration", []string{"a"}},
+		{0, 0, "+upnp:unknown", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		res := ts.browse(t, otherTV, films, "BrowseDirectChildren", tt.start, tt.count, tt.sort)
+		if !slices.Equal(res.titles(), tt.want) || res.TotalMatches != 3 || res.NumberReturned != len(tt.want) {
+			t.Errorf("start=%d count=%d sort=%q: %v (%d of %d); want %v",
+				tt.start, tt.count, tt.sort, res.titles(), res.NumberReturned, res.TotalMatches, tt.want)
+		}
+		for _, item := range res.Items {
+			if item.ParentID != strconv.Itoa(films) {
+				t.Errorf("%s: parentID %s; want %d", item.Title, item.ParentID, films)
+			}
+		}
+	}
+}
+
+func TestBrowseMetadata(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+	a := ts.object(t, "Films/a.mkv")
+
+	res := ts.browse(t, otherTV, a.ID, "BrowseMetadata", 0, 0, "")
+	if len(res.Items) != 1 || res.Items[0].Title != "a" || res.TotalMatches != 1 {
+		t.Fatalf("metadata: %+v", res)
+	}
+	if res.Items[0].ParentID != strconv.Itoa(ts.object(t, "Films").ID) {
+		t.Errorf("parentID %s", res.Items[0].ParentID)
+	}
+
+	w := ts.soap(t, otherTV, "Browse", "<ObjectID>999999</ObjectID><BrowseFlag>BrowseMetadata</BrowseFlag>")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown object: status %d", w.Code)
+	}
+	w = ts.soap(t, otherTV, "Browse", fmt.Sprintf("<ObjectID>%d</ObjectID><BrowseFlag>Unknown</BrowseFlag>", a.ID))
+	if w.Code == http.StatusOK {
+		t.Errorf("invalid BrowseFlag is accepted")
+	}
+}
+
+func TestBrowseVirtualContainers(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+	a := ts.object(t, "Films/a.mkv").ID
+	c := ts.object(t, "Films/c.mkv").ID
+
+	res := ts.browse(t, otherTV, backend.ContinueWatchingID, "BrowseDirectChildren", 0, 0, "")
+	if res.TotalMatches != 0 || len(res.Items) != 0 {
+		t.Errorf("continue watching is not empty: %v", res.titles())
+	}
+
+	ts.setBookmark(t, otherTV, a, 60000)
+	ts.setBookmark(t, otherTV, c, 120000)
+
+	// the latest bookmark first
+	res = ts.browse(t, otherTV, backend.ContinueWatchingID, "BrowseDirectChildren", 0, 0, "")
+	if want := []string{"c", "a"}; !slices.Equal(res.titles(), want) || res.TotalMatches != 2 {
+		t.Errorf("continue watching: %v (total %d); want %v", res.titles(), res.TotalMatches, want)
+	}
+	for _, item := range res.Items {
+		if item.ParentID != strconv.Itoa(backend.ContinueWatchingID) {
+			t.Errorf("%s: parentID %s; want %d", item.Title, item.ParentID, backend.ContinueWatchingID)
+		}
+	}
+
+	// watched to the end, moves out of container
+	ts.setBookmark(t, otherTV, c, 900000)
+	res = ts.browse(t, otherTV, backend.ContinueWatchingID, "BrowseDirectChildren", 0, 0, "")
+	if want := []string{"a"}; !slices.Equal(res.titles(), want) {
+		t.Errorf("continue watching after end of video: %v; want %v", res.titles(), want)
+	}
+
+	res = ts.browse(t, otherTV, backend.RecentlyAddedID, "BrowseDirectChildren", 0, 2, "")
+	if res.TotalMatches != 4 || res.NumberReturned != 2 {
+		t.Errorf("recently added: %v (%d of %d)", res.titles(), res.NumberReturned, res.TotalMatches)
+	}
+
+	res = ts.browse(t, otherTV, backend.RecentlyAddedID, "BrowseMetadata", 0, 0, "")
+	if want := []string{"Recently Added"}; !slices.Equal(res.titles(), want) {
+		t.Errorf("recently added metadata: %v", res.titles())
+	}
+}
+
+func TestSetBookmarkShared(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+	a := ts.object(t, "Films/a.mkv").ID
+
+	// Samsung sends seconds
+	ts.setBookmark(t, samsungTV, a, 120)
+	if o := ts.object(t, "Films/a.mkv"); o.Bookmark.Int64 != 120000 {
+		t.Errorf("bookmark in seconds is stored as %d ms; want 120000", o.Bookmark.Int64)
+	}
+
+	// bookmark is shared, every client sees it in own units
+	if res := ts.browse(t, samsungTV, a, "BrowseMetadata", 0, 0, ""); res.Items[0].Bookmark != "BM=120" {
+		t.Errorf("samsung bookmark %q; want BM=120", res.Items[0].Bookmark)
+	}
+	if res := ts.browse(t, otherTV, a, "BrowseMetadata", 0, 0, ""); res.Items[0].Bookmark != "BM=120000" {
+		t.Errorf("other bookmark %q; want BM=120000", res.Items[0].Bookmark)
+	}
+
+	// other client sends milliseconds
+	ts.setBookmark(t, otherTV, a, 90500)
+	if o := ts.object(t, "Films/a.mkv"); o.Bookmark.Int64 != 90500 {
+		t.Errorf("bookmark in milliseconds is stored as %d; want 90500", o.Bookmark.Int64)
+	}
+
+	w := ts.soap(t, otherTV, "X_SetBookmark", "<ObjectID>999999</ObjectID><PosSecond>10</PosSecond>")
+	if w.Code == http.StatusOK {
+		t.Errorf("bookmark of unknown object is accepted")
+	}
+}
+
+func TestSetBookmarkPerClient(t *testing.T) {
+	ts := newTestServer(t, testLibrary)
+	ts.BookmarkMode = BookmarkPerClient
+	ts.ClientAliases = []ClientAlias{{Match: "192.168.1.20", Name: "bedroom"}}
+	a := ts.object(t, "Films/a.mkv").ID
+
+	ts.setBookmark(t, samsungTV, a, 120)
+	ts.setBookmark(t, otherTV, a, 30000)
+
+	if res := ts.browse(t, samsungTV, a, "BrowseMetadata", 0, 0, ""); res.Items[0].Bookmark != "BM=120" {
+		t.Errorf("samsung bookmark %q; want BM=120", res.Items[0].Bookmark)
+	}
+	if res := ts.browse(t, otherTV, a, "BrowseMetadata", 0, 0, ""); res.Items[0].Bookmark != "BM=30000" {
+		t.Errorf("other bookmark %q; want BM=30000", res.Items[0].Bookmark)
+	}
+
+	// own bookmarks do not touch shared one
+	if o := ts.object(t, "Films/a.mkv"); o.Bookmark.Valid {
+		t.Errorf("shared bookmark is changed: %v", o.Bookmark)
+	}
+
+	// bookmark of the client is stored by its alias
+	o, err := ts.back.Object(a, "bedroom")
+	if err != nil || o.Bookmark.Int64 != 120000 {
+		t.Errorf("bookmark of alias: %v, %v; want 120000", o.Bookmark, err)
+	}
+
+	res := ts.browse(t, samsungTV, backend.ContinueWatchingID, "BrowseDirectChildren", 0, 0, "")
+	if want := []string{"a"}; !slices.Equal(res.titles(), want) {
+		t.Errorf("continue watching of the client: %v; want %v", res.titles(), want)
+	}
+}
@@ -0,0 +1,110 @@
+package dlna
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+const (
+	// systemUpdateIdProperty is a name of backend property for persisting SystemUpdateID between restarts
+	systemUpdateIdProperty = "cds.SystemUpdateID"
+
+	// eventModerationInterval SystemUpdateID and ContainerUpdateIDs are moderated variables,
+	// events are sent not often than once per 2 seconds (ContentDirectory:1, section 2.5.20)
+	eventModerationInterval = 2 * time.Second
+)
+
+// loadSystemUpdateId restores SystemUpdateID saved by previous run, 1 for the first run
+func (ctl *ContentDirectoryController) loadSystemUpdateId() {
+	ctl.systemUpdateId = 1
+	value, err := ctl.back.Property(systemUpdateIdProperty)
+	if err != nil {
+		if !errors.Is(err, backend.ErrNoRows) {
+			slog.Error("failed to load SystemUpdateID", "err", err)
+		}
+		return
+	}
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil && id > 0 {
+		ctl.systemUpdateId = uint32(id)
+	}
+}
+
+// updateId returns current SystemUpdateID
+func (ctl *ContentDirectoryController) updateId() string {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return strconv.FormatUint(uint64(ctl.systemUpdateId), 10)
+}
+
+// containerUpdateId returns update id of container, SystemUpdateID when container was not changed since start
+func (ctl *ContentDirectoryController) containerUpdateId(id int) string {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if updateId, ok := ctl.containerUpdateIds[id]; ok {
+		return strconv.FormatUint(uint64(updateId), 10)
+	}
+	return strconv.FormatUint(uint64(ctl.systemUpdateId), 10)
+}
+
+// onLibraryChange collects changed containers, event is sent after moderation interval
+func (ctl *ContentDirectoryController) onLibraryChange(containerIds []int) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	for _, id := range containerIds {
+		ctl.changedContainers[id] = struct{}{}
+	}
+	if ctl.eventTimer == nil {
+		ctl.eventTimer = time.AfterFunc(eventModerationInterval, ctl.sendChangeEvent)
+	}
+}
+
+// sendChangeEvent increments SystemUpdateID, update ids of changed containers and notifies all subscribers
+func (ctl *ContentDirectoryController) sendChangeEvent() {
+	// one event at a time, keeps SEQ of subscribers in order
+	ctl.eventMu.Lock()
+	defer ctl.eventMu.Unlock()
+
+	ctl.mu.Lock()
+	ctl.eventTimer = nil
+	if len(ctl.changedContainers) == 0 {
+		ctl.mu.Unlock()
+		return
+	}
+
+	ctl.systemUpdateId++
+	if ctl.systemUpdateId == 0 {
+		ctl.systemUpdateId++
+	}
+	updateId := strconv.FormatUint(uint64(ctl.systemUpdateId), 10)
+
+	ids := make([]int, 0, len(ctl.changedContainers))
+	for id := range ctl.changedContainers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	pairs := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		ctl.containerUpdateIds[id] = ctl.systemUpdateId
+		pairs = append(pairs, strconv.Itoa(id), updateId)
+	}
+	clear(ctl.changedContainers)
+	ctl.mu.Unlock()
+
+	if err := ctl.back.SetProperty(systemUpdateIdProperty, updateId); err != nil {
+		slog.Error("failed to save SystemUpdateID", "err", err)
+	}
+
+	slog.Debug("library changed", "SystemUpdateID", updateId, "containers", ids)
+	ctl.eventManager.NotifyAll(map[string]string{
+		"SystemUpdateID":     updateId,
+		"ContainerUpdateIDs": strings.Join(pairs, ","),
+	})
+}
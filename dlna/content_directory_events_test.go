@@ -0,0 +1,80 @@
+package dlna
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLibraryChangeEvents(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"Films/a.mkv": 60000, "Series/b.mkv": 60000})
+	films, series := ts.object(t, "Films"), ts.object(t, "Series")
+	ctl, err := NewContentDirectoryController(ts.Server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make([]string, 0)
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		events = append(events, string(body))
+	}))
+	defer subscriber.Close()
+	if res := ctl.eventManager.Subscribe("", "upnp:event", "<"+subscriber.URL+">", "Second-300"); !res.Success {
+		t.Fatalf("subscribe: status %d", res.StatusCode)
+	}
+
+	if id := ctl.updateId(); id != "1" {
+		t.Fatalf("initial SystemUpdateID %s; want 1", id)
+	}
+
+	// changes are moderated: collected and sent by one event
+	ctl.onLibraryChange([]int{films.ID})
+	ctl.onLibraryChange([]int{0, films.ID})
+	ctl.mu.Lock()
+	timer := ctl.eventTimer
+	ctl.mu.Unlock()
+	if timer == nil || !timer.Stop() {
+		t.Fatal("event is not scheduled")
+	}
+	if id := ctl.updateId(); id != "1" || len(events) != 0 {
+		t.Fatalf("SystemUpdateID %s and %d events before moderation interval; want 1 and no events", id, len(events))
+	}
+	ctl.sendChangeEvent()
+
+	if id := ctl.updateId(); id != "2" {
+		t.Errorf("SystemUpdateID %s; want 2", id)
+	}
+	if len(events) != 1 || !strings.Contains(events[0], "<SystemUpdateID>2</SystemUpdateID>") ||
+		!strings.Contains(events[0], fmt.Sprintf("<ContainerUpdateIDs>0,2,%d,2</ContainerUpdateIDs>", films.ID)) {
+		t.Errorf("events %q; want one event with SystemUpdateID 2 and containers 0 and %d", events, films.ID)
+	}
+
+	// the next change bumps only changed container
+	ctl.onLibraryChange([]int{series.ID})
+	ctl.mu.Lock()
+	ctl.eventTimer.Stop()
+	ctl.mu.Unlock()
+	ctl.sendChangeEvent()
+	if got := []string{ctl.containerUpdateId(0), ctl.containerUpdateId(films.ID), ctl.containerUpdateId(series.ID), ctl.updateId()}; strings.Join(got, ",") != "2,2,3,3" {
+		t.Errorf("update ids of root, films, series and system %v; want [2 2 3 3]", got)
+	}
+
+	// nothing changed, no event
+	ctl.sendChangeEvent()
+	if ctl.updateId() != "3" || len(events) != 2 {
+		t.Errorf("SystemUpdateID %s, %d events after empty change; want 3 and 2 events", ctl.updateId(), len(events))
+	}
+
+	// SystemUpdateID is persisted
+	restarted, err := NewContentDirectoryController(ts.Server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := restarted.updateId(); id != "3" {
+		t.Errorf("SystemUpdateID after restart %s; want 3", id)
+	}
+}
@@ -0,0 +1,143 @@
+package dlna
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/dlna/profiles"
+	"github.com/szonov/godlna/pkg/subtitles"
+	"github.com/szonov/godlna/pkg/upnpav"
+)
+
+// subtitleMimeTypes content types of external subtitles by extension
+var subtitleMimeTypes = map[string]string{
+	"srt":  "text/srt",
+	"smi":  "smi/caption",
+	"sami": "smi/caption",
+	"ass":  "text/x-ass",
+	"ssa":  "text/x-ssa",
+	"vtt":  "text/vtt",
+	"sub":  "text/x-microdvd",
+}
+
+// HandleSubtitleURL serves external subtitles, url format is /ct/s/{objectId}-{streamIndex}.{ext},
+// srt subtitles are converted to SAMI when ext is "smi"
+func (ctl *ContentDirectoryController) HandleSubtitleURL(w http.ResponseWriter, r *http.Request) {
+	obj := r.PathValue("obj")
+	ext := filepath.Ext(obj)
+	oid, sid, _ := strings.Cut(strings.TrimSuffix(obj, ext), "-")
+
+	objectID, err1 := strconv.Atoi(oid)
+	index, err2 := strconv.Atoi(sid)
+	if err1 != nil || err2 != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	o, err := ctl.back.Object(objectID, "")
+	if err != nil {
+		slog.Error("Object not found", "objectID", objectID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s, err := ctl.back.Subtitle(o, index)
+	if err != nil {
+		slog.Error("Subtitle not found", "objectID", objectID, "index", index)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ext = strings.TrimPrefix(ext, ".")
+	if !subtitleExtValid(*s, ext) {
+		slog.Error("Subtitle format not supported", "objectID", objectID, "index", index, "ext", ext)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("EXT", "")
+	w.Header().Set("transferMode.dlna.org", "Background")
+	w.Header().Set("Content-Type", subtitleMimeTypes[ext])
+
+	if ext == "smi" && s.Codec == "srt" {
+		f, err := os.Open(s.File)
+		if err != nil {
+			slog.Error("Failed to open subtitle", "file", s.File, "err", err.Error())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer func() { _ = f.Close() }()
+		if r.Method == http.MethodHead {
+			return
+		}
+		if err = subtitles.SrtToSami(f, w, s.Language); err != nil {
+			slog.Error("Failed to convert subtitle", "file", s.File, "err", err.Error())
+		}
+		return
+	}
+
+	http.ServeFile(w, r, s.File)
+}
+
+// subtitleExt returns extension of subtitle url for the client, old Samsung TVs get SAMI instead of srt
+func subtitleExt(s backend.Stream, p *profiles.Profile) string {
+	if s.Codec == "srt" && p.SamiCaptions {
+		return "smi"
+	}
+	if s.Codec == "sami" {
+		return "smi"
+	}
+	return s.Codec
+}
+
+// subtitleExtValid reports whether subtitle can be served with extension, the file itself or srt converted to SAMI
+func subtitleExtValid(s backend.Stream, ext string) bool {
+	switch ext {
+	case s.Codec:
+		return true
+	case "smi":
+		return s.Codec == "srt" || s.Codec == "sami"
+	}
+	return false
+}
+
+func subtitleURL(o *backend.Object, s backend.Stream, r *http.Request, p *profiles.Profile) string {
+	return fmt.Sprintf("http://%s/ct/s/%d-%d.%s", r.Host, o.ID, s.Index, subtitleExt(s, p))
+}
+
+// captions returns links to external subtitles of video for CaptionInfoEx elements and res elements
+func (ctl *ContentDirectoryController) captions(o *backend.Object, r *http.Request, p *profiles.Profile) ([]upnpav.CaptionInfo, []upnpav.Resource) {
+	list, err := ctl.back.Subtitles(o)
+	if err != nil || len(list) == 0 {
+		return nil, nil
+	}
+	captions := make([]upnpav.CaptionInfo, 0, len(list))
+	resources := make([]upnpav.Resource, 0, len(list))
+	for _, s := range list {
+		ext := subtitleExt(s, p)
+		url := subtitleURL(o, s, r, p)
+		captions = append(captions, upnpav.CaptionInfo{Type: ext, URL: url})
+		resources = append(resources, upnpav.Resource{
+			URL:          url,
+			ProtocolInfo: "http-get:*:" + subtitleMimeTypes[ext] + ":*",
+		})
+	}
+	return captions, resources
+}
+
+// setCaptionInfoHeader answers to "getCaptionInfo.sec" request header of Samsung TVs with url of the first subtitle
+func (ctl *ContentDirectoryController) setCaptionInfoHeader(w http.ResponseWriter, r *http.Request, o *backend.Object, p *profiles.Profile) {
+	if r.Header.Get("getCaptionInfo.sec") == "" {
+		return
+	}
+	list, err := ctl.back.Subtitles(o)
+	if err != nil || len(list) == 0 {
+		return
+	}
+	w.Header().Set("CaptionInfo.sec", subtitleURL(o, list[0], r, p))
+}
@@ -0,0 +1,57 @@
+package dlna
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+func TestHandleSubtitleURL(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000})
+	o := ts.object(t, "a.mkv")
+	srt, ass := filepath.Join(ts.root, "a.srt"), filepath.Join(ts.root, "a.ass")
+	for _, file := range []string{srt, ass} {
+		if err := os.WriteFile(file, []byte("1\n00:00:01,000 --> 00:00:02,000\nHello\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ts.driver.SetStreams(o.ID, []backend.Stream{
+		{Index: 0, Type: backend.StreamVideo, Codec: "h264"},
+		{Index: 1, Type: backend.StreamSubtitle, Codec: "srt", File: srt},
+		{Index: 2, Type: backend.StreamSubtitle, Codec: "ass", File: ass},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		index       int
+		ext         string
+		status      int
+		contentType string
+	}{
+		{1, "srt", http.StatusOK, "text/srt"},
+		{1, "smi", http.StatusOK, "smi/caption"},
+		{1, "ass", http.StatusNotFound, ""},
+		{1, "vtt", http.StatusNotFound, ""},
+		{1, "", http.StatusNotFound, ""},
+		{2, "ass", http.StatusOK, "text/x-ass"},
+		{2, "smi", http.StatusNotFound, ""},
+		{2, "srt", http.StatusNotFound, ""},
+		{0, "h264", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		target := fmt.Sprintf("/ct/s/%d-%d.%s", o.ID, tt.index, tt.ext)
+		w := ts.do(http.MethodGet, target, otherTV.userAgent, "", nil)
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status %d; want %d", target, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK && w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("GET %s: Content-Type %q; want %q", target, w.Header().Get("Content-Type"), tt.contentType)
+		}
+	}
+}
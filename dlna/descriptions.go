@@ -8,6 +8,12 @@ import (
 const (
 	ContentDirectoryServiceType = "urn:schemas-upnp-org:service:ContentDirectory:1"
 	ContentDirectoryServiceId   = "urn:upnp-org:serviceId:ContentDirectory"
+
+	ConnectionManagerServiceType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+	ConnectionManagerServiceId   = "urn:upnp-org:serviceId:ConnectionManager"
+
+	MediaReceiverRegistrarServiceType = "urn:microsoft.com:service:X_MS_MediaReceiverRegistrar:1"
+	MediaReceiverRegistrarServiceId   = "urn:microsoft.com:serviceId:X_MS_MediaReceiverRegistrar"
 )
 
 func makeDeviceDescription(friendlyName string, listenAddress string) *device.Description {
@@ -33,6 +39,20 @@ func makeDeviceDescription(friendlyName string, listenAddress string) *device.De
 					ControlURL:  "/cds/ctl",
 					EventSubURL: "/cds/evt",
 				},
+				{
+					ServiceType: ConnectionManagerServiceType,
+					ServiceId:   ConnectionManagerServiceId,
+					SCPDURL:     "/cm/desc.xml",
+					ControlURL:  "/cm/ctl",
+					EventSubURL: "/cm/evt",
+				},
+				{
+					ServiceType: MediaReceiverRegistrarServiceType,
+					ServiceId:   MediaReceiverRegistrarServiceId,
+					SCPDURL:     "/mrr/desc.xml",
+					ControlURL:  "/mrr/ctl",
+					EventSubURL: "/mrr/evt",
+				},
 			},
 			PresentationURL: "http://" + listenAddress + "/",
 			VendorXML: device.NewVendorXML().
@@ -71,18 +91,18 @@ func makeContentDirectoryServiceDescription() *scpd.Document {
 			scpd.OUT("TotalMatches", "A_ARG_TYPE_Count"),
 			scpd.OUT("UpdateID", "A_ARG_TYPE_UpdateID"),
 		).
-		//Action("Search",
-		//	scpd.IN("ContainerID", "A_ARG_TYPE_ObjectID"),
-		//	scpd.IN("SearchCriteria", "A_ARG_TYPE_SearchCriteria"),
-		//	scpd.IN("Filter", "A_ARG_TYPE_Filter"),
-		//	scpd.IN("StartingIndex", "A_ARG_TYPE_Index"),
-		//	scpd.IN("RequestedCount", "A_ARG_TYPE_Count"),
-		//	scpd.IN("SortCriteria", "A_ARG_TYPE_SortCriteria"),
-		//	scpd.OUT("Result", "A_ARG_TYPE_Result"),
-		//	scpd.OUT("NumberReturned", "A_ARG_TYPE_Count"),
-		//	scpd.OUT("TotalMatches", "A_ARG_TYPE_Count"),
-		//	scpd.OUT("UpdateID", "A_ARG_TYPE_UpdateID"),
-		//).
+		Action("Search",
+			scpd.IN("ContainerID", "A_ARG_TYPE_ObjectID"),
+			scpd.IN("SearchCriteria", "A_ARG_TYPE_SearchCriteria"),
+			scpd.IN("Filter", "A_ARG_TYPE_Filter"),
+			scpd.IN("StartingIndex", "A_ARG_TYPE_Index"),
+			scpd.IN("RequestedCount", "A_ARG_TYPE_Count"),
+			scpd.IN("SortCriteria", "A_ARG_TYPE_SortCriteria"),
+			scpd.OUT("Result", "A_ARG_TYPE_Result"),
+			scpd.OUT("NumberReturned", "A_ARG_TYPE_Count"),
+			scpd.OUT("TotalMatches", "A_ARG_TYPE_Count"),
+			scpd.OUT("UpdateID", "A_ARG_TYPE_UpdateID"),
+		).
 		//Action("CreateObject",
 		//	scpd.IN("ContainerID", "A_ARG_TYPE_ObjectID"),
 		//	scpd.IN("Elements", "A_ARG_TYPE_Result"),
@@ -136,7 +156,7 @@ func makeContentDirectoryServiceDescription() *scpd.Document {
 		//Variable("TransferIDs", "string", scpd.Events()).
 		Variable("A_ARG_TYPE_ObjectID", "string").
 		Variable("A_ARG_TYPE_Result", "string").
-		//Variable("A_ARG_TYPE_SearchCriteria", "string").
+		Variable("A_ARG_TYPE_SearchCriteria", "string").
 		Variable("A_ARG_TYPE_BrowseFlag", "string",
 			scpd.Only("BrowseMetadata", "BrowseDirectChildren"),
 		).
@@ -162,3 +182,62 @@ func makeContentDirectoryServiceDescription() *scpd.Document {
 		Variable("X_ARG_TYPE_RID", "ui4").
 		Variable("X_ARG_TYPE_PosSec", "ui4")
 }
+
+func makeConnectionManagerServiceDescription() *scpd.Document {
+	return scpd.NewDocument(1, 0).
+		Action("GetProtocolInfo",
+			scpd.OUT("Source", "SourceProtocolInfo"),
+			scpd.OUT("Sink", "SinkProtocolInfo"),
+		).
+		Action("GetCurrentConnectionIDs",
+			scpd.OUT("ConnectionIDs", "CurrentConnectionIDs"),
+		).
+		Action("GetCurrentConnectionInfo",
+			scpd.IN("ConnectionID", "A_ARG_TYPE_ConnectionID"),
+			scpd.OUT("RcsID", "A_ARG_TYPE_RcsID"),
+			scpd.OUT("AVTransportID", "A_ARG_TYPE_AVTransportID"),
+			scpd.OUT("ProtocolInfo", "A_ARG_TYPE_ProtocolInfo"),
+			scpd.OUT("PeerConnectionManager", "A_ARG_TYPE_ConnectionManager"),
+			scpd.OUT("PeerConnectionID", "A_ARG_TYPE_ConnectionID"),
+			scpd.OUT("Direction", "A_ARG_TYPE_Direction"),
+			scpd.OUT("Status", "A_ARG_TYPE_ConnectionStatus"),
+		).
+		Variable("SourceProtocolInfo", "string", scpd.Events()).
+		Variable("SinkProtocolInfo", "string", scpd.Events()).
+		Variable("CurrentConnectionIDs", "string", scpd.Events()).
+		Variable("A_ARG_TYPE_ConnectionStatus", "string",
+			scpd.Only("OK", "ContentFormatMismatch", "InsufficientBandwidth", "UnreliableChannel", "Unknown"),
+		).
+		Variable("A_ARG_TYPE_ConnectionManager", "string").
+		Variable("A_ARG_TYPE_Direction", "string",
+			scpd.Only("Input", "Output"),
+		).
+		Variable("A_ARG_TYPE_ProtocolInfo", "string").
+		Variable("A_ARG_TYPE_ConnectionID", "i4").
+		Variable("A_ARG_TYPE_AVTransportID", "i4").
+		Variable("A_ARG_TYPE_RcsID", "i4")
+}
+
+func makeMediaReceiverRegistrarServiceDescription() *scpd.Document {
+	return scpd.NewDocument(1, 0).
+		Action("IsAuthorized",
+			scpd.IN("DeviceID", "A_ARG_TYPE_DeviceID"),
+			scpd.OUT("Result", "A_ARG_TYPE_Result"),
+		).
+		Action("RegisterDevice",
+			scpd.IN("RegistrationReqMsg", "A_ARG_TYPE_RegistrationReqMsg"),
+			scpd.OUT("RegistrationRespMsg", "A_ARG_TYPE_RegistrationRespMsg"),
+		).
+		Action("IsValidated",
+			scpd.IN("DeviceID", "A_ARG_TYPE_DeviceID"),
+			scpd.OUT("Result", "A_ARG_TYPE_Result"),
+		).
+		Variable("A_ARG_TYPE_DeviceID", "string").
+		Variable("A_ARG_TYPE_Result", "int").
+		Variable("A_ARG_TYPE_RegistrationReqMsg", "bin.base64").
+		Variable("A_ARG_TYPE_RegistrationRespMsg", "bin.base64").
+		Variable("AuthorizationGrantedUpdateID", "ui4", scpd.Events()).
+		Variable("AuthorizationDeniedUpdateID", "ui4", scpd.Events()).
+		Variable("ValidationSucceededUpdateID", "ui4", scpd.Events()).
+		Variable("ValidationRevokedUpdateID", "ui4", scpd.Events())
+}
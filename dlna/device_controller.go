@@ -1,11 +1,14 @@
 package dlna
 
 import (
+	"bytes"
 	"embed"
 	"encoding/xml"
 	"fmt"
+	"html/template"
 	"io/fs"
 	"net/http"
+	"sync"
 
 	"github.com/szonov/godlna/pkg/soap"
 )
@@ -13,25 +16,28 @@ import (
 //go:embed icons
 var embedIconsFS embed.FS
 
+// embedWebFS is web UI of the library, index.html is a template, the rest is served as is
+//
+//go:embed web
+var embedWebFS embed.FS
+
 type DeviceController struct {
+	srv            *Server
+	mu             sync.RWMutex
 	deviceDescXML  []byte
 	indexHtml      []byte
+	indexTpl       *template.Template
 	iconFileServer http.Handler
+	webFileServer  http.Handler
 }
 
 func NewDeviceController(srv *Server) (*DeviceController, error) {
 	var err error
-	ctl := &DeviceController{}
-	desc := srv.DeviceDescription
-	if desc == nil {
+	ctl := &DeviceController{srv: srv}
+	if srv.DeviceDescription == nil {
 		return ctl, fmt.Errorf("device description can't be nil")
 	}
 
-	if ctl.deviceDescXML, err = xml.Marshal(desc); err != nil {
-		return ctl, fmt.Errorf("marshal device desc error: '%s'", err.Error())
-	}
-	ctl.deviceDescXML = append([]byte(xml.Header), ctl.deviceDescXML...)
-
 	// embed file system with icons
 	var sub fs.FS
 	if sub, err = fs.Sub(embedIconsFS, "icons"); err != nil {
@@ -39,16 +45,52 @@ func NewDeviceController(srv *Server) (*DeviceController, error) {
 	}
 	ctl.iconFileServer = http.StripPrefix("/device/icons/", http.FileServer(http.FS(sub)))
 
-	// index page - simple text
-	ctl.indexHtml = []byte(fmt.Sprintf(`[%s] Video DLNA Server`, desc.Device.FriendlyName))
+	// embed file system with web UI
+	if sub, err = fs.Sub(embedWebFS, "web"); err != nil {
+		return ctl, fmt.Errorf("failed to load embedded web fs: %w", err)
+	}
+	ctl.webFileServer = http.StripPrefix("/web/", http.FileServer(http.FS(sub)))
+
+	// index page - web UI of the library
+	if ctl.indexTpl, err = template.ParseFS(sub, "index.html"); err != nil {
+		return ctl, fmt.Errorf("failed to parse index.html: %w", err)
+	}
 
-	return ctl, nil
+	return ctl, ctl.render()
+}
+
+// render makes device description and index page from actual device description of the server
+func (ctl *DeviceController) render() error {
+	desc := ctl.srv.DeviceDescription
+
+	descXML, err := xml.Marshal(desc)
+	if err != nil {
+		return fmt.Errorf("marshal device desc error: '%s'", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err = ctl.indexTpl.Execute(&buf, map[string]any{
+		"FriendlyName": desc.Device.FriendlyName,
+		"PerClient":    ctl.srv.BookmarkMode == BookmarkPerClient,
+	}); err != nil {
+		return fmt.Errorf("failed to render index.html: %w", err)
+	}
+
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	ctl.deviceDescXML = append([]byte(xml.Header), descXML...)
+	ctl.indexHtml = buf.Bytes()
+	return nil
 }
 
 func (ctl *DeviceController) HandleIndexURL(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/" {
 		if r.Method == http.MethodGet || r.Method == http.MethodHead {
-			_, _ = w.Write(ctl.indexHtml)
+			ctl.mu.RLock()
+			indexHtml := ctl.indexHtml
+			ctl.mu.RUnlock()
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(indexHtml)
 		} else {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
@@ -59,7 +101,10 @@ func (ctl *DeviceController) HandleIndexURL(w http.ResponseWriter, r *http.Reque
 
 func (ctl *DeviceController) HandleDescriptionURL(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet || r.Method == http.MethodHead {
-		soap.SendXML(ctl.deviceDescXML, w)
+		ctl.mu.RLock()
+		deviceDescXML := ctl.deviceDescXML
+		ctl.mu.RUnlock()
+		soap.SendXML(deviceDescXML, w)
 	} else {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
@@ -72,3 +117,12 @@ func (ctl *DeviceController) HandleIcons(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+// HandleWeb serves static files (scripts, styles) of the web UI
+func (ctl *DeviceController) HandleWeb(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		ctl.webFileServer.ServeHTTP(w, r)
+	} else {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,113 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/szonov/godlna/dlna/profiles"
+	"github.com/szonov/godlna/pkg/soap"
+	"github.com/szonov/godlna/pkg/upnp/events"
+)
+
+type (
+	// MediaReceiverRegistrarController implements X_MS_MediaReceiverRegistrar service required by Xbox and
+	// Windows Media Player, all devices are authorized and validated
+	MediaReceiverRegistrarController struct {
+		serviceDescriptionXML []byte
+		eventManager          *events.Manager
+	}
+	argOutRegistrarResult struct {
+		Result int
+	}
+	argOutRegisterDevice struct {
+		RegistrationRespMsg string
+	}
+)
+
+func NewMediaReceiverRegistrarController(srv *Server) (*MediaReceiverRegistrarController, error) {
+	var err error
+	ctl := &MediaReceiverRegistrarController{
+		eventManager: events.NewManager().WithService("X_MS_MediaReceiverRegistrar"),
+	}
+
+	if ctl.serviceDescriptionXML, err = xml.Marshal(makeMediaReceiverRegistrarServiceDescription()); err != nil {
+		return ctl, err
+	}
+	ctl.serviceDescriptionXML = append([]byte(xml.Header), ctl.serviceDescriptionXML...)
+
+	return ctl, nil
+}
+
+func (ctl *MediaReceiverRegistrarController) HandleSCPDURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		soap.SendXML(ctl.serviceDescriptionXML, w)
+	} else {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (ctl *MediaReceiverRegistrarController) HandleEventSubURL(w http.ResponseWriter, r *http.Request) {
+	ctl.eventManager.HandleEventSubURL(w, r, func() map[string]string {
+		return map[string]string{
+			"AuthorizationGrantedUpdateID": "0",
+			"AuthorizationDeniedUpdateID":  "0",
+			"ValidationSucceededUpdateID":  "0",
+			"ValidationRevokedUpdateID":    "0",
+		}
+	})
+}
+
+func (ctl *MediaReceiverRegistrarController) HandleControlURL(w http.ResponseWriter, r *http.Request) {
+	// Control URL works only with POST http method
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// resolve current action name from http header
+	soapAction := soap.DetectAction(r.Header.Get("SoapAction"))
+	if soapAction == nil || soapAction.ServiceType != MediaReceiverRegistrarServiceType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch soapAction.Name {
+	case "IsAuthorized", "IsValidated":
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, &argOutRegistrarResult{Result: 1}, w)
+
+	case "RegisterDevice":
+		w.Header().Set("EXT", "")
+		soap.SendActionResponse(soapAction, &argOutRegisterDevice{}, w)
+
+	default:
+		err := fmt.Errorf("unknown action '%s'", soapAction.Name)
+		soap.SendUPnPError(soap.InvalidActionErrorCode, err.Error(), w, http.StatusUnauthorized)
+	}
+}
+
+// msContainerAliases ids of predefined containers requested by Windows Media clients (Xbox, WMP),
+// video containers are mapped to the root, music and pictures containers are always empty (-1)
+var msContainerAliases = map[string]int{
+	// videos: all, genre, actor, album (folders), playlists
+	"15": 0, "8": 0, "9": 0, "A": 0, "10": 0, "11": 0,
+	// music
+	"1": -1, "4": -1, "5": -1, "6": -1, "7": -1, "F": -1,
+	// pictures
+	"3": -1, "B": -1, "C": -1, "D": -1, "16": -1,
+}
+
+// resolveContainerID converts ContainerID argument of Search to object id, Windows Media clients search
+// in predefined containers, aliases are applied only here: real object ids share the same numbers
+// and Browse of them should not be intercepted, exists is false for containers which are always empty
+func resolveContainerID(id string, p *profiles.Profile) (objectID int, exists bool, err error) {
+	if p.WindowsMedia {
+		if alias, ok := msContainerAliases[id]; ok {
+			return alias, alias >= 0, nil
+		}
+	}
+	objectID, err = strconv.Atoi(id)
+	return objectID, true, err
+}
@@ -0,0 +1,121 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/profiles"
+)
+
+var xboxTV = testClient{remoteAddr: "192.168.1.40:40000", userAgent: "Xbox/2.0.4548.0 UPnP/1.0 Xbox/2.0.4548.0"}
+
+// search sends Search action (criteria is escaped) and returns titles of found objects
+func (ts *testServer) search(t *testing.T, c testClient, containerID string, criteria string) []string {
+	t.Helper()
+	w := ts.soap(t, c, "Search", fmt.Sprintf(
+		"<ContainerID>%s</ContainerID><SearchCriteria>%s</SearchCriteria><Filter>*</Filter>"+
+			"<StartingIndex>0</StartingIndex><RequestedCount>0</RequestedCount><SortCriteria>+dc:title</SortCriteria>",
+		containerID, html.EscapeString(criteria)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Search %s: status %d: %s", containerID, w.Code, w.Body.String())
+	}
+	var env struct {
+		Result string `xml:"Body>SearchResponse>Result"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("Search %s: %v", containerID, err)
+	}
+	var didl struct {
+		Objects []didlObject `xml:",any"`
+	}
+	if err := xml.Unmarshal([]byte(env.Result), &didl); err != nil {
+		t.Fatalf("Search %s: invalid DIDL-Lite: %v", containerID, err)
+	}
+	titles := make([]string, 0)
+	for _, o := range didl.Objects {
+		titles = append(titles, o.Title)
+	}
+	return titles
+}
+
+func TestResolveContainerID(t *testing.T) {
+	wmp := &profiles.Profile{WindowsMedia: true}
+	other := &profiles.Profile{}
+
+	tests := []struct {
+		id      string
+		p       *profiles.Profile
+		want    int
+		exists  bool
+		invalid bool
+	}{
+		{"0", wmp, 0, true, false},
+		{"15", wmp, 0, true, false},
+		{"8", wmp, 0, true, false},
+		{"A", wmp, 0, true, false},
+		{"1", wmp, -1, false, false},
+		{"F", wmp, -1, false, false},
+		{"16", wmp, -1, false, false},
+		{"25", wmp, 25, true, false},
+		{"8", other, 8, true, false},
+		{"1", other, 1, true, false},
+		{"A", other, 0, true, true},
+	}
+	for _, tt := range tests {
+		got, exists, err := resolveContainerID(tt.id, tt.p)
+		if (err != nil) != tt.invalid {
+			t.Errorf("resolveContainerID(%q, windows media %v): %v", tt.id, tt.p.WindowsMedia, err)
+			continue
+		}
+		if !tt.invalid && (got != tt.want || exists != tt.exists) {
+			t.Errorf("resolveContainerID(%q, windows media %v) = %d, %v; want %d, %v",
+				tt.id, tt.p.WindowsMedia, got, exists, tt.want, tt.exists)
+		}
+	}
+}
+
+func TestWindowsMediaBrowseRealIDs(t *testing.T) {
+	// roots of multi-root library are the first folders in database
+	ts := newTestServerRoots(t, map[string]int64{"Films/a.mkv": 60000, "Series/s1.mkv": 60000}, true)
+	films := ts.object(t, "Films")
+	if films.ID != 1 {
+		t.Fatalf("first root has id %d; want 1", films.ID)
+	}
+
+	res := ts.browse(t, xboxTV, 0, "BrowseDirectChildren", 0, 0, "")
+	if want := []string{"Continue Watching", "Recently Added", "Films", "Series"}; !slices.Equal(res.titles(), want) {
+		t.Errorf("root: %v; want %v", res.titles(), want)
+	}
+
+	// real folder with id of music alias is browsable
+	res = ts.browse(t, xboxTV, films.ID, "BrowseDirectChildren", 0, 0, "")
+	if want := []string{"a"}; !slices.Equal(res.titles(), want) {
+		t.Errorf("browse %d: %v; want %v", films.ID, res.titles(), want)
+	}
+	res = ts.browse(t, xboxTV, films.ID, "BrowseMetadata", 0, 0, "")
+	if len(res.Containers) != 1 || res.Containers[0].ID != strconv.Itoa(films.ID) {
+		t.Errorf("metadata of %d: %+v", films.ID, res.Containers)
+	}
+
+	// id of video alias is not the root for Browse
+	w := ts.soap(t, xboxTV, "Browse", "<ObjectID>8</ObjectID><BrowseFlag>BrowseDirectChildren</BrowseFlag>")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("browse of unknown object 8: status %d", w.Code)
+	}
+
+	// aliases are applied to Search
+	if got := ts.search(t, xboxTV, "8", `upnp:class derivedfrom "object.item.videoItem"`); !slices.Equal(got, []string{"a", "s1"}) {
+		t.Errorf("search in video alias: %v", got)
+	}
+	if got := ts.search(t, xboxTV, "1", `upnp:class derivedfrom "object.item.videoItem"`); len(got) != 0 {
+		t.Errorf("search in music alias: %v", got)
+	}
+	if got := ts.search(t, otherTV, "1", `upnp:class derivedfrom "object.item.videoItem"`); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("search in folder 1 by other client: %v", got)
+	}
+}
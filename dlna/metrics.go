@@ -0,0 +1,124 @@
+package dlna
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szonov/godlna/dlna/profiles"
+	"github.com/szonov/godlna/pkg/metrics"
+	"github.com/szonov/godlna/pkg/soap"
+)
+
+var (
+	soapRequests = metrics.NewCounter("godlna_soap_requests_total",
+		"SOAP actions handled, by service, action, client (alias or profile) and http status.",
+		"service", "action", "client", "status")
+
+	soapDuration = metrics.NewHistogram("godlna_soap_request_duration_seconds",
+		"Duration of SOAP actions, by service, action and client (alias or profile).",
+		metrics.DefBuckets, "service", "action", "client")
+
+	contentBytes = metrics.NewCounter("godlna_content_bytes_total",
+		"Bytes sent to the clients by content URLs, by kind (video, thumbnail, transcode).",
+		"kind")
+)
+
+// statusResponseWriter remembers http status of the response
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingResponseWriter adds amount of written bytes to godlna_content_bytes_total
+type countingResponseWriter struct {
+	http.ResponseWriter
+	kind string
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	contentBytes.Add(float64(n), w.kind)
+	return n, err
+}
+
+// ReadFrom keeps sendfile optimization of http.ServeFile
+func (w *countingResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	contentBytes.Add(float64(n), w.kind)
+	return n, err
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func countContent(w http.ResponseWriter, kind string) http.ResponseWriter {
+	return &countingResponseWriter{ResponseWriter: w, kind: kind}
+}
+
+// measureSOAP collects metrics of SOAP action requests (control URLs)
+func (s *Server) measureSOAP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		soapAction := soap.DetectAction(r.Header.Get("SoapAction"))
+		if r.Method != http.MethodPost || soapAction == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		service := soapAction.ServiceType
+		// urn:schemas-upnp-org:service:ContentDirectory:1 -> ContentDirectory
+		if parts := strings.Split(service, ":"); len(parts) >= 2 {
+			service = parts[len(parts)-2]
+		}
+		client := s.metricsClient(r)
+
+		sw := &statusResponseWriter{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		soapDuration.ObserveSince(start, service, soapAction.Name, client)
+		soapRequests.Inc(service, soapAction.Name, client, strconv.Itoa(sw.status))
+	}
+}
+
+// metricsClient returns client label of metrics: alias of the client, name of its profile or "other",
+// User-Agent and IP are not used to keep amount of series bounded
+func (s *Server) metricsClient(r *http.Request) string {
+	if name, ok := clientAlias(r, s.ClientAliases); ok {
+		return name
+	}
+	if name := s.profile(r).Name; name != profiles.DefaultName {
+		return name
+	}
+	return "other"
+}
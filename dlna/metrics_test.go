@@ -0,0 +1,33 @@
+package dlna
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/profiles"
+)
+
+func TestMetricsClient(t *testing.T) {
+	srv := &Server{
+		ClientAliases: []ClientAlias{{Match: "192.168.1.20", Name: "bedroom"}},
+		Profiles:      profiles.NewRegistry(nil),
+	}
+	tests := []struct {
+		remote string
+		agent  string
+		client string
+	}{
+		{"192.168.1.20:1234", "Anything", "bedroom"},
+		{"192.168.1.30:1234", "SEC_HHP_[TV] UE40C7000/1.0", "samsung-c"},
+		{"192.168.1.31:1234", "Some Unknown Player/1.0", "other"},
+		{"192.168.1.32:1234", "Another Unknown Player/2.0", "other"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/cds/ctl", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("User-Agent", tt.agent)
+		if client := srv.metricsClient(r); client != tt.client {
+			t.Errorf("metricsClient(%s, %s) = %q; want %q", tt.remote, tt.agent, client, tt.client)
+		}
+	}
+}
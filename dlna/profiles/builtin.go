@@ -0,0 +1,44 @@
+package profiles
+
+// samsungCSeries returns profile of Samsung TVs of C series (2010), for example UE40C7000
+func samsungCSeries(name string, match Match) *Profile {
+	return &Profile{
+		Name:          name,
+		Match:         match,
+		BookmarkUnit:  BookmarkSeconds,
+		Containers:    []string{"matroska", "avi", "mp4", "mov", "mpegts", "mpeg", "asf", "flv"},
+		VideoCodecs:   []string{"h264", "mpeg4", "mpeg2video", "mpeg1video", "msmpeg4v3", "vc1", "wmv3"},
+		AudioCodecs:   []string{"aac", "ac3", "mp3", "mp2", "dts", "wmav2", "pcm_s16le"},
+		Only8Bit:      true,
+		MaxWidth:      1920,
+		MaxHeight:     1080,
+		MimeTypes:     map[string]string{"matroska": "video/avi", "avi": "video/avi"},
+		NamedEntities: true,
+		SamiCaptions:  true,
+	}
+}
+
+// Builtin returns built-in profiles, new copy on every call
+func Builtin() []*Profile {
+	return []*Profile{
+		samsungCSeries("samsung-c", Match{UserAgent: "40C7000"}),
+		// the same Samsung TVs send this User-Agent in some requests
+		samsungCSeries("samsung-dlnadoc", Match{UserAgentEqual: "DLNADOC/1.50"}),
+		{
+			Name:         "xbox",
+			Match:        Match{UserAgent: "Xbox"},
+			MimeTypes:    map[string]string{"avi": "video/avi"},
+			WindowsMedia: true,
+		},
+		{
+			Name:         "windows-media-player",
+			Match:        Match{UserAgent: "Windows-Media-Player"},
+			WindowsMedia: true,
+		},
+		{
+			Name:          DefaultName,
+			BookmarkUnit:  BookmarkMilliseconds,
+			NamedEntities: true,
+		},
+	}
+}
@@ -0,0 +1,233 @@
+// Package profiles describes capabilities and quirks of DLNA clients (TVs): how bookmarks are sent,
+// which containers and codecs can be played, how DIDL-Lite should be encoded, etc.
+//
+// Profiles are matched by User-Agent, request headers or remote IP. Built-in profiles can be extended
+// or replaced by profiles from user file (JSON array of profiles), user profile with the same name
+// as built-in one replaces it, the profile named "default" is used when nothing matched.
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultName is a name of the profile used for unknown clients
+const DefaultName = "default"
+
+const (
+	// BookmarkMilliseconds bookmarks are sent and received in milliseconds (default)
+	BookmarkMilliseconds = "ms"
+
+	// BookmarkSeconds bookmarks are sent and received in seconds
+	BookmarkSeconds = "s"
+)
+
+// Match is a condition of profile, all not empty fields should match
+type Match struct {
+	// UserAgent is a substring of User-Agent header
+	UserAgent string `json:"user_agent,omitempty" toml:"user_agent"`
+
+	// UserAgentEqual is a whole User-Agent header
+	UserAgentEqual string `json:"user_agent_equal,omitempty" toml:"user_agent_equal"`
+
+	// Headers are substrings of request headers, for example {"X-AV-Client-Info": "Samsung"}
+	Headers map[string]string `json:"headers,omitempty" toml:"headers"`
+
+	// IP is remote IP address or network in CIDR notation
+	IP string `json:"ip,omitempty" toml:"ip"`
+}
+
+// Profile describes capabilities and quirks of a client
+type Profile struct {
+	Name  string `json:"name" toml:"name"`
+	Match Match  `json:"match" toml:"match"`
+
+	// BookmarkUnit is BookmarkMilliseconds or BookmarkSeconds
+	BookmarkUnit string `json:"bookmark_unit,omitempty" toml:"bookmark_unit"`
+
+	// Containers, VideoCodecs and AudioCodecs are ffprobe names of formats and codecs supported by client,
+	// empty list means "everything is supported", other videos are transcoded
+	Containers  []string `json:"containers,omitempty" toml:"containers"`
+	VideoCodecs []string `json:"video_codecs,omitempty" toml:"video_codecs"`
+	AudioCodecs []string `json:"audio_codecs,omitempty" toml:"audio_codecs"`
+
+	// Only8Bit is true when client can't decode 10-bit video
+	Only8Bit bool `json:"only_8bit,omitempty" toml:"only_8bit"`
+
+	// MaxWidth and MaxHeight are max video resolution supported by client, 0 means no limit
+	MaxWidth  int `json:"max_width,omitempty" toml:"max_width"`
+	MaxHeight int `json:"max_height,omitempty" toml:"max_height"`
+
+	// MimeTypes overrides mime type by ffprobe format name, for example {"matroska": "video/avi"}
+	MimeTypes map[string]string `json:"mime_types,omitempty" toml:"mime_types"`
+
+	// NamedEntities DIDL-Lite quirk, use &quot; and &apos; instead of numeric entities
+	NamedEntities bool `json:"didl_named_entities,omitempty" toml:"didl_named_entities"`
+
+	// SamiCaptions client supports SAMI external subtitles only, srt is converted
+	SamiCaptions bool `json:"sami_captions,omitempty" toml:"sami_captions"`
+
+	// WindowsMedia quirks of Xbox and Windows Media Player: predefined container ids, searchable containers
+	WindowsMedia bool `json:"windows_media,omitempty" toml:"windows_media"`
+
+	// ThumbWidth and ThumbHeight is size of thumbnails, 0 means size of generated thumbnails
+	ThumbWidth  int `json:"thumb_width,omitempty" toml:"thumb_width"`
+	ThumbHeight int `json:"thumb_height,omitempty" toml:"thumb_height"`
+}
+
+// BookmarkInSeconds reports whether client uses seconds for bookmarks
+func (p *Profile) BookmarkInSeconds() bool {
+	return p.BookmarkUnit == BookmarkSeconds
+}
+
+// SupportsContainer reports whether client plays ffprobe format, format may be a list "mov,mp4,m4a"
+func (p *Profile) SupportsContainer(format string) bool {
+	if len(p.Containers) == 0 {
+		return true
+	}
+	for _, f := range strings.Split(format, ",") {
+		if slices.Contains(p.Containers, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsVideoCodec reports whether client decodes video codec
+func (p *Profile) SupportsVideoCodec(codec string) bool {
+	return len(p.VideoCodecs) == 0 || slices.Contains(p.VideoCodecs, codec)
+}
+
+// SupportsAudioCodec reports whether client decodes audio codec, video without audio is always supported
+func (p *Profile) SupportsAudioCodec(codec string) bool {
+	return codec == "" || len(p.AudioCodecs) == 0 || slices.Contains(p.AudioCodecs, codec)
+}
+
+// SupportsResolution reports whether client decodes video of width x height
+func (p *Profile) SupportsResolution(width, height int) bool {
+	return (p.MaxWidth <= 0 || width <= p.MaxWidth) && (p.MaxHeight <= 0 || height <= p.MaxHeight)
+}
+
+// MimeType returns overridden mime type of ffprobe format, empty string if not overridden
+func (p *Profile) MimeType(format string) string {
+	for _, f := range strings.Split(format, ",") {
+		if mime, ok := p.MimeTypes[f]; ok {
+			return mime
+		}
+	}
+	return ""
+}
+
+func (p *Profile) match(r *http.Request, ip net.IP) bool {
+	m := p.Match
+	if m.UserAgent == "" && m.UserAgentEqual == "" && len(m.Headers) == 0 && m.IP == "" {
+		return false
+	}
+	agent := r.Header.Get("User-Agent")
+	if m.UserAgent != "" && !strings.Contains(agent, m.UserAgent) {
+		return false
+	}
+	if m.UserAgentEqual != "" && agent != m.UserAgentEqual {
+		return false
+	}
+	for name, value := range m.Headers {
+		if !strings.Contains(r.Header.Get(name), value) {
+			return false
+		}
+	}
+	if m.IP != "" && !matchIP(m.IP, ip) {
+		return false
+	}
+	return true
+}
+
+func matchIP(pattern string, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if _, network, err := net.ParseCIDR(pattern); err == nil {
+		return network.Contains(ip)
+	}
+	return ip.Equal(net.ParseIP(pattern))
+}
+
+// Registry is a list of profiles
+type Registry struct {
+	profiles []*Profile
+	def      *Profile
+}
+
+// NewRegistry creates registry of built-in profiles and user profiles, user profiles are checked first
+func NewRegistry(user []*Profile) *Registry {
+	reg := &Registry{}
+	replaced := make(map[string]bool)
+	for _, p := range user {
+		replaced[p.Name] = true
+		reg.add(p)
+	}
+	for _, p := range Builtin() {
+		if !replaced[p.Name] {
+			reg.add(p)
+		}
+	}
+	return reg
+}
+
+func (reg *Registry) add(p *Profile) {
+	if p.Name == DefaultName {
+		reg.def = p
+		return
+	}
+	reg.profiles = append(reg.profiles, p)
+}
+
+// Load creates registry of built-in profiles, extra profiles (for example from configuration file)
+// and profiles from user file, empty file means no user file, extra profiles are checked first
+func Load(file string, extra ...*Profile) (*Registry, error) {
+	user := slices.Clone(extra)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("(profiles.Load) failed to read %s: %w", file, err)
+		}
+		fromFile := make([]*Profile, 0)
+		if err = json.Unmarshal(data, &fromFile); err != nil {
+			return nil, fmt.Errorf("(profiles.Load) failed to parse %s: %w", file, err)
+		}
+		user = append(user, fromFile...)
+	}
+	for i, p := range user {
+		if p.Name == "" {
+			return nil, fmt.Errorf("(profiles.Load) user profile #%d has no name", i)
+		}
+		if p.BookmarkUnit != "" && p.BookmarkUnit != BookmarkMilliseconds && p.BookmarkUnit != BookmarkSeconds {
+			return nil, fmt.Errorf("(profiles.Load) profile %s has invalid bookmark_unit: %s", p.Name, p.BookmarkUnit)
+		}
+	}
+	return NewRegistry(user), nil
+}
+
+// Find returns profile of the client, default profile if nothing matched
+func (reg *Registry) Find(r *http.Request) *Profile {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, p := range reg.profiles {
+		if p.match(r, ip) {
+			return p
+		}
+	}
+	return reg.def
+}
+
+// Profiles returns all profiles of registry, default profile is the last
+func (reg *Registry) Profiles() []*Profile {
+	return append(slices.Clone(reg.profiles), reg.def)
+}
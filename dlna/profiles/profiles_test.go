@@ -0,0 +1,159 @@
+package profiles
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	reg := NewRegistry([]*Profile{
+		{Name: "lg", Match: Match{Headers: map[string]string{"X-AV-Client-Info": "LG"}}},
+		{Name: "kodi", Match: Match{UserAgent: "Kodi", IP: "192.168.1.10"}},
+		{Name: "lan", Match: Match{IP: "10.0.0.0/8"}},
+		{Name: "empty"},
+	})
+
+	tests := []struct {
+		name    string
+		remote  string
+		agent   string
+		headers map[string]string
+		want    string
+	}{
+		{"user agent substring", "192.168.1.2:1234", "SEC_HHP_[TV]UE40C7000/1.0", nil, "samsung-c"},
+		{"user agent equal", "192.168.1.2:1234", "DLNADOC/1.50", nil, "samsung-dlnadoc"},
+		{"user agent not equal", "192.168.1.2:1234", "DLNADOC/1.50 SEC_HHP", nil, DefaultName},
+		{"xbox", "192.168.1.2:1234", "Xbox/2.0.4548.0 UPnP/1.0 Xbox/2.0.4548.0", nil, "xbox"},
+		{"header substring", "192.168.1.2:1234", "", map[string]string{"X-AV-Client-Info": "av=5.0; cn=\"LG Electronics\""}, "lg"},
+		{"user agent and ip", "192.168.1.10:1234", "Kodi/20.0", nil, "kodi"},
+		{"user agent and other ip", "192.168.1.11:1234", "Kodi/20.0", nil, DefaultName},
+		{"cidr", "10.1.2.3:1234", "", nil, "lan"},
+		{"ip without port", "10.1.2.3", "", nil, "lan"},
+		{"nothing matched", "192.168.1.2:1234", "VLC/3.0", nil, DefaultName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			r.Header.Set("User-Agent", tt.agent)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := reg.Find(r).Name; got != tt.want {
+				t.Errorf("Find() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRegistryOverride(t *testing.T) {
+	reg := NewRegistry([]*Profile{
+		{Name: "xbox", Match: Match{UserAgent: "Xbox"}},
+		{Name: DefaultName, BookmarkUnit: BookmarkSeconds},
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "Xbox/2.0")
+	if p := reg.Find(r); p.Name != "xbox" || p.WindowsMedia {
+		t.Errorf("Find(xbox) = %+v, want user profile", p)
+	}
+	r.Header.Set("User-Agent", "VLC/3.0")
+	if p := reg.Find(r); !p.BookmarkInSeconds() {
+		t.Errorf("Find(default) = %+v, want user default profile", p)
+	}
+
+	names := make([]string, 0)
+	for _, p := range reg.Profiles() {
+		names = append(names, p.Name)
+	}
+	want := "xbox,samsung-c,samsung-dlnadoc,windows-media-player,default"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Profiles() = %s, want %s", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		extra   []*Profile
+		wantErr string
+		want    string
+	}{
+		{"file", `[{"name": "kodi", "match": {"user_agent": "Kodi"}, "bookmark_unit": "s"}]`, nil, "", "kodi"},
+		{"extra before file", `[{"name": "kodi", "match": {"user_agent": "Kodi"}}]`,
+			[]*Profile{{Name: "config-kodi", Match: Match{UserAgent: "Kodi"}}}, "", "config-kodi"},
+		{"no name", `[{"match": {"user_agent": "Kodi"}}]`, nil, "has no name", ""},
+		{"invalid bookmark unit", `[{"name": "kodi", "bookmark_unit": "min"}]`, nil, "invalid bookmark_unit", ""},
+		{"invalid json", `{"name": "kodi"}`, nil, "failed to parse", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "profiles.json")
+			if err := os.WriteFile(file, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			reg, err := Load(file, tt.extra...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("User-Agent", "Kodi/20.0")
+			if got := reg.Find(r).Name; got != tt.want {
+				t.Errorf("Find() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load(missing file) succeeded")
+	}
+	if reg, err := Load(""); err != nil || len(reg.Profiles()) != len(Builtin()) {
+		t.Errorf("Load(\"\") = %v, %v, want built-in profiles", reg, err)
+	}
+}
+
+func TestSupports(t *testing.T) {
+	samsung := samsungCSeries("samsung-c", Match{})
+	all := &Profile{}
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"container list", samsung.SupportsContainer("mov,mp4,m4a,3gp,3g2,mj2"), true},
+		{"container unsupported", samsung.SupportsContainer("ogg"), false},
+		{"container everything", all.SupportsContainer("ogg"), true},
+		{"video codec", samsung.SupportsVideoCodec("h264"), true},
+		{"video codec unsupported", samsung.SupportsVideoCodec("hevc"), false},
+		{"video codec everything", all.SupportsVideoCodec("hevc"), true},
+		{"audio codec", samsung.SupportsAudioCodec("ac3"), true},
+		{"audio codec unsupported", samsung.SupportsAudioCodec("opus"), false},
+		{"no audio", samsung.SupportsAudioCodec(""), true},
+		{"resolution", samsung.SupportsResolution(1920, 1080), true},
+		{"resolution too wide", samsung.SupportsResolution(3840, 1080), false},
+		{"resolution too high", samsung.SupportsResolution(1440, 1440), false},
+		{"resolution no limit", all.SupportsResolution(7680, 4320), true},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if got := samsung.MimeType("matroska,webm"); got != "video/avi" {
+		t.Errorf("MimeType(matroska) = %s, want video/avi", got)
+	}
+	if got := samsung.MimeType("mpegts"); got != "" {
+		t.Errorf("MimeType(mpegts) = %s, want empty", got)
+	}
+}
@@ -0,0 +1,250 @@
+package dlna
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/pkg/upnpav"
+	"github.com/szonov/godlna/pkg/upnpav/searchcriteria"
+)
+
+// SearchCapabilities list of properties supported in SearchCriteria of Search action
+const SearchCapabilities = "dc:title,upnp:class,dc:date,res@duration"
+
+// objectClasses upnp:class of objects by type
+var objectClasses = map[backend.ObjectType]string{
+	backend.ObjectFolder: upnpav.ClassStorageFolder,
+	backend.ObjectVideo:  upnpav.ClassVideoItem,
+}
+
+// searchCondition converts parsed search criteria to backend search conditions,
+// unknown properties never match, so criteria like `upnp:artist contains "x" or dc:title contains "x"` still work
+func searchCondition(expr searchcriteria.Expr) (*backend.SearchCondition, error) {
+	switch e := expr.(type) {
+	case searchcriteria.All:
+		return backend.SearchConst(true), nil
+
+	case searchcriteria.And:
+		left, right, err := searchConditionPair(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &backend.SearchCondition{And: []*backend.SearchCondition{left, right}}, nil
+
+	case searchcriteria.Or:
+		left, right, err := searchConditionPair(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &backend.SearchCondition{Or: []*backend.SearchCondition{left, right}}, nil
+
+	case searchcriteria.Exists:
+		return existsCondition(e), nil
+
+	case searchcriteria.Relation:
+		return relationCondition(e)
+	}
+	return nil, fmt.Errorf("unsupported expression: %s", expr)
+}
+
+func searchConditionPair(l, r searchcriteria.Expr) (*backend.SearchCondition, *backend.SearchCondition, error) {
+	left, err := searchCondition(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := searchCondition(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+func existsCondition(e searchcriteria.Exists) *backend.SearchCondition {
+	switch e.Property {
+	case "@id", "@parentID", "@restricted", "dc:title", "upnp:class":
+		// all objects have these properties
+		return backend.SearchConst(e.Exists)
+	case "dc:date", "res", "res@duration", "res@size", "res@protocolInfo":
+		// videos only
+		typ := backend.ObjectVideo
+		if !e.Exists {
+			typ = backend.ObjectFolder
+		}
+		return &backend.SearchCondition{Field: backend.SearchType, Operator: backend.SearchEqual, Value: typ}
+	}
+	return backend.SearchConst(!e.Exists)
+}
+
+func relationCondition(e searchcriteria.Relation) (*backend.SearchCondition, error) {
+	switch e.Property {
+	case "dc:title":
+		if e.Op == searchcriteria.OpDerivedFrom {
+			return backend.SearchConst(false), nil
+		}
+		title := &backend.SearchCondition{Field: backend.SearchTitle, Operator: searchOperator(e.Op), Value: e.Value}
+		path := &backend.SearchCondition{Field: backend.SearchPath, Operator: title.Operator, Value: e.Value}
+		// substring of title is searched in names of parent folders as well
+		switch e.Op {
+		case searchcriteria.OpContains:
+			return &backend.SearchCondition{Or: []*backend.SearchCondition{title, path}}, nil
+		case searchcriteria.OpDoesNotContain:
+			return &backend.SearchCondition{And: []*backend.SearchCondition{title, path}}, nil
+		}
+		return title, nil
+
+	case "upnp:class":
+		return classCondition(e), nil
+
+	case "dc:date":
+		if !isRelOp(e.Op) {
+			return backend.SearchConst(false), nil
+		}
+		from, to, err := parseSearchDate(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return dateCondition(e.Op, from, to), nil
+
+	case "res@duration":
+		if !isRelOp(e.Op) {
+			return backend.SearchConst(false), nil
+		}
+		duration, err := parseSearchDuration(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &backend.SearchCondition{Field: backend.SearchDuration, Operator: searchOperator(e.Op), Value: duration}, nil
+	}
+	return backend.SearchConst(false), nil
+}
+
+// classCondition evaluates relation for every object type, as upnp:class depends on type only
+func classCondition(e searchcriteria.Relation) *backend.SearchCondition {
+	matched := make([]*backend.SearchCondition, 0)
+	for typ, class := range objectClasses {
+		if classMatch(class, e.Op, e.Value) {
+			matched = append(matched, &backend.SearchCondition{Field: backend.SearchType, Operator: backend.SearchEqual, Value: typ})
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return backend.SearchConst(false)
+	case len(objectClasses):
+		return backend.SearchConst(true)
+	case 1:
+		return matched[0]
+	}
+	return &backend.SearchCondition{Or: matched}
+}
+
+func classMatch(class string, op searchcriteria.Operator, value string) bool {
+	switch op {
+	case searchcriteria.OpDerivedFrom:
+		return class == value || strings.HasPrefix(class, value+".")
+	case searchcriteria.OpContains:
+		return strings.Contains(class, value)
+	case searchcriteria.OpDoesNotContain:
+		return !strings.Contains(class, value)
+	case searchcriteria.OpEqual:
+		return class == value
+	case searchcriteria.OpNotEqual:
+		return class != value
+	case searchcriteria.OpLess:
+		return class < value
+	case searchcriteria.OpLessOrEqual:
+		return class <= value
+	case searchcriteria.OpGreater:
+		return class > value
+	case searchcriteria.OpGreaterOrEqual:
+		return class >= value
+	}
+	return false
+}
+
+func isRelOp(op searchcriteria.Operator) bool {
+	switch op {
+	case searchcriteria.OpEqual, searchcriteria.OpNotEqual, searchcriteria.OpLess,
+		searchcriteria.OpLessOrEqual, searchcriteria.OpGreater, searchcriteria.OpGreaterOrEqual:
+		return true
+	}
+	return false
+}
+
+func searchOperator(op searchcriteria.Operator) backend.SearchOperator {
+	switch op {
+	case searchcriteria.OpNotEqual:
+		return backend.SearchNotEqual
+	case searchcriteria.OpLess:
+		return backend.SearchLess
+	case searchcriteria.OpLessOrEqual:
+		return backend.SearchLessOrEqual
+	case searchcriteria.OpGreater:
+		return backend.SearchGreater
+	case searchcriteria.OpGreaterOrEqual:
+		return backend.SearchGreaterOrEqual
+	case searchcriteria.OpContains:
+		return backend.SearchContains
+	case searchcriteria.OpDoesNotContain:
+		return backend.SearchNotContains
+	}
+	return backend.SearchEqual
+}
+
+// dateCondition compares dc:date with range of seconds [from, to)
+func dateCondition(op searchcriteria.Operator, from, to int64) *backend.SearchCondition {
+	cond := func(op backend.SearchOperator, value int64) *backend.SearchCondition {
+		return &backend.SearchCondition{Field: backend.SearchDate, Operator: op, Value: value}
+	}
+	switch op {
+	case searchcriteria.OpEqual:
+		return &backend.SearchCondition{And: []*backend.SearchCondition{
+			cond(backend.SearchGreaterOrEqual, from), cond(backend.SearchLess, to),
+		}}
+	case searchcriteria.OpNotEqual:
+		return &backend.SearchCondition{Or: []*backend.SearchCondition{
+			cond(backend.SearchLess, from), cond(backend.SearchGreaterOrEqual, to),
+		}}
+	case searchcriteria.OpLess:
+		return cond(backend.SearchLess, from)
+	case searchcriteria.OpLessOrEqual:
+		return cond(backend.SearchLess, to)
+	case searchcriteria.OpGreater:
+		return cond(backend.SearchGreaterOrEqual, to)
+	case searchcriteria.OpGreaterOrEqual:
+		return cond(backend.SearchGreaterOrEqual, from)
+	}
+	return backend.SearchConst(false)
+}
+
+// parseSearchDate parses dc:date value to range of seconds [from, to), date without time is a whole day,
+// date is compared in local time zone as dc:date in Browse response
+func parseSearchDate(value string) (from int64, to int64, err error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), t.Unix() + 1, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local); err == nil {
+		return t.Unix(), t.Unix() + 1, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.Unix(), t.AddDate(0, 0, 1).Unix(), nil
+	}
+	return 0, 0, fmt.Errorf("invalid date: %s", value)
+}
+
+// parseSearchDuration parses res@duration value in format H+:MM:SS[.F+] to milliseconds
+func parseSearchDuration(value string) (int64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	h, err1 := strconv.ParseInt(parts[0], 10, 64)
+	m, err2 := strconv.ParseInt(parts[1], 10, 64)
+	s, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || h < 0 || m < 0 || m > 59 || s < 0 || s >= 60 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return (h*3600+m*60)*1000 + int64(s*1000), nil
+}
@@ -0,0 +1,75 @@
+package dlna
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchDate(t *testing.T) {
+	// all videos of test server have date 1700000000
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000})
+	date := time.Unix(1700000000, 0)
+	day := date.Format("2006-01-02")
+	prev := date.AddDate(0, 0, -1).Format("2006-01-02")
+	next := date.AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		criteria string
+		found    bool
+	}{
+		{`dc:date = "` + day + `"`, true},
+		{`dc:date != "` + day + `"`, false},
+		{`dc:date = "` + next + `"`, false},
+		{`dc:date != "` + next + `"`, true},
+		{`dc:date < "` + day + `"`, false},
+		{`dc:date <= "` + day + `"`, true},
+		{`dc:date > "` + day + `"`, false},
+		{`dc:date >= "` + day + `"`, true},
+		{`dc:date > "` + prev + `"`, true},
+		{`dc:date < "` + next + `"`, true},
+		{`dc:date = "` + date.Format(time.RFC3339) + `"`, true},
+		{`dc:date = "` + date.Add(time.Second).Format("2006-01-02T15:04:05") + `"`, false},
+		{`dc:date <= "` + date.Format("2006-01-02T15:04:05") + `"`, true},
+		{`dc:date < "` + date.Format("2006-01-02T15:04:05") + `"`, false},
+	}
+	for _, tt := range tests {
+		if got := ts.search(t, otherTV, "0", tt.criteria); (len(got) == 1) != tt.found {
+			t.Errorf("search %s: %v; want found %v", tt.criteria, got, tt.found)
+		}
+	}
+}
+
+func TestSearchTitlePath(t *testing.T) {
+	files := map[string]int64{"Films/Action/a.mkv": 60000, "Series/b.mkv": 60000, "Series/c.mkv": 60000}
+	const videos = `upnp:class derivedfrom "object.item.videoItem" and `
+
+	tests := []struct {
+		multiRoot bool
+		criteria  string
+		want      []string
+	}{
+		{false, `dc:title contains "B"`, []string{"b"}},
+		{false, `dc:title contains "action"`, []string{"a"}},
+		{false, `dc:title contains "FILMS/act"`, []string{"a"}},
+		{false, `dc:title doesNotContain "series"`, []string{"a"}},
+		{false, `dc:title doesNotContain "b"`, []string{"a", "c"}},
+		{false, `dc:title = "series"`, []string{}},
+		// path of library root is not visible
+		{false, `dc:title contains "TestSearchTitlePath"`, []string{}},
+		// roots are visible folders in multi-root mode
+		{true, `dc:title contains "series/"`, []string{"b", "c"}},
+		{true, `dc:title contains "TestSearchTitlePath"`, []string{}},
+	}
+	single := newTestServer(t, files)
+	multi := newTestServerRoots(t, files, true)
+	for _, tt := range tests {
+		ts := single
+		if tt.multiRoot {
+			ts = multi
+		}
+		if got := ts.search(t, otherTV, "0", videos+tt.criteria); !slices.Equal(got, tt.want) {
+			t.Errorf("search %s (multi-root %v): %v; want %v", tt.criteria, tt.multiRoot, got, tt.want)
+		}
+	}
+}
@@ -2,17 +2,23 @@ package dlna
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/dlna/profiles"
 	"github.com/szonov/godlna/logger"
+	"github.com/szonov/godlna/pkg/metrics"
 	"github.com/szonov/godlna/pkg/upnp/device"
+	"github.com/szonov/godlna/pkg/upnp/events"
 )
 
 var ServerHeader = fmt.Sprintf("%s/%s %s %s", runtime.GOOS, runtime.Version(), "UPnP/1.0", "GoUPnP/1.0")
@@ -23,15 +29,28 @@ type Server struct {
 	DebugRequest       bool
 	DebugRequestHeader bool
 	DebugRequestBody   bool
+	BookmarkMode       BookmarkMode
+	ClientAliases      []ClientAlias
+	MaxTranscodes      int
+	Profiles           *profiles.Registry
+	APIToken           string
+	APIAllow           []netip.Prefix
 	srv                *http.Server
 	back               *backend.Backend
+	clients            *clientRegistry
+	mu                 sync.Mutex
+	device             *DeviceController
+	connectionManager  *ConnectionManagerController
 }
 
 func NewServer(friendlyName string, listenAddr string, back *backend.Backend) *Server {
 	return &Server{
 		ListenAddress:     listenAddr,
 		DeviceDescription: makeDeviceDescription(friendlyName, listenAddr),
+		MaxTranscodes:     DefaultMaxTranscodes,
+		Profiles:          profiles.NewRegistry(nil),
 		back:              back,
+		clients:           newClientRegistry(),
 	}
 }
 
@@ -87,43 +106,196 @@ func (s *Server) hook(next http.HandlerFunc) http.HandlerFunc {
 			logger.DebugRequest(r, s.DebugRequestHeader, s.DebugRequestBody)
 		}
 		w.Header().Set("Server", ServerHeader)
+		if !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/metrics" {
+			s.clients.seen(r, clientName(r, s.ClientAliases), s.profile(r).Name)
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// guardAPI rejects admin API requests from networks not listed in APIAllow (empty means all networks),
+// and mutating requests sent cross-origin by browser or without APIToken (Authorization: Bearer), if it is set
+func (s *Server) guardAPI(next http.HandlerFunc) http.HandlerFunc {
+	csrf := http.NewCrossOriginProtection()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.apiAllowed(r) {
+			sendJSON(w, http.StatusForbidden, &apiError{Error: "forbidden"})
+			return
+		}
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := csrf.Check(r); err != nil {
+			sendJSON(w, http.StatusForbidden, &apiError{Error: "cross-origin request"})
+			return
+		}
+		if s.APIToken != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.APIToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="godlna"`)
+				sendJSON(w, http.StatusUnauthorized, &apiError{Error: "invalid token"})
+				return
+			}
+		}
 		next.ServeHTTP(w, r)
 	}
 }
 
+// apiAllowed reports whether remote IP of the request is in one of APIAllow networks
+func (s *Server) apiAllowed(r *http.Request) bool {
+	if len(s.APIAllow) == 0 {
+		return true
+	}
+	ip, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range s.APIAllow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) setupRoutes(mux *http.ServeMux) error {
 	var err error
 	var deviceController *DeviceController
 	var cdsController *ContentDirectoryController
+	var cmController *ConnectionManagerController
+	var mrrController *MediaReceiverRegistrarController
+	var apiController *APIController
+
+	s.mu.Lock()
+	deviceController, err = NewDeviceController(s)
+	s.device = deviceController
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if cdsController, err = NewContentDirectoryController(s); err != nil {
+		return err
+	}
 
-	if deviceController, err = NewDeviceController(s); err != nil {
+	if cmController, err = NewConnectionManagerController(s); err != nil {
 		return err
 	}
+	s.mu.Lock()
+	s.connectionManager = cmController
+	s.mu.Unlock()
 
-	if cdsController, err = NewContentDirectoryController(s.back); err != nil {
+	if mrrController, err = NewMediaReceiverRegistrarController(s); err != nil {
 		return err
 	}
 
+	apiController = NewAPIController(s, map[string]*events.Manager{
+		"ContentDirectory":            cdsController.eventManager,
+		"ConnectionManager":           cmController.eventManager,
+		"X_MS_MediaReceiverRegistrar": mrrController.eventManager,
+	})
+
 	// index
 	mux.HandleFunc("/", s.hook(deviceController.HandleIndexURL))
 
 	// device
 	mux.HandleFunc("/device/desc.xml", s.hook(deviceController.HandleDescriptionURL))
 	mux.HandleFunc("/device/icons/", s.hook(deviceController.HandleIcons))
+	mux.HandleFunc("/web/", s.hook(deviceController.HandleWeb))
 
 	// content directory
 	mux.HandleFunc("/cds/desc.xml", s.hook(cdsController.HandleSCPDURL))
-	mux.HandleFunc("/cds/ctl", s.hook(cdsController.HandleControlURL))
+	mux.HandleFunc("/cds/ctl", s.hook(s.measureSOAP(cdsController.HandleControlURL)))
 	mux.HandleFunc("/cds/evt", s.hook(cdsController.HandleEventSubURL))
 
+	// connection manager
+	mux.HandleFunc("/cm/desc.xml", s.hook(cmController.HandleSCPDURL))
+	mux.HandleFunc("/cm/ctl", s.hook(s.measureSOAP(cmController.HandleControlURL)))
+	mux.HandleFunc("/cm/evt", s.hook(cmController.HandleEventSubURL))
+
+	// media receiver registrar (Xbox, Windows Media Player)
+	mux.HandleFunc("/mrr/desc.xml", s.hook(mrrController.HandleSCPDURL))
+	mux.HandleFunc("/mrr/ctl", s.hook(s.measureSOAP(mrrController.HandleControlURL)))
+	mux.HandleFunc("/mrr/evt", s.hook(mrrController.HandleEventSubURL))
+
 	// content
 	mux.HandleFunc("/ct/t/{obj}", s.hook(cdsController.HandleContentURL))
 	mux.HandleFunc("/ct/v/{obj}", s.hook(cdsController.HandleContentURL))
+	mux.HandleFunc("/ct/s/{obj}", s.hook(cdsController.HandleSubtitleURL))
+	mux.HandleFunc("/ct/x/{obj}", s.hook(cdsController.HandleTranscodeURL))
+
+	// admin api
+	mux.HandleFunc("/api/", s.hook(s.guardAPI(apiController.HandleNotFound)))
+	mux.HandleFunc("/api/objects", s.hook(s.guardAPI(apiController.HandleObjects)))
+	mux.HandleFunc("/api/objects/{id}", s.hook(s.guardAPI(apiController.HandleObject)))
+	mux.HandleFunc("/api/objects/{id}/children", s.hook(s.guardAPI(apiController.HandleChildren)))
+	mux.HandleFunc("/api/objects/{id}/bookmark", s.hook(s.guardAPI(apiController.HandleBookmark)))
+	mux.HandleFunc("/api/objects/{id}/bookmark/restore", s.hook(s.guardAPI(apiController.HandleRestoreBookmark)))
+	mux.HandleFunc("/api/objects/{id}/reindex", s.hook(s.guardAPI(apiController.HandleReindex)))
+	mux.HandleFunc("/api/objects/{id}/thumbnail", s.hook(s.guardAPI(apiController.HandleThumbnail)))
+	mux.HandleFunc("/api/objects/{id}/rescan", s.hook(s.guardAPI(apiController.HandleRescan)))
+	mux.HandleFunc("/api/bookmarks", s.hook(s.guardAPI(apiController.HandleBookmarks)))
+	mux.HandleFunc("/api/history", s.hook(s.guardAPI(apiController.HandleHistory)))
+	mux.HandleFunc("/api/reindex", s.hook(s.guardAPI(apiController.HandleReindexQueue)))
+	mux.HandleFunc("/api/reindex/broken", s.hook(s.guardAPI(apiController.HandleBroken)))
+	mux.HandleFunc("/api/reindex/requeue", s.hook(s.guardAPI(apiController.HandleRequeue)))
+	mux.HandleFunc("/api/subscribers", s.hook(s.guardAPI(apiController.HandleSubscribers)))
+	mux.HandleFunc("/api/clients", s.hook(s.guardAPI(apiController.HandleClients)))
+
+	// prometheus metrics
+	mux.HandleFunc("/metrics", s.hook(metrics.Handler().ServeHTTP))
 
 	return nil
 }
 
-func useSecondsInBookmark(r *http.Request) bool {
-	agent := r.Header.Get("User-Agent")
-	return agent == "DLNADOC/1.50" || strings.Contains(agent, "40C7000")
+// SetFriendlyName changes name of the server as it is visible on TV, device description and index page
+// are updated immediately, but clients see the new name after SSDP announcement
+func (s *Server) SetFriendlyName(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DeviceDescription.Device.FriendlyName = name
+	if s.device == nil {
+		// server is not started yet
+		return nil
+	}
+	return s.device.render()
+}
+
+// SetProfiles replaces client profiles, SourceProtocolInfo of ConnectionManager is rebuilt
+// and sent to subscribers if mime types of profiles are changed
+func (s *Server) SetProfiles(reg *profiles.Registry) {
+	s.mu.Lock()
+	s.Profiles = reg
+	cm := s.connectionManager
+	s.mu.Unlock()
+	if cm != nil {
+		cm.UpdateSourceProtocolInfo()
+	}
+}
+
+// Clients returns clients (TVs) connected since start of the server, recently seen first
+func (s *Server) Clients() []ClientInfo {
+	return s.clients.list()
+}
+
+// bookmarkClient returns client name used for storing bookmarks, empty string means shared bookmarks
+func (s *Server) bookmarkClient(r *http.Request) string {
+	if s.BookmarkMode == BookmarkPerClient {
+		return clientName(r, s.ClientAliases)
+	}
+	return ""
+}
+
+// profile returns profile of the client (TV) which sent the request
+func (s *Server) profile(r *http.Request) *profiles.Profile {
+	return s.profiles().Find(r)
+}
+
+// profiles returns current registry of client profiles, it is replaced by SetProfiles
+func (s *Server) profiles() *profiles.Registry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Profiles
 }
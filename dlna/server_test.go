@@ -0,0 +1,178 @@
+package dlna
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/pkg/ffmpeg"
+)
+
+// testServer is a server with library of empty video files inside of temp directory, stored by memory driver
+type testServer struct {
+	*Server
+	root    string
+	handler http.Handler
+	driver  *backend.EmbeddedDriver
+}
+
+// newTestServer creates library of files (relative paths, duration in milliseconds), all videos are reindexed,
+// ffmpeg is replaced by script returning small JPEG image, so thumbnails are created without real ffmpeg
+func newTestServer(t *testing.T, files map[string]int64) *testServer {
+	return newTestServerRoots(t, files, false)
+}
+
+// newTestServerRoots creates library as newTestServer, with multiRoot top level folders are separate roots;
+// roots are indexed first, as file system watcher does, so the first root has id 1
+func newTestServerRoots(t *testing.T, files map[string]int64, multiRoot bool) *testServer {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeFFmpeg(t)
+
+	roots := []string{root}
+	if multiRoot {
+		roots = make([]string, 0)
+		for name := range files {
+			dir := filepath.Join(root, strings.Split(name, "/")[0])
+			if !slices.Contains(roots, dir) {
+				roots = append(roots, dir)
+			}
+		}
+		slices.Sort(roots)
+	}
+
+	d := backend.NewMemoryDriver()
+	for _, dir := range roots {
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err = d.Index(true, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, duration := range files {
+		file := filepath.Join(root, name)
+		if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for dir := filepath.Dir(file); !slices.Contains(roots, dir); dir = filepath.Dir(dir) {
+			if err = d.Index(true, dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = d.Index(false, file); err != nil {
+			t.Fatal(err)
+		}
+		res, err := d.GetObjects(backend.ObjectSearchFilter{OwnPaths: []string{file}, Status: backend.StatusAll, Sort: backend.SortNone})
+		if err != nil || len(res.Items) != 1 {
+			t.Fatalf("indexed %s: %v", name, err)
+		}
+		info := &backend.VideoInfo{Format: "matroska", VideoCodec: "h264", AudioCodec: "aac", Width: 1920, Height: 1080, Duration: duration, Date: 1700000000}
+		if err = d.UpdateObject(res.Items[0], info, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	back, err := backend.NewBackend(roots, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer("Test", "127.0.0.1:50003", back)
+	mux := http.NewServeMux()
+	if err = srv.setupRoutes(mux); err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{Server: srv, root: root, handler: mux, driver: d}
+}
+
+// fakeFFmpeg replaces ffmpeg by script writing 8x8 JPEG image to stdout
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	frame := filepath.Join(dir, "frame.jpg")
+	script := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(frame, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat '"+frame+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ffmpeg.SetBinPath(script)
+	t.Cleanup(func() { ffmpeg.SetBinPath("ffmpeg") })
+}
+
+// object returns object by path relative to library root
+func (ts *testServer) object(t *testing.T, name string) *backend.Object {
+	t.Helper()
+	res, err := ts.driver.GetObjects(backend.ObjectSearchFilter{
+		OwnPaths: []string{filepath.Join(ts.root, name)},
+		Status:   backend.StatusAll,
+		Sort:     backend.SortNone,
+	})
+	if err != nil || len(res.Items) != 1 {
+		t.Fatalf("object %s: %v", name, err)
+	}
+	return res.Items[0]
+}
+
+// do sends request to the server, remote address is 192.168.1.20
+func (ts *testServer) do(method, target, userAgent string, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.RemoteAddr = "192.168.1.20:40000"
+	r.Header.Set("User-Agent", userAgent)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+// testClient is a TV sending SOAP requests
+type testClient struct {
+	remoteAddr string
+	userAgent  string
+}
+
+var (
+	// samsungTV sends bookmarks in seconds
+	samsungTV = testClient{remoteAddr: "192.168.1.20:40000", userAgent: "SEC_HHP_[TV] UE40C7000/1.0"}
+	// otherTV is unknown client (default profile), sends bookmarks in milliseconds
+	otherTV = testClient{remoteAddr: "192.168.1.30:40000", userAgent: "Some Player/1.0"}
+)
+
+// soap sends action of ContentDirectory service, args are XML elements of action
+func (ts *testServer) soap(t *testing.T, c testClient, action string, args string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + ContentDirectoryServiceType + `">` + args + `</u:` + action + `></s:Body></s:Envelope>`
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:50003/cds/ctl", strings.NewReader(body))
+	r.RemoteAddr = c.remoteAddr
+	r.Header.Set("User-Agent", c.userAgent)
+	r.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	r.Header.Set("SoapAction", `"`+ContentDirectoryServiceType+"#"+action+`"`)
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
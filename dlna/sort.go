@@ -0,0 +1,32 @@
+package dlna
+
+import (
+	"strings"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+// SortCapabilities list of properties supported in SortCriteria of Browse and Search actions
+const SortCapabilities = "dc:title,dc:date,res@size,res@duration"
+
+var sortFields = map[string]backend.SortField{
+	"dc:title":     backend.SortByTitle,
+	"dc:date":      backend.SortByDate,
+	"res@size":     backend.SortBySize,
+	"res@duration": backend.SortByDuration,
+}
+
+// parseSortCriteria parses SortCriteria argument, for example "+dc:title,-dc:date",
+// properties without sign are sorted ascending, unsupported properties are ignored
+func parseSortCriteria(criteria string) []backend.SortKey {
+	keys := make([]backend.SortKey, 0)
+	for _, item := range strings.Split(criteria, ",") {
+		item = strings.TrimSpace(item)
+		desc := strings.HasPrefix(item, "-")
+		item = strings.TrimLeft(item, "+-")
+		if field, ok := sortFields[item]; ok {
+			keys = append(keys, backend.SortKey{Field: field, Desc: desc})
+		}
+	}
+	return keys
+}
@@ -0,0 +1,139 @@
+package dlna
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/pkg/ffmpeg"
+)
+
+const timeSeekRangeHeader = "TimeSeekRange.dlna.org"
+
+var errInvalidNpt = errors.New("invalid npt time")
+
+// timeSeekFormats are ffprobe formats of MPEG transport and program streams, renderers can start playing
+// them from any byte offset, other containers (mp4, mkv) have index and can't be cut in the middle
+var timeSeekFormats = []string{"mpegts", "mpeg", "mpegvideo"}
+
+// timeSeekSupported reports whether byte offset of video can be estimated by time
+func timeSeekSupported(o *backend.Object) bool {
+	if o.Duration <= 0 || o.FileSize <= 0 {
+		return false
+	}
+	for _, format := range strings.Split(o.Format, ",") {
+		if slices.Contains(timeSeekFormats, format) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeSeekRange parses value of TimeSeekRange.dlna.org header "npt=START-[END]",
+// end is -1 when not specified
+func parseTimeSeekRange(value string) (start time.Duration, end time.Duration, err error) {
+	value, ok := strings.CutPrefix(strings.TrimSpace(value), "npt=")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+	}
+	// some clients send bytes range in the same header, "npt=10-20 bytes=1000-2000"
+	value, _, _ = strings.Cut(value, " ")
+
+	s, e, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+	}
+	if start, err = parseNptTime(s); err != nil {
+		return 0, 0, err
+	}
+	end = -1
+	if e != "" {
+		if end, err = parseNptTime(e); err != nil {
+			return 0, 0, err
+		}
+		if end < start {
+			return 0, 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+		}
+	}
+	return start, end, nil
+}
+
+// parseNptTime parses npt time in format "SECONDS[.FRACTION]" or "H+:MM:SS[.FRACTION]"
+func parseNptTime(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	var h, m int64
+	var err error
+	switch len(parts) {
+	case 1:
+	case 3:
+		if h, err = strconv.ParseInt(parts[0], 10, 64); err != nil || h < 0 {
+			return 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+		}
+		if m, err = strconv.ParseInt(parts[1], 10, 64); err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+		}
+	default:
+		return 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+	}
+	s, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || s < 0 || (len(parts) == 3 && s >= 60) {
+		return 0, fmt.Errorf("%w: %s", errInvalidNpt, value)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second)), nil
+}
+
+// handleTimeSeek converts TimeSeekRange.dlna.org request to byte Range request by bitrate estimation,
+// answers with TimeSeekRange.dlna.org header, Content-Range header is added by http.ServeFile,
+// returns false when request is already answered with error
+func handleTimeSeek(w http.ResponseWriter, r *http.Request, o *backend.Object) bool {
+	value := r.Header.Get(timeSeekRangeHeader)
+	if value == "" {
+		return true
+	}
+	if !timeSeekSupported(o) {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return false
+	}
+
+	start, end, err := parseTimeSeekRange(value)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+
+	duration := time.Duration(o.Duration) * time.Millisecond
+	if start >= duration {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return false
+	}
+	if end < 0 || end > duration {
+		end = duration
+	}
+
+	firstByte := timeToByte(o, start)
+	lastByte := timeToByte(o, end) - 1
+	if end == duration || lastByte >= o.FileSize {
+		lastByte = o.FileSize - 1
+	}
+	if lastByte < firstByte {
+		lastByte = firstByte
+	}
+
+	w.Header().Set(timeSeekRangeHeader, fmt.Sprintf(
+		"npt=%s-%s/%s bytes=%d-%d/%d",
+		ffmpeg.DurationToString(start), ffmpeg.DurationToString(end), ffmpeg.DurationToString(duration),
+		firstByte, lastByte, o.FileSize,
+	))
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", firstByte, lastByte))
+	return true
+}
+
+// timeToByte estimates byte offset of time position with average bitrate of file
+func timeToByte(o *backend.Object, t time.Duration) int64 {
+	return int64(float64(o.FileSize) * float64(t.Milliseconds()) / float64(o.Duration))
+}
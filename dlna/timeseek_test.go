@@ -0,0 +1,135 @@
+package dlna
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+)
+
+func TestParseTimeSeekRange(t *testing.T) {
+	tests := []struct {
+		value string
+		start time.Duration
+		end   time.Duration
+	}{
+		{"npt=0-", 0, -1},
+		{"npt=10-", 10 * time.Second, -1},
+		{"npt=10.5-20.25", 10500 * time.Millisecond, 20250 * time.Millisecond},
+		{"npt=10-10", 10 * time.Second, 10 * time.Second},
+		{" npt=0:01:30-", 90 * time.Second, -1},
+		{"npt=1:02:03.5-", time.Hour + 2*time.Minute + 3500*time.Millisecond, -1},
+		{"npt=00:00:00.000-00:00:59.999", 0, 59999 * time.Millisecond},
+		{"npt=100:00:00-", 100 * time.Hour, -1},
+		{"npt=10-20 bytes=1000-2000", 10 * time.Second, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		start, end, err := parseTimeSeekRange(tt.value)
+		if err != nil {
+			t.Errorf("parseTimeSeekRange(%q): %v", tt.value, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("parseTimeSeekRange(%q) = %v, %v; want %v, %v", tt.value, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParseTimeSeekRangeErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"10-20",
+		"bytes=0-100",
+		"npt=",
+		"npt=10",
+		"npt=-20",
+		"npt=abc-",
+		"npt=20-10",
+		"npt=-1-",
+		"npt=now-",
+		"npt=1:02-",
+		"npt=0:60:00-",
+		"npt=0:00:60-",
+		"npt=-1:00:00-",
+		"npt=0:00:00:00-",
+		"npt=10-abc",
+	} {
+		if start, end, err := parseTimeSeekRange(value); !errors.Is(err, errInvalidNpt) {
+			t.Errorf("parseTimeSeekRange(%q) = %v, %v, %v; want invalid npt error", value, start, end, err)
+		}
+	}
+}
+
+func TestTimeSeekSupported(t *testing.T) {
+	tests := []struct {
+		format   string
+		duration int64
+		size     int64
+		want     bool
+	}{
+		{"mpegts", 60000, 1000, true},
+		{"mpeg", 60000, 1000, true},
+		{"mpegvideo", 60000, 1000, true},
+		{"matroska,webm", 60000, 1000, false},
+		{"mov,mp4,m4a,3gp,3g2,mj2", 60000, 1000, false},
+		{"mpegts", 0, 1000, false},
+		{"mpegts", 60000, 0, false},
+		{"", 60000, 1000, false},
+	}
+	for _, tt := range tests {
+		o := &backend.Object{Format: tt.format, Duration: tt.duration, FileSize: tt.size}
+		if got := timeSeekSupported(o); got != tt.want {
+			t.Errorf("timeSeekSupported(%q, duration=%d, size=%d) = %v; want %v", tt.format, tt.duration, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestHandleTimeSeek(t *testing.T) {
+	// 100 seconds, 10000 bytes: 100 bytes per second
+	ts := &backend.Object{Format: "mpegts", Duration: 100000, FileSize: 10000}
+	mkv := &backend.Object{Format: "matroska,webm", Duration: 100000, FileSize: 10000}
+
+	tests := []struct {
+		name     string
+		o        *backend.Object
+		value    string
+		ok       bool
+		status   int
+		rangeHdr string
+		seekHdr  string
+	}{
+		{"without header", ts, "", true, http.StatusOK, "", ""},
+		{"open end", ts, "npt=10-", true, http.StatusOK,
+			"bytes=1000-9999", "npt=0:00:10.000-0:01:40.000/0:01:40.000 bytes=1000-9999/10000"},
+		{"closed range", ts, "npt=10-20", true, http.StatusOK,
+			"bytes=1000-1999", "npt=0:00:10.000-0:00:20.000/0:01:40.000 bytes=1000-1999/10000"},
+		{"end after duration", ts, "npt=90-200", true, http.StatusOK,
+			"bytes=9000-9999", "npt=0:01:30.000-0:01:40.000/0:01:40.000 bytes=9000-9999/10000"},
+		{"empty range", ts, "npt=10-10", true, http.StatusOK,
+			"bytes=1000-1000", "npt=0:00:10.000-0:00:10.000/0:01:40.000 bytes=1000-1000/10000"},
+		{"start after duration", ts, "npt=100-", false, http.StatusRequestedRangeNotSatisfiable, "", ""},
+		{"invalid", ts, "npt=20-10", false, http.StatusBadRequest, "", ""},
+		{"not supported", mkv, "npt=10-", false, http.StatusNotAcceptable, "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/video", nil)
+		if tt.value != "" {
+			r.Header.Set(timeSeekRangeHeader, tt.value)
+		}
+		w := httptest.NewRecorder()
+		ok := handleTimeSeek(w, r, tt.o)
+		if ok != tt.ok || w.Code != tt.status {
+			t.Errorf("%s: handleTimeSeek() = %v, status %d; want %v, %d", tt.name, ok, w.Code, tt.ok, tt.status)
+			continue
+		}
+		if got := r.Header.Get("Range"); got != tt.rangeHdr {
+			t.Errorf("%s: Range %q; want %q", tt.name, got, tt.rangeHdr)
+		}
+		if got := w.Header().Get(timeSeekRangeHeader); got != tt.seekHdr {
+			t.Errorf("%s: %s %q; want %q", tt.name, timeSeekRangeHeader, got, tt.seekHdr)
+		}
+	}
+}
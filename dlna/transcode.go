@@ -0,0 +1,151 @@
+package dlna
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/dlna/profiles"
+	"github.com/szonov/godlna/pkg/dlnaprofile"
+	"github.com/szonov/godlna/pkg/ffmpeg"
+)
+
+// DefaultMaxTranscodes is default amount of concurrent transcodes
+const DefaultMaxTranscodes = 2
+
+// transcodeNeeded reports whether video should be transcoded for the client, and codecs to use,
+// ffmpeg.CopyCodec is used for supported streams, unsupported container with supported codecs is remuxed
+func (ctl *ContentDirectoryController) transcodeNeeded(o *backend.Object, p *profiles.Profile) (video string, audio string, needed bool) {
+	if ctl.transcodes == nil || o.Typ != backend.ObjectVideo {
+		return "", "", false
+	}
+
+	video, audio = ffmpeg.CopyCodec, ffmpeg.CopyCodec
+	if !p.SupportsVideoCodec(o.VideoCodec) || !p.SupportsResolution(o.Width, o.Height) {
+		video = "libx264"
+	} else if p.Only8Bit && ctl.is10Bit(o) {
+		video = "libx264"
+	}
+	if !p.SupportsAudioCodec(o.AudioCodec) {
+		audio = "ac3"
+	}
+	needed = video != ffmpeg.CopyCodec || audio != ffmpeg.CopyCodec || !p.SupportsContainer(o.Format)
+	return video, audio, needed
+}
+
+// is10Bit reports whether video stream has more than 8 bits per component, for example yuv420p10le
+func (ctl *ContentDirectoryController) is10Bit(o *backend.Object) bool {
+	s := ctl.videoStream(o)
+	if s == nil {
+		return false
+	}
+	return strings.Contains(s.PixelFormat, "p10") || strings.Contains(s.PixelFormat, "p12") ||
+		strings.Contains(s.PixelFormat, "p010")
+}
+
+// transcodeProtocolInfo returns protocol info of MPEG-TS stream made with video and audio codecs
+func (ctl *ContentDirectoryController) transcodeProtocolInfo(o *backend.Object, video string, audio string) dlnaprofile.ProtocolInfo {
+	m := dlnaprofile.Media{
+		Format:       "mpegts",
+		Ext:          ".ts",
+		VideoCodec:   "h264",
+		VideoProfile: "High",
+		AudioCodec:   audio,
+		Width:        o.Width,
+		Height:       o.Height,
+	}
+	if video == ffmpeg.CopyCodec {
+		// bitrate of transcoded video is unknown, copied video keeps the original one
+		m.VideoCodec = o.VideoCodec
+		m.Bitrate = o.Bitrate
+		if s := ctl.videoStream(o); s != nil {
+			m.VideoProfile = s.Profile
+		}
+	}
+	if audio == ffmpeg.CopyCodec {
+		m.AudioCodec = o.AudioCodec
+	}
+	// transcoded stream is sought by ffmpeg, it works for any source container
+	return dlnaprofile.Transcoded(dlnaprofile.Detect(m), o.Duration > 0)
+}
+
+func transcodeURL(o *backend.Object, r *http.Request) string {
+	return fmt.Sprintf("http://%s/ct/x/%d.ts", r.Host, o.ID)
+}
+
+// HandleTranscodeURL streams video transcoded to MPEG-TS, url format is /ct/x/{objectId}.ts,
+// amount of concurrent transcodes is limited by Server.MaxTranscodes
+func (ctl *ContentDirectoryController) HandleTranscodeURL(w http.ResponseWriter, r *http.Request) {
+	obj := r.PathValue("obj")
+	objectID, err := strconv.Atoi(strings.TrimSuffix(obj, filepath.Ext(obj)))
+	if err != nil || ctl.transcodes == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	o, err := ctl.back.Object(objectID, "")
+	if err != nil || o.Typ != backend.ObjectVideo {
+		slog.Error("Object not found", "objectID", objectID)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// client plays source codecs, but requested transcoded stream anyway, remux only
+	p := ctl.srv.profile(r)
+	video, audio, _ := ctl.transcodeNeeded(o, p)
+
+	var seek time.Duration
+	if value := r.Header.Get(timeSeekRangeHeader); value != "" {
+		start, _, err := parseTimeSeekRange(value)
+		duration := time.Duration(o.Duration) * time.Millisecond
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if duration > 0 && start >= duration {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		seek = start
+		w.Header().Set(timeSeekRangeHeader, fmt.Sprintf(
+			"npt=%s-%s/%s",
+			ffmpeg.DurationToString(start), ffmpeg.DurationToString(duration), ffmpeg.DurationToString(duration),
+		))
+	}
+
+	w.Header().Set("EXT", "")
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	protocolInfo := ctl.transcodeProtocolInfo(o, video, audio)
+	w.Header().Set("contentFeatures.dlna.org", protocolInfo.ContentFeatures())
+	w.Header().Set("Content-Type", protocolInfo.MimeType)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case ctl.transcodes <- struct{}{}:
+		defer func() { <-ctl.transcodes }()
+	default:
+		slog.Warn("too many concurrent transcodes", "objectID", objectID)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	slog.Info("transcoding", "path", o.Path, "video", video, "audio", audio, "seek", seek)
+	w.WriteHeader(http.StatusOK)
+	err = ffmpeg.Transcode(r.Context(), o.Path, countContent(w, "transcode"),
+		ffmpeg.TranscodeVideo(video),
+		ffmpeg.TranscodeAudio(audio),
+		ffmpeg.TranscodeSeek(seek),
+		ffmpeg.TranscodeMaxHeight(p.MaxHeight),
+	)
+	if err != nil {
+		slog.Error("transcoding failed", "path", o.Path, "err", err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package dlna
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/szonov/godlna/dlna/backend"
+	"github.com/szonov/godlna/pkg/ffmpeg"
+)
+
+func TestTranscodeNeeded(t *testing.T) {
+	tests := []struct {
+		name        string
+		info        backend.VideoInfo
+		pixelFormat string
+		client      testClient
+		video       string
+		audio       string
+		needed      bool
+	}{
+		{"supported", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "h264", AudioCodec: "aac", Width: 1920, Height: 1080},
+			"yuv420p", samsungTV, ffmpeg.CopyCodec, ffmpeg.CopyCodec, false},
+		{"video codec", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "hevc", AudioCodec: "aac", Width: 1920, Height: 1080},
+			"yuv420p", samsungTV, "libx264", ffmpeg.CopyCodec, true},
+		{"audio codec", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "h264", AudioCodec: "opus", Width: 1920, Height: 1080},
+			"yuv420p", samsungTV, ffmpeg.CopyCodec, "ac3", true},
+		{"resolution", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "h264", AudioCodec: "aac", Width: 3840, Height: 2160},
+			"yuv420p", samsungTV, "libx264", ffmpeg.CopyCodec, true},
+		{"10 bit", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "h264", AudioCodec: "aac", Width: 1920, Height: 1080},
+			"yuv420p10le", samsungTV, "libx264", ffmpeg.CopyCodec, true},
+		{"container is remuxed", backend.VideoInfo{Format: "ogg", VideoCodec: "h264", AudioCodec: "aac", Width: 1920, Height: 1080},
+			"yuv420p", samsungTV, ffmpeg.CopyCodec, ffmpeg.CopyCodec, true},
+		{"default profile plays everything", backend.VideoInfo{Format: "matroska,webm", VideoCodec: "hevc", AudioCodec: "opus", Width: 3840, Height: 2160},
+			"yuv420p10le", otherTV, ffmpeg.CopyCodec, ffmpeg.CopyCodec, false},
+	}
+
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000})
+	ctl, err := NewContentDirectoryController(ts.Server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		o := ts.object(t, "a.mkv")
+		info := tt.info
+		if err = ts.driver.UpdateObject(o, &info, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err = ts.driver.SetStreams(o.ID, []backend.Stream{
+			{Index: 0, Type: backend.StreamVideo, Codec: tt.info.VideoCodec, PixelFormat: tt.pixelFormat},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", tt.client.userAgent)
+		video, audio, needed := ctl.transcodeNeeded(ts.object(t, "a.mkv"), ts.profile(r))
+		if video != tt.video || audio != tt.audio || needed != tt.needed {
+			t.Errorf("%s: transcodeNeeded() = %s, %s, %v; want %s, %s, %v",
+				tt.name, video, audio, needed, tt.video, tt.audio, tt.needed)
+		}
+	}
+}
+
+func TestTranscodeResource(t *testing.T) {
+	ts := newTestServer(t, map[string]int64{"a.mkv": 60000, "b.mkv": 60000})
+	a, b := ts.object(t, "a.mkv"), ts.object(t, "b.mkv")
+	info := &backend.VideoInfo{Format: "matroska,webm", VideoCodec: "hevc", AudioCodec: "aac", Width: 1920, Height: 1080, Duration: 60000}
+	if err := ts.driver.UpdateObject(a, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	transcodeURL := fmt.Sprintf("http://127.0.0.1:50003/ct/x/%d.ts", a.ID)
+
+	// transcoded stream is the first resource for the client not playing the source
+	res := ts.browse(t, samsungTV, a.ID, "BrowseMetadata", 0, 0, "")
+	if len(res.Items) != 1 || len(res.Items[0].Res) < 2 {
+		t.Fatalf("Browse %d: %+v", a.ID, res.Items)
+	}
+	if r := res.Items[0].Res[0]; r.URL != transcodeURL ||
+		!strings.HasPrefix(r.ProtocolInfo, "http-get:*:video/mpeg:") || !strings.Contains(r.ProtocolInfo, "DLNA.ORG_CI=1") {
+		t.Errorf("first resource %+v; want transcoded stream %s", r, transcodeURL)
+	}
+	for _, tt := range []struct {
+		client testClient
+		id     int
+	}{{otherTV, a.ID}, {samsungTV, b.ID}} {
+		res = ts.browse(t, tt.client, tt.id, "BrowseMetadata", 0, 0, "")
+		for _, r := range res.Items[0].Res {
+			if strings.Contains(r.URL, "/ct/x/") {
+				t.Errorf("Browse %d by %s: unexpected transcoded resource %+v", tt.id, tt.client.userAgent, r)
+			}
+		}
+	}
+
+	tests := []struct {
+		method string
+		target string
+		header map[string]string
+		status int
+	}{
+		{http.MethodHead, fmt.Sprintf("/ct/x/%d.ts", a.ID), nil, http.StatusOK},
+		{http.MethodGet, fmt.Sprintf("/ct/x/%d.ts", a.ID), nil, http.StatusOK},
+		{http.MethodHead, fmt.Sprintf("/ct/x/%d.ts", a.ID), map[string]string{timeSeekRangeHeader: "npt=10-"}, http.StatusOK},
+		{http.MethodHead, fmt.Sprintf("/ct/x/%d.ts", a.ID), map[string]string{timeSeekRangeHeader: "npt=70-"}, http.StatusRequestedRangeNotSatisfiable},
+		{http.MethodHead, fmt.Sprintf("/ct/x/%d.ts", a.ID), map[string]string{timeSeekRangeHeader: "bytes=0-"}, http.StatusBadRequest},
+		{http.MethodHead, "/ct/x/100000.ts", nil, http.StatusNotFound},
+		{http.MethodHead, "/ct/x/abc.ts", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := ts.do(tt.method, tt.target, samsungTV.userAgent, "", tt.header)
+		if w.Code != tt.status {
+			t.Errorf("%s %s %v: status %d; want %d", tt.method, tt.target, tt.header, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "video/mpeg" {
+			t.Errorf("%s %s: Content-Type %q; want video/mpeg", tt.method, tt.target, ct)
+		}
+		if cf := w.Header().Get("contentFeatures.dlna.org"); !strings.Contains(cf, "DLNA.ORG_OP=10") || !strings.Contains(cf, "DLNA.ORG_CI=1") {
+			t.Errorf("%s %s: contentFeatures %q; want time seek of transcoded stream", tt.method, tt.target, cf)
+		}
+		if tt.header != nil && w.Header().Get(timeSeekRangeHeader) == "" {
+			t.Errorf("%s %s: no %s header", tt.method, tt.target, timeSeekRangeHeader)
+		}
+		if tt.method == http.MethodGet && w.Body.Len() == 0 {
+			t.Errorf("GET %s: empty body", tt.target)
+		}
+	}
+}
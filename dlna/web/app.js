@@ -0,0 +1,278 @@
+// Web UI of the library, works on top of admin JSON API (/api/)
+(function () {
+    "use strict";
+
+    const pageSize = 60;
+    const perClient = document.body.dataset.perClient === "true";
+
+    const el = {
+        items: document.getElementById("items"),
+        more: document.getElementById("more"),
+        message: document.getElementById("message"),
+        breadcrumbs: document.getElementById("breadcrumbs"),
+        status: document.getElementById("status"),
+        rescan: document.getElementById("rescan"),
+        client: document.getElementById("client"),
+        clientSelect: document.getElementById("client-select"),
+        folderTemplate: document.getElementById("folder-template"),
+        videoTemplate: document.getElementById("video-template"),
+    };
+
+    const state = {
+        folderId: 0,
+        offset: 0,
+    };
+
+    function client() {
+        return el.client.value;
+    }
+
+    function url(path, params) {
+        const q = new URLSearchParams();
+        for (const [k, v] of Object.entries(params || {})) {
+            if (v !== "" && v !== undefined && v !== null) {
+                q.set(k, v);
+            }
+        }
+        const qs = q.toString();
+        return qs ? path + "?" + qs : path;
+    }
+
+    async function api(method, path, params) {
+        const headers = {};
+        const token = localStorage.getItem("apiToken");
+        if (token) {
+            headers["Authorization"] = "Bearer " + token;
+        }
+        const res = await fetch(url(path, params), {method: method, headers: headers});
+        if (res.status === 401) {
+            // mutating requests require token configured on the server (-api-token)
+            const entered = prompt("API token");
+            if (entered) {
+                localStorage.setItem("apiToken", entered);
+                return api(method, path, params);
+            }
+        }
+        if (!res.ok) {
+            let text = res.statusText;
+            try {
+                text = (await res.json()).error || text;
+            } catch (e) {
+                // not json body
+            }
+            throw new Error(text);
+        }
+        if (res.status === 204 || res.status === 202) {
+            return null;
+        }
+        return res.json();
+    }
+
+    function showMessage(text) {
+        el.message.textContent = text;
+        el.message.hidden = !text;
+    }
+
+    function formatDuration(ms) {
+        const total = Math.floor(ms / 1000);
+        const h = Math.floor(total / 3600);
+        const m = Math.floor((total % 3600) / 60);
+        const s = total % 60;
+        const pad = (v) => String(v).padStart(2, "0");
+        return h > 0 ? h + ":" + pad(m) + ":" + pad(s) : m + ":" + pad(s);
+    }
+
+    function formatSize(bytes) {
+        const units = ["B", "KB", "MB", "GB", "TB"];
+        let i = 0;
+        while (bytes >= 1024 && i < units.length - 1) {
+            bytes /= 1024;
+            i++;
+        }
+        return bytes.toFixed(i > 1 ? 1 : 0) + " " + units[i];
+    }
+
+    // watchedPercent returns progress of the video, bookmark 0 means watched to the end (Samsung TV)
+    function watchedPercent(o) {
+        if (o.bookmark === undefined || !o.duration) {
+            return 0;
+        }
+        if (o.bookmark === 0) {
+            return 100;
+        }
+        return Math.min(100, Math.round(o.bookmark * 100 / o.duration));
+    }
+
+    function renderFolder(o) {
+        const node = el.folderTemplate.content.firstElementChild.cloneNode(true);
+        node.querySelector(".title").textContent = o.title;
+        node.querySelector(".open").href = "#/" + o.id;
+        return node;
+    }
+
+    function renderVideo(o) {
+        const node = el.videoTemplate.content.firstElementChild.cloneNode(true);
+        const percent = watchedPercent(o);
+
+        const img = node.querySelector("img");
+        img.src = url("/api/objects/" + o.id + "/thumbnail", {client: client(), t: o.bookmark_at || o.date});
+        img.addEventListener("error", () => img.remove(), {once: true});
+
+        node.querySelector(".bar").style.width = percent + "%";
+        node.querySelector(".title").textContent = o.title;
+
+        const details = [];
+        if (o.duration) {
+            details.push(formatDuration(o.duration));
+        }
+        if (o.width && o.height) {
+            details.push(o.width + "×" + o.height);
+        }
+        if (o.file_size) {
+            details.push(formatSize(o.file_size));
+        }
+        if (percent >= 95) {
+            details.push("watched");
+        } else if (percent > 0) {
+            details.push("watched " + percent + "%");
+        }
+        node.querySelector(".details").textContent = details.join(" · ");
+
+        const action = (button, method, path, params) => {
+            button.addEventListener("click", async () => {
+                button.disabled = true;
+                try {
+                    await api(method, path, params);
+                    await reload();
+                } catch (e) {
+                    showMessage(o.title + ": " + e.message);
+                    button.disabled = false;
+                }
+            });
+        };
+        action(node.querySelector(".watched"), "PUT", "/api/objects/" + o.id + "/bookmark",
+            {position: o.duration || 0, client: client()});
+        action(node.querySelector(".unwatched"), "DELETE", "/api/objects/" + o.id + "/bookmark",
+            {client: client()});
+        action(node.querySelector(".reindex"), "POST", "/api/objects/" + o.id + "/reindex");
+
+        return node;
+    }
+
+    async function renderBreadcrumbs(id) {
+        const path = [];
+        while (path.length < 64) {
+            const o = await api("GET", "/api/objects/" + id);
+            path.unshift(o);
+            if (o.id === 0) {
+                break;
+            }
+            // parent of top level folders is the root
+            id = o.parent_id === undefined || o.parent_id < 0 ? 0 : o.parent_id;
+        }
+
+        el.breadcrumbs.replaceChildren();
+        path.forEach((o, i) => {
+            if (i > 0) {
+                const sep = document.createElement("span");
+                sep.className = "sep";
+                sep.textContent = "/";
+                el.breadcrumbs.append(sep);
+            }
+            const a = document.createElement("a");
+            a.href = "#/" + o.id;
+            a.textContent = o.id === 0 ? "Library" : o.title;
+            el.breadcrumbs.append(a);
+        });
+    }
+
+    async function loadChildren() {
+        const res = await api("GET", "/api/objects/" + state.folderId + "/children",
+            {client: client(), limit: pageSize, offset: state.offset});
+        for (const o of res.items) {
+            el.items.append(o.type === "folder" ? renderFolder(o) : renderVideo(o));
+        }
+        state.offset += res.items.length;
+        el.more.hidden = state.offset >= res.total_matches;
+        if (res.total_matches === 0) {
+            showMessage("Folder is empty");
+        }
+    }
+
+    async function open(id) {
+        state.folderId = id;
+        state.offset = 0;
+        el.items.replaceChildren();
+        showMessage("");
+        try {
+            await Promise.all([renderBreadcrumbs(id), loadChildren()]);
+        } catch (e) {
+            showMessage(e.message);
+        }
+    }
+
+    // reload shows the same amount of items of current folder again
+    async function reload() {
+        const count = Math.max(state.offset, pageSize);
+        state.offset = 0;
+        const res = await api("GET", "/api/objects/" + state.folderId + "/children",
+            {client: client(), limit: count, offset: 0});
+        el.items.replaceChildren(...res.items.map((o) => o.type === "folder" ? renderFolder(o) : renderVideo(o)));
+        state.offset = res.items.length;
+        el.more.hidden = state.offset >= res.total_matches;
+    }
+
+    function folderFromHash() {
+        const id = parseInt(location.hash.replace(/^#\//, ""), 10);
+        return isNaN(id) ? 0 : id;
+    }
+
+    async function refreshStatus() {
+        try {
+            const s = await api("GET", "/api/reindex");
+            const parts = ["Reindexing: " + s.dirty + " waiting", s.running.length + " in progress"];
+            if (s.broken > 0) {
+                parts.push(s.broken + " broken");
+            }
+            el.status.textContent = parts.join(", ");
+        } catch (e) {
+            el.status.textContent = "";
+        }
+    }
+
+    async function loadClients() {
+        if (!perClient) {
+            return;
+        }
+        el.clientSelect.hidden = false;
+        try {
+            for (const c of await api("GET", "/api/clients")) {
+                const option = document.createElement("option");
+                option.value = c.name;
+                option.textContent = c.name;
+                el.client.append(option);
+            }
+        } catch (e) {
+            // shared bookmarks only
+        }
+    }
+
+    el.more.addEventListener("click", () => loadChildren().catch((e) => showMessage(e.message)));
+    el.client.addEventListener("change", () => open(state.folderId));
+    el.rescan.addEventListener("click", async () => {
+        el.rescan.disabled = true;
+        try {
+            await api("POST", "/api/objects/" + Math.max(state.folderId, 0) + "/rescan");
+            showMessage("Rescan started, new videos appear after reindexing");
+        } catch (e) {
+            showMessage(e.message);
+        } finally {
+            el.rescan.disabled = false;
+        }
+    });
+    window.addEventListener("hashchange", () => open(folderFromHash()));
+
+    loadClients().then(() => open(folderFromHash()));
+    refreshStatus();
+    setInterval(refreshStatus, 10000);
+})();
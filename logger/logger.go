@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 func rgb(s string, r uint, g uint, b uint) string {
@@ -21,7 +22,13 @@ type MyLogHandler struct {
 	l *log.Logger
 }
 
-var OnlyMessage = false
+// onlyMessage is systemd format: message and attributes only, journal adds time and level itself
+var onlyMessage atomic.Bool
+
+// SetOnlyMessage switches the logger to systemd format, can be changed at runtime
+func SetOnlyMessage(v bool) {
+	onlyMessage.Store(v)
+}
 
 func (h *MyLogHandler) Handle(ctx context.Context, r slog.Record) error {
 
@@ -37,7 +44,7 @@ func (h *MyLogHandler) Handle(ctx context.Context, r slog.Record) error {
 		return true
 	})
 
-	if OnlyMessage {
+	if onlyMessage.Load() {
 		h.l.Println(r.Message, strings.Join(values, " "))
 		return nil
 	}
@@ -80,7 +87,7 @@ func (h *MyLogHandler) Handle(ctx context.Context, r slog.Record) error {
 	return nil
 }
 
-func NewMyLogHandler(out io.Writer, level slog.Level) *MyLogHandler {
+func NewMyLogHandler(out io.Writer, level slog.Leveler) *MyLogHandler {
 	h := &MyLogHandler{
 		Handler: slog.NewTextHandler(out, &slog.HandlerOptions{
 			AddSource: true,
@@ -91,10 +98,19 @@ func NewMyLogHandler(out io.Writer, level slog.Level) *MyLogHandler {
 	return h
 }
 
+// logLevel is a level of the default logger, can be changed at runtime by SetLevel
+var logLevel = new(slog.LevelVar)
+
 func InitLogger(level ...slog.Level) {
 	if len(level) == 0 {
 		level = append(level, slog.LevelDebug)
 	}
-	logger := slog.New(NewMyLogHandler(os.Stdout, level[0]))
+	logLevel.Set(level[0])
+	logger := slog.New(NewMyLogHandler(os.Stdout, logLevel))
 	slog.SetDefault(logger)
 }
+
+// SetLevel changes level of the logger created by InitLogger
+func SetLevel(level slog.Level) {
+	logLevel.Set(level)
+}
@@ -0,0 +1,278 @@
+// Package dlnaprofile derives mime type and DLNA profile name (DLNA.ORG_PN) of video from ffprobe
+// format name, codecs, resolution and bitrate, and builds protocolInfo / contentFeatures.dlna.org values
+package dlnaprofile
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Media is a description of video file as reported by ffprobe
+type Media struct {
+	// Format is ffprobe format name, for example "matroska,webm" or "mov,mp4,m4a,3gp,3g2,mj2"
+	Format string
+
+	// Ext is file extension or name, used to distinguish .m2ts (192-byte packets) from .ts
+	Ext string
+
+	VideoCodec   string
+	VideoProfile string
+	AudioCodec   string
+	Width        int
+	Height       int
+
+	// Bitrate is bitrate of the whole file in bits per second, 0 when unknown
+	Bitrate int
+}
+
+// Profile is mime type and DLNA profile name, PN is empty when media has no DLNA profile
+type Profile struct {
+	MimeType string
+	PN       string
+}
+
+// Detect returns mime type and DLNA profile name of media
+func Detect(m Media) Profile {
+	formats := strings.Split(m.Format, ",")
+	has := func(name string) bool {
+		for _, f := range formats {
+			if f == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("matroska"):
+		if has("webm") && isWebmCodecs(m) {
+			return Profile{MimeType: "video/webm"}
+		}
+		return Profile{MimeType: "video/x-matroska", PN: findPN(containerMKV, m)}
+
+	case has("mp4"), has("mov"):
+		ext := strings.ToLower(filepath.Ext(m.Ext))
+		switch {
+		case ext == ".3gp" || ext == ".3g2":
+			return Profile{MimeType: "video/3gpp"}
+		case ext == ".mov" || ext == ".qt":
+			return Profile{MimeType: "video/quicktime"}
+		}
+		return Profile{MimeType: "video/mp4", PN: findPN(containerMP4, m)}
+
+	case has("mpegts"):
+		if isTimestampedTS(m.Ext) {
+			return Profile{MimeType: "video/vnd.dlna.mpeg-tts", PN: withSuffix(findPN(containerTS, m), "_T")}
+		}
+		return Profile{MimeType: "video/mpeg", PN: withSuffix(findPN(containerTS, m), "_ISO")}
+
+	case has("mpeg"), has("mpegvideo"):
+		return Profile{MimeType: "video/mpeg", PN: findPN(containerPS, m)}
+
+	case has("avi"):
+		return Profile{MimeType: "video/x-msvideo"}
+
+	case has("asf"):
+		return Profile{MimeType: "video/x-ms-wmv", PN: findPN(containerASF, m)}
+
+	case has("flv"):
+		return Profile{MimeType: "video/x-flv"}
+
+	case has("ogg"):
+		return Profile{MimeType: "video/ogg"}
+	}
+	return Profile{MimeType: "video/mpeg"}
+}
+
+func isWebmCodecs(m Media) bool {
+	switch m.VideoCodec {
+	case "vp8", "vp9", "av1":
+	default:
+		return false
+	}
+	switch m.AudioCodec {
+	case "", "vorbis", "opus":
+		return true
+	}
+	return false
+}
+
+// isTimestampedTS reports whether file is MPEG-TS with 4-byte timestamp before every packet (BDAV, AVCHD)
+func isTimestampedTS(ext string) bool {
+	switch strings.ToLower(filepath.Ext(ext)) {
+	case ".m2ts", ".mts":
+		return true
+	}
+	return false
+}
+
+// videoProfile returns BL, MP or HP for AVC and SP or ASP for MPEG-4 Part 2, as in profile names
+func videoProfile(m Media) string {
+	p := strings.ToLower(m.VideoProfile)
+	switch m.VideoCodec {
+	case "h264":
+		switch {
+		case strings.Contains(p, "baseline"):
+			return "BL"
+		case strings.Contains(p, "high"):
+			return "HP"
+		}
+		return "MP"
+	case "mpeg4":
+		if p == "simple profile" {
+			return "SP"
+		}
+		return "ASP"
+	}
+	return ""
+}
+
+// withSuffix adds suffix of MPEG-TS packet size to profile name: _ISO for 188-byte packets or _T for 192-byte packets
+func withSuffix(pn string, suffix string) string {
+	if pn == "" {
+		return ""
+	}
+	return pn + suffix
+}
+
+type container int
+
+const (
+	containerMKV container = iota
+	containerMP4
+	containerTS
+	containerPS
+	containerASF
+)
+
+// pnRule is DLNA profile name with limits of media, empty or zero limit is not checked
+type pnRule struct {
+	container container
+	pn        string
+
+	// video and audio are ffprobe codec names, "" in audio allows video without sound
+	video []string
+	audio []string
+
+	// profiles are allowed video profiles as returned by videoProfile
+	profiles []string
+
+	// width and height are maximal frame size, heights are allowed frame heights (PAL or NTSC)
+	width   int
+	height  int
+	heights []int
+
+	// bitrate is maximal bitrate of the whole file in bits per second
+	bitrate int
+}
+
+// pnRules are DLNA profile names of DLNA Media Format guidelines, and Matroska as MKV (used by TVs without
+// the official profile), for MPEG-TS profiles the packet size suffix is added by Detect; the first matched rule
+// is used, so smaller profiles go first;
+// profiles limited by low bitrate only (like AVC_MP4_BL_CIF15_AAC_520) are not listed, as bitrate is often unknown
+var pnRules = []pnRule{
+	{container: containerMKV, pn: "MKV"},
+
+	// H.264 in MP4, Constrained Baseline is a subset of Main profile
+	{containerMP4, "AVC_MP4_MP_SD_AAC_MULT5", []string{"h264"}, []string{"aac"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerMP4, "AVC_MP4_MP_SD_MPEG1_L3", []string{"h264"}, []string{"mp3"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerMP4, "AVC_MP4_MP_SD_AC3", []string{"h264"}, []string{"ac3"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerMP4, "AVC_MP4_MP_HD_720p_AAC", []string{"h264"}, []string{"aac"}, []string{"BL", "MP"}, 1280, 720, nil, 20_000_000},
+	{containerMP4, "AVC_MP4_MP_HD_1080i_AAC", []string{"h264"}, []string{"aac"}, []string{"BL", "MP"}, 1920, 1080, nil, 20_000_000},
+	{containerMP4, "AVC_MP4_HP_HD_AAC", []string{"h264"}, []string{"aac"}, []string{"BL", "MP", "HP"}, 1920, 1080, nil, 20_000_000},
+
+	// MPEG-4 Part 2 in MP4, Simple profile is a subset of Advanced Simple profile
+	{containerMP4, "MPEG4_P2_MP4_SP_AAC", []string{"mpeg4"}, []string{"aac"}, []string{"SP"}, 352, 288, nil, 384_000},
+	{containerMP4, "MPEG4_P2_MP4_ASP_AAC", []string{"mpeg4"}, []string{"aac"}, []string{"SP", "ASP"}, 720, 576, nil, 8_000_000},
+
+	// MPEG-2 in MPEG-TS
+	{containerTS, "MPEG_TS_SD_NA", []string{"mpeg2video"}, []string{"", "ac3"}, nil, 720, 480, []int{480, 240}, 15_000_000},
+	{containerTS, "MPEG_TS_SD_EU", []string{"mpeg2video"}, []string{"", "ac3", "mp2"}, nil, 720, 576, []int{576, 288}, 15_000_000},
+	{containerTS, "MPEG_TS_HD_NA", []string{"mpeg2video"}, []string{"", "ac3"}, nil, 1920, 1080, nil, 19_392_658},
+
+	// H.264 in MPEG-TS
+	{containerTS, "AVC_TS_MP_SD_AAC_MULT5", []string{"h264"}, []string{"aac"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerTS, "AVC_TS_MP_SD_MPEG1_L3", []string{"h264"}, []string{"mp3"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerTS, "AVC_TS_MP_SD_AC3", []string{"h264"}, []string{"ac3"}, []string{"BL", "MP"}, 720, 576, nil, 10_000_000},
+	{containerTS, "AVC_TS_HP_SD_MPEG1_L2", []string{"h264"}, []string{"mp2"}, []string{"BL", "MP", "HP"}, 720, 576, nil, 10_000_000},
+	{containerTS, "AVC_TS_HP_SD_AC3", []string{"h264"}, []string{"ac3"}, []string{"BL", "MP", "HP"}, 720, 576, nil, 10_000_000},
+	{containerTS, "AVC_TS_MP_HD_AAC_MULT5", []string{"h264"}, []string{"aac"}, []string{"BL", "MP"}, 1920, 1080, nil, 20_000_000},
+	{containerTS, "AVC_TS_MP_HD_MPEG1_L3", []string{"h264"}, []string{"mp3"}, []string{"BL", "MP"}, 1920, 1080, nil, 20_000_000},
+	{containerTS, "AVC_TS_MP_HD_AC3", []string{"h264"}, []string{"ac3"}, []string{"BL", "MP"}, 1920, 1080, nil, 20_000_000},
+	{containerTS, "AVC_TS_HP_HD_MPEG1_L2", []string{"h264"}, []string{"mp2"}, []string{"BL", "MP", "HP"}, 1920, 1080, nil, 20_000_000},
+	{containerTS, "AVC_TS_HP_HD_AC3", []string{"h264"}, []string{"ac3"}, []string{"BL", "MP", "HP"}, 1920, 1080, nil, 20_000_000},
+
+	// MPEG program stream and elementary stream
+	{containerPS, "MPEG1", []string{"mpeg1video"}, []string{"", "mp2"}, nil, 352, 288, nil, 1_856_000},
+	{containerPS, "MPEG_PS_NTSC", []string{"mpeg2video"}, []string{"", "ac3", "mp2", "pcm_dvd"}, nil, 720, 480, []int{480, 240}, 10_080_000},
+	{containerPS, "MPEG_PS_PAL", []string{"mpeg2video"}, []string{"", "ac3", "mp2", "pcm_dvd"}, nil, 720, 576, []int{576, 288}, 10_080_000},
+
+	// Windows Media Video 9 (Main profile)
+	{containerASF, "WMVMED_FULL", []string{"wmv3"}, []string{"wmav1", "wmav2"}, nil, 720, 576, nil, 10_000_000},
+	{containerASF, "WMVMED_PRO", []string{"wmv3"}, []string{"wmapro"}, nil, 720, 576, nil, 10_000_000},
+	{containerASF, "WMVHIGH_FULL", []string{"wmv3"}, []string{"wmav1", "wmav2"}, nil, 1920, 1080, nil, 20_000_000},
+	{containerASF, "WMVHIGH_PRO", []string{"wmv3"}, []string{"wmapro"}, nil, 1920, 1080, nil, 20_000_000},
+}
+
+func (r *pnRule) match(m Media) bool {
+	switch {
+	case r.video != nil && !slices.Contains(r.video, m.VideoCodec),
+		r.audio != nil && !slices.Contains(r.audio, m.AudioCodec),
+		r.profiles != nil && !slices.Contains(r.profiles, videoProfile(m)),
+		r.width > 0 && m.Width > r.width,
+		r.height > 0 && m.Height > r.height,
+		r.heights != nil && m.Height > 0 && !slices.Contains(r.heights, m.Height),
+		r.bitrate > 0 && m.Bitrate > r.bitrate:
+		return false
+	}
+	return true
+}
+
+// findPN returns name of the first profile matched media, empty string when media is out of all profiles
+func findPN(c container, m Media) string {
+	for i := range pnRules {
+		if pnRules[i].container == c && pnRules[i].match(m) {
+			return pnRules[i].pn
+		}
+	}
+	return ""
+}
+
+// containerMimeTypes are mime types of containers with DLNA profiles, MPEG-TS profiles with _T suffix are sent
+// with video/vnd.dlna.mpeg-tts
+var containerMimeTypes = map[container]string{
+	containerMKV: "video/x-matroska",
+	containerMP4: "video/mp4",
+	containerTS:  "video/mpeg",
+	containerPS:  "video/mpeg",
+	containerASF: "video/x-ms-wmv",
+}
+
+// All returns all profiles which can be returned by Detect, used in ConnectionManager's SourceProtocolInfo
+func All() []Profile {
+	list := []Profile{
+		{MimeType: "video/x-matroska"},
+		{MimeType: "video/webm"},
+		{MimeType: "video/3gpp"},
+		{MimeType: "video/quicktime"},
+		{MimeType: "video/mp4"},
+		{MimeType: "video/mpeg"},
+		{MimeType: "video/vnd.dlna.mpeg-tts"},
+		{MimeType: "video/x-msvideo"},
+		{MimeType: "video/x-ms-wmv"},
+		{MimeType: "video/x-flv"},
+		{MimeType: "video/ogg"},
+	}
+	for _, r := range pnRules {
+		if r.container == containerTS {
+			list = append(list,
+				Profile{MimeType: "video/mpeg", PN: r.pn + "_ISO"},
+				Profile{MimeType: "video/vnd.dlna.mpeg-tts", PN: r.pn + "_T"},
+			)
+			continue
+		}
+		list = append(list, Profile{MimeType: containerMimeTypes[r.container], PN: r.pn})
+	}
+	return list
+}
@@ -0,0 +1,169 @@
+package dlnaprofile
+
+import (
+	"slices"
+	"testing"
+)
+
+// format names below are exactly as ffprobe reports them in format_name
+const (
+	formatMatroska = "matroska,webm"
+	formatMP4      = "mov,mp4,m4a,3gp,3g2,mj2"
+	formatTS       = "mpegts"
+	formatPS       = "mpeg"
+	formatES       = "mpegvideo"
+	formatASF      = "asf"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		media Media
+		want  Profile
+	}{
+		// matroska / webm
+		{"mkv h264", Media{Format: formatMatroska, Ext: ".mkv", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/x-matroska", "MKV"}},
+		{"mkv hevc", Media{Format: formatMatroska, Ext: ".mkv", VideoCodec: "hevc", VideoProfile: "Main 10", AudioCodec: "eac3", Width: 3840, Height: 2160},
+			Profile{"video/x-matroska", "MKV"}},
+		{"webm vp9 opus", Media{Format: formatMatroska, Ext: ".webm", VideoCodec: "vp9", AudioCodec: "opus", Width: 1920, Height: 1080},
+			Profile{"video/webm", ""}},
+		{"webm vp8 vorbis", Media{Format: formatMatroska, Ext: ".webm", VideoCodec: "vp8", AudioCodec: "vorbis", Width: 640, Height: 360},
+			Profile{"video/webm", ""}},
+		{"webm av1 without audio", Media{Format: formatMatroska, Ext: ".webm", VideoCodec: "av1", Width: 1920, Height: 1080},
+			Profile{"video/webm", ""}},
+		{"mkv vp9 aac is not webm", Media{Format: formatMatroska, Ext: ".mkv", VideoCodec: "vp9", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/x-matroska", "MKV"}},
+
+		// mp4 / mov / 3gp
+		{"mp4 h264 high hd aac", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/mp4", "AVC_MP4_HP_HD_AAC"}},
+		{"mp4 h264 main sd ac3", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "ac3", Width: 720, Height: 576},
+			Profile{"video/mp4", "AVC_MP4_MP_SD_AC3"}},
+		{"mp4 h264 baseline mp3", Media{Format: formatMP4, Ext: ".m4v", VideoCodec: "h264", VideoProfile: "Constrained Baseline", AudioCodec: "mp3", Width: 640, Height: 360},
+			Profile{"video/mp4", "AVC_MP4_MP_SD_MPEG1_L3"}},
+		{"mp4 h264 main 720p", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "aac", Width: 1280, Height: 720, Bitrate: 6_000_000},
+			Profile{"video/mp4", "AVC_MP4_MP_HD_720p_AAC"}},
+		{"mp4 h264 main sd above sd bitrate", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "aac", Width: 720, Height: 576, Bitrate: 12_000_000},
+			Profile{"video/mp4", "AVC_MP4_MP_HD_720p_AAC"}},
+		{"mp4 h264 high above hd bitrate has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 1920, Height: 1080, Bitrate: 35_000_000},
+			Profile{"video/mp4", ""}},
+		{"mp4 h264 4k has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 3840, Height: 2160},
+			Profile{"video/mp4", ""}},
+		{"mp4 h264 hd ac3 has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "ac3", Width: 1280, Height: 720},
+			Profile{"video/mp4", ""}},
+		{"mp4 h264 eac3 has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "eac3", Width: 720, Height: 576},
+			Profile{"video/mp4", ""}},
+		{"mp4 h264 opus has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "opus", Width: 1920, Height: 1080},
+			Profile{"video/mp4", ""}},
+		{"mp4 hevc has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "hevc", VideoProfile: "Main", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/mp4", ""}},
+		{"mp4 mpeg4 simple", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "mpeg4", VideoProfile: "Simple Profile", AudioCodec: "aac", Width: 352, Height: 288},
+			Profile{"video/mp4", "MPEG4_P2_MP4_SP_AAC"}},
+		{"mp4 mpeg4 simple above cif", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "mpeg4", VideoProfile: "Simple Profile", AudioCodec: "aac", Width: 640, Height: 480},
+			Profile{"video/mp4", "MPEG4_P2_MP4_ASP_AAC"}},
+		{"mp4 mpeg4 advanced simple", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "mpeg4", VideoProfile: "Advanced Simple Profile", AudioCodec: "aac", Width: 640, Height: 480},
+			Profile{"video/mp4", "MPEG4_P2_MP4_ASP_AAC"}},
+		{"mp4 mpeg4 mp3 has no profile", Media{Format: formatMP4, Ext: ".mp4", VideoCodec: "mpeg4", VideoProfile: "Simple Profile", AudioCodec: "mp3", Width: 640, Height: 480},
+			Profile{"video/mp4", ""}},
+		{"mov", Media{Format: formatMP4, Ext: ".MOV", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/quicktime", ""}},
+		{"3gp", Media{Format: formatMP4, Ext: ".3gp", VideoCodec: "h263", AudioCodec: "amr_nb", Width: 176, Height: 144},
+			Profile{"video/3gpp", ""}},
+
+		// MPEG transport stream: .ts is 188-byte packets (_ISO), .m2ts and .mts are 192-byte packets (_T)
+		{"ts mpeg2 pal", Media{Format: formatTS, Ext: ".ts", VideoCodec: "mpeg2video", VideoProfile: "Main", AudioCodec: "mp2", Width: 720, Height: 576},
+			Profile{"video/mpeg", "MPEG_TS_SD_EU_ISO"}},
+		{"ts mpeg2 ntsc", Media{Format: formatTS, Ext: ".ts", VideoCodec: "mpeg2video", VideoProfile: "Main", AudioCodec: "ac3", Width: 720, Height: 480},
+			Profile{"video/mpeg", "MPEG_TS_SD_NA_ISO"}},
+		{"ts mpeg2 hd", Media{Format: formatTS, Ext: ".ts", VideoCodec: "mpeg2video", VideoProfile: "Main", AudioCodec: "ac3", Width: 1920, Height: 1080},
+			Profile{"video/mpeg", "MPEG_TS_HD_NA_ISO"}},
+		{"ts mpeg2 hd above atsc bitrate has no profile", Media{Format: formatTS, Ext: ".ts", VideoCodec: "mpeg2video", AudioCodec: "ac3", Width: 1920, Height: 1080, Bitrate: 25_000_000},
+			Profile{"video/mpeg", ""}},
+		{"ts mpeg2 aac has no profile", Media{Format: formatTS, Ext: ".ts", VideoCodec: "mpeg2video", AudioCodec: "aac", Width: 720, Height: 576},
+			Profile{"video/mpeg", ""}},
+		{"ts h264 main aac", Media{Format: formatTS, Ext: ".ts", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/mpeg", "AVC_TS_MP_HD_AAC_MULT5_ISO"}},
+		{"ts h264 high ac3", Media{Format: formatTS, Ext: ".ts", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "ac3", Width: 1920, Height: 1080, Bitrate: 15_000_000},
+			Profile{"video/mpeg", "AVC_TS_HP_HD_AC3_ISO"}},
+		{"ts h264 high aac has no profile", Media{Format: formatTS, Ext: ".ts", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "aac", Width: 1920, Height: 1080},
+			Profile{"video/mpeg", ""}},
+		{"ts h264 dts has no profile", Media{Format: formatTS, Ext: ".ts", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "dts", Width: 1920, Height: 1080},
+			Profile{"video/mpeg", ""}},
+		{"m2ts h264", Media{Format: formatTS, Ext: ".m2ts", VideoCodec: "h264", VideoProfile: "High", AudioCodec: "ac3", Width: 1920, Height: 1080},
+			Profile{"video/vnd.dlna.mpeg-tts", "AVC_TS_HP_HD_AC3_T"}},
+		{"mts h264 sd", Media{Format: formatTS, Ext: "/video/clip.MTS", VideoCodec: "h264", VideoProfile: "Main", AudioCodec: "ac3", Width: 720, Height: 576},
+			Profile{"video/vnd.dlna.mpeg-tts", "AVC_TS_MP_SD_AC3_T"}},
+		{"m2ts mpeg2", Media{Format: formatTS, Ext: ".m2ts", VideoCodec: "mpeg2video", AudioCodec: "ac3", Width: 1920, Height: 1080},
+			Profile{"video/vnd.dlna.mpeg-tts", "MPEG_TS_HD_NA_T"}},
+
+		// MPEG program stream (.mpg, .vob) and elementary stream
+		{"ps mpeg2 pal", Media{Format: formatPS, Ext: ".mpg", VideoCodec: "mpeg2video", AudioCodec: "mp2", Width: 720, Height: 576},
+			Profile{"video/mpeg", "MPEG_PS_PAL"}},
+		{"ps mpeg2 pal half", Media{Format: formatPS, Ext: ".mpg", VideoCodec: "mpeg2video", AudioCodec: "mp2", Width: 352, Height: 288},
+			Profile{"video/mpeg", "MPEG_PS_PAL"}},
+		{"ps mpeg2 ntsc", Media{Format: formatPS, Ext: ".vob", VideoCodec: "mpeg2video", AudioCodec: "ac3", Width: 720, Height: 480},
+			Profile{"video/mpeg", "MPEG_PS_NTSC"}},
+		{"ps mpeg1", Media{Format: formatPS, Ext: ".mpg", VideoCodec: "mpeg1video", AudioCodec: "mp2", Width: 352, Height: 240},
+			Profile{"video/mpeg", "MPEG1"}},
+		{"es mpeg2 ntsc", Media{Format: formatES, Ext: ".m2v", VideoCodec: "mpeg2video", Width: 720, Height: 480},
+			Profile{"video/mpeg", "MPEG_PS_NTSC"}},
+		{"ps h264 has no profile", Media{Format: formatPS, Ext: ".mpg", VideoCodec: "h264", AudioCodec: "mp2", Width: 720, Height: 576},
+			Profile{"video/mpeg", ""}},
+
+		// windows media
+		{"asf wmv3 sd", Media{Format: formatASF, Ext: ".wmv", VideoCodec: "wmv3", AudioCodec: "wmav2", Width: 720, Height: 480},
+			Profile{"video/x-ms-wmv", "WMVMED_FULL"}},
+		{"asf wmv3 hd wma pro", Media{Format: formatASF, Ext: ".wmv", VideoCodec: "wmv3", AudioCodec: "wmapro", Width: 1280, Height: 720},
+			Profile{"video/x-ms-wmv", "WMVHIGH_PRO"}},
+		{"asf vc1 has no profile", Media{Format: formatASF, Ext: ".wmv", VideoCodec: "vc1", AudioCodec: "wmapro", Width: 1280, Height: 720},
+			Profile{"video/x-ms-wmv", ""}},
+		{"asf msmpeg4 has no profile", Media{Format: formatASF, Ext: ".asf", VideoCodec: "msmpeg4v3", AudioCodec: "wmav2", Width: 640, Height: 480},
+			Profile{"video/x-ms-wmv", ""}},
+
+		// formats without DLNA profiles
+		{"avi", Media{Format: "avi", Ext: ".avi", VideoCodec: "mpeg4", AudioCodec: "mp3", Width: 640, Height: 480},
+			Profile{"video/x-msvideo", ""}},
+		{"flv", Media{Format: "flv", Ext: ".flv", VideoCodec: "h264", AudioCodec: "aac", Width: 640, Height: 360},
+			Profile{"video/x-flv", ""}},
+		{"ogg", Media{Format: "ogg", Ext: ".ogv", VideoCodec: "theora", AudioCodec: "vorbis", Width: 640, Height: 360},
+			Profile{"video/ogg", ""}},
+		{"unknown", Media{Format: "rm", Ext: ".rm"},
+			Profile{"video/mpeg", ""}},
+	}
+
+	all := All()
+	for _, tt := range tests {
+		got := Detect(tt.media)
+		if got != tt.want {
+			t.Errorf("%s: Detect() = %+v; want %+v", tt.name, got, tt.want)
+		}
+		if !slices.Contains(all, got) {
+			t.Errorf("%s: %+v is not listed by All()", tt.name, got)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	seen := make(map[Profile]bool)
+	for _, p := range All() {
+		if seen[p] {
+			t.Errorf("%+v is listed twice", p)
+		}
+		seen[p] = true
+	}
+	// every listed name is a name of the table, with packet size suffix for MPEG-TS
+	for p := range seen {
+		if p.PN == "" {
+			continue
+		}
+		found := false
+		for _, r := range pnRules {
+			found = found || p.PN == r.pn ||
+				r.container == containerTS && (p.PN == r.pn+"_ISO" || p.PN == r.pn+"_T")
+		}
+		if !found {
+			t.Errorf("%+v is not in the table", p)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package dlnaprofile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flags is a value of DLNA.ORG_FLAGS, primary flags (32 bits) are followed by 96 reserved zero bits
+type Flags uint32
+
+const (
+	FlagSenderPaced         Flags = 1 << 31
+	FlagLimitedTimeSeek     Flags = 1 << 30
+	FlagLimitedByteSeek     Flags = 1 << 29
+	FlagPlayContainer       Flags = 1 << 28
+	FlagS0Increase          Flags = 1 << 27
+	FlagSNIncrease          Flags = 1 << 26
+	FlagRtspPause           Flags = 1 << 25
+	FlagStreamingTransfer   Flags = 1 << 24
+	FlagInteractiveTransfer Flags = 1 << 23
+	FlagBackgroundTransfer  Flags = 1 << 22
+	FlagConnectionStall     Flags = 1 << 21
+	FlagDlnaV15             Flags = 1 << 20
+)
+
+const (
+	defaultStreamingFlags   = FlagStreamingTransfer | FlagBackgroundTransfer | FlagConnectionStall | FlagDlnaV15
+	defaultInteractiveFlags = FlagInteractiveTransfer | FlagBackgroundTransfer | FlagConnectionStall | FlagDlnaV15
+)
+
+func (f Flags) String() string {
+	return fmt.Sprintf("%08x%024x", uint32(f), 0)
+}
+
+// ProtocolInfo is a protocolInfo of res element: "http-get:*:MIME:DLNA.ORG_PN=...;DLNA.ORG_OP=..."
+type ProtocolInfo struct {
+	Profile
+
+	// TimeSeek server supports TimeSeekRange.dlna.org header
+	TimeSeek bool
+
+	// ByteSeek server supports Range header
+	ByteSeek bool
+
+	// Transcoded content is converted from original (DLNA.ORG_CI=1)
+	Transcoded bool
+
+	Flags Flags
+}
+
+// Video returns protocol info of streamed video file
+func Video(p Profile, timeSeek bool, byteSeek bool) ProtocolInfo {
+	return ProtocolInfo{Profile: p, TimeSeek: timeSeek, ByteSeek: byteSeek, Flags: defaultStreamingFlags}
+}
+
+// Transcoded returns protocol info of video transcoded on the fly, it has no size and can be seeked by time only
+func Transcoded(p Profile, timeSeek bool) ProtocolInfo {
+	flags := FlagStreamingTransfer | FlagBackgroundTransfer | FlagDlnaV15
+	return ProtocolInfo{Profile: p, TimeSeek: timeSeek, Transcoded: true, Flags: flags}
+}
+
+// Thumbnail returns protocol info of JPEG thumbnail
+func Thumbnail() ProtocolInfo {
+	return ProtocolInfo{Profile: Profile{MimeType: "image/jpeg", PN: "JPEG_TN"}, Flags: defaultInteractiveFlags}
+}
+
+// ContentFeatures returns value of contentFeatures.dlna.org header (4th field of protocolInfo)
+func (p ProtocolInfo) ContentFeatures() string {
+	params := make([]string, 0, 4)
+	if p.PN != "" {
+		params = append(params, "DLNA.ORG_PN="+p.PN)
+	}
+	if p.TimeSeek || p.ByteSeek {
+		params = append(params, "DLNA.ORG_OP="+seekFlag(p.TimeSeek)+seekFlag(p.ByteSeek))
+	}
+	if p.Transcoded {
+		params = append(params, "DLNA.ORG_CI=1")
+	}
+	params = append(params, "DLNA.ORG_FLAGS="+p.Flags.String())
+	return strings.Join(params, ";")
+}
+
+func (p ProtocolInfo) String() string {
+	return "http-get:*:" + p.MimeType + ":" + p.ContentFeatures()
+}
+
+func seekFlag(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// Source returns protocolInfo of Profile as it is listed in ConnectionManager's SourceProtocolInfo,
+// "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC" or "http-get:*:video/x-msvideo:*"
+func (p Profile) Source() string {
+	if p.PN == "" {
+		return "http-get:*:" + p.MimeType + ":*"
+	}
+	return "http-get:*:" + p.MimeType + ":DLNA.ORG_PN=" + p.PN
+}
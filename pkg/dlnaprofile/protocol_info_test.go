@@ -0,0 +1,47 @@
+package dlnaprofile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentFeatures(t *testing.T) {
+	const reserved = "000000000000000000000000"
+	mkv := Profile{MimeType: "video/x-matroska", PN: "MKV"}
+	tts := Profile{MimeType: "video/vnd.dlna.mpeg-tts", PN: "AVC_TS_HP_HD_AC3_T"}
+	avi := Profile{MimeType: "video/x-msvideo"}
+
+	tests := []struct {
+		name string
+		info ProtocolInfo
+		want string
+	}{
+		{"byte seek", Video(mkv, false, true), "DLNA.ORG_PN=MKV;DLNA.ORG_OP=01;DLNA.ORG_FLAGS=01700000" + reserved},
+		{"time and byte seek", Video(tts, true, true), "DLNA.ORG_PN=AVC_TS_HP_HD_AC3_T;DLNA.ORG_OP=11;DLNA.ORG_FLAGS=01700000" + reserved},
+		{"without profile", Video(avi, false, true), "DLNA.ORG_OP=01;DLNA.ORG_FLAGS=01700000" + reserved},
+		{"without seek", Video(avi, false, false), "DLNA.ORG_FLAGS=01700000" + reserved},
+		{"transcoded", Transcoded(tts, true), "DLNA.ORG_PN=AVC_TS_HP_HD_AC3_T;DLNA.ORG_OP=10;DLNA.ORG_CI=1;DLNA.ORG_FLAGS=01500000" + reserved},
+		{"transcoded without seek", Transcoded(tts, false), "DLNA.ORG_PN=AVC_TS_HP_HD_AC3_T;DLNA.ORG_CI=1;DLNA.ORG_FLAGS=01500000" + reserved},
+		{"thumbnail", Thumbnail(), "DLNA.ORG_PN=JPEG_TN;DLNA.ORG_FLAGS=00f00000" + reserved},
+	}
+	for _, tt := range tests {
+		if got := tt.info.ContentFeatures(); got != tt.want {
+			t.Errorf("%s: ContentFeatures() = %s; want %s", tt.name, got, tt.want)
+		}
+		if got := tt.info.String(); got != "http-get:*:"+tt.info.MimeType+":"+tt.want {
+			t.Errorf("%s: String() = %s", tt.name, got)
+		}
+		if flags := tt.want[strings.LastIndex(tt.want, "=")+1:]; len(flags) != 32 {
+			t.Errorf("%s: DLNA.ORG_FLAGS should be 32 hex digits: %s", tt.name, flags)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	if got := (Profile{MimeType: "video/mp4", PN: "AVC_MP4_MP_HD_AAC"}).Source(); got != "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC" {
+		t.Errorf("Source() = %s", got)
+	}
+	if got := (Profile{MimeType: "video/x-msvideo"}).Source(); got != "http-get:*:video/x-msvideo:*" {
+		t.Errorf("Source() = %s", got)
+	}
+}
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"os/exec"
 	"time"
+
+	"github.com/szonov/godlna/pkg/metrics"
 )
 
 var binPath = "ffmpeg"
 
+var execDuration = metrics.NewHistogram("godlna_ffmpeg_duration_seconds",
+	"Duration of ffmpeg invocations, by operation (frame, transcode).",
+	metrics.ExecBuckets, "operation")
+
 // SetBinPath sets the global path to find and execute the `ffmpeg` program
 func SetBinPath(path string) {
 	binPath = path
@@ -44,6 +50,7 @@ func DurationToString(dur time.Duration) string {
 func GetVideoFrame(src string, timeToSeek time.Duration) ([]byte, error) {
 	ss := DurationToString(timeToSeek)
 	args := []string{"-ss", ss, "-i", src, "-y", "-r", "1", "-vframes", "1", "-an", "-loglevel", "panic", "-f", "mjpeg", "pipe:1"}
+	defer execDuration.ObserveSince(time.Now(), "frame")
 	cmd := exec.Command(binPath, args...)
 	return cmd.Output()
 }
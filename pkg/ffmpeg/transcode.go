@@ -0,0 +1,115 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CopyCodec is a codec name which means "copy stream without transcoding"
+const CopyCodec = "copy"
+
+type transcodeConfig struct {
+	videoCodec string
+	audioCodec string
+	seek       time.Duration
+	maxHeight  int
+}
+
+var defaultTranscodeConfig = transcodeConfig{
+	videoCodec: "libx264",
+	audioCodec: "ac3",
+}
+
+// TranscodeOption sets an optional parameter for the transcoding.
+type TranscodeOption func(*transcodeConfig)
+
+// TranscodeVideo returns an TranscodeOption that sets the output video codec, CopyCodec keeps the source stream.
+// Default is libx264.
+func TranscodeVideo(codec string) TranscodeOption {
+	return func(c *transcodeConfig) {
+		c.videoCodec = codec
+	}
+}
+
+// TranscodeAudio returns an TranscodeOption that sets the output audio codec, CopyCodec keeps the source stream.
+// Default is ac3.
+func TranscodeAudio(codec string) TranscodeOption {
+	return func(c *transcodeConfig) {
+		c.audioCodec = codec
+	}
+}
+
+// TranscodeSeek returns an TranscodeOption that sets the start position in the source file.
+func TranscodeSeek(seek time.Duration) TranscodeOption {
+	return func(c *transcodeConfig) {
+		c.seek = seek
+	}
+}
+
+// TranscodeMaxHeight returns an TranscodeOption that limits the output video height, video is scaled down
+// keeping aspect ratio. Ignored when video is copied.
+func TranscodeMaxHeight(height int) TranscodeOption {
+	return func(c *transcodeConfig) {
+		c.maxHeight = height
+	}
+}
+
+func transcodeArgs(src string, c transcodeConfig) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	if c.seek > 0 {
+		args = append(args, "-ss", DurationToString(c.seek))
+	}
+	args = append(args, "-i", src, "-map", "0:v:0", "-map", "0:a:0?", "-sn", "-dn")
+
+	// mpegts muxer inserts mp4toannexb bitstream filters itself when h264/hevc is copied
+	args = append(args, "-c:v", c.videoCodec)
+	if c.videoCodec != CopyCodec {
+		args = append(args, "-preset", "veryfast", "-crf", "21", "-pix_fmt", "yuv420p")
+		if c.maxHeight > 0 {
+			args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", c.maxHeight))
+		}
+	}
+
+	args = append(args, "-c:a", c.audioCodec)
+	if c.audioCodec != CopyCodec {
+		args = append(args, "-ac", "2", "-b:a", "192k")
+	}
+
+	return append(args, "-f", "mpegts", "-muxdelay", "0", "pipe:1")
+}
+
+// Transcode converts src file to MPEG-TS stream and writes it to w, ffmpeg process is killed when ctx is done.
+// Returns nil when ctx is cancelled, as it is usual way to stop transcoding (client closed connection).
+func Transcode(ctx context.Context, src string, w io.Writer, opts ...TranscodeOption) error {
+	c := defaultTranscodeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	stderr := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, binPath, transcodeArgs(src, c)...)
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 5 * time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	execDuration.ObserveSince(start, "transcode")
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return fmt.Errorf("ffmpeg: %s: %w", strings.TrimSpace(stderr.String()), err)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
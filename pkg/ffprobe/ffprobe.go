@@ -7,10 +7,16 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/szonov/godlna/pkg/metrics"
 )
 
 var binPath = "ffprobe"
 
+var execDuration = metrics.NewHistogram("godlna_ffprobe_duration_seconds",
+	"Duration of ffprobe invocations, by operation (probe, duration).",
+	metrics.ExecBuckets, "operation")
+
 // SetBinPath sets the global path to find and execute the `ffprobe` program
 func SetBinPath(path string) {
 	binPath = path
@@ -33,18 +39,91 @@ func Autodetect() bool {
 }
 
 type Stream struct {
-	CodecType  string `json:"codec_type"`
-	CodecName  string `json:"codec_name"`
-	SampleRate uint   `json:"sample_rate,string"`
-	Channels   uint   `json:"channels"`
-	Width      uint   `json:"width"`
-	Height     uint   `json:"height"`
+	Index          int               `json:"index"`
+	CodecType      string            `json:"codec_type"`
+	CodecName      string            `json:"codec_name"`
+	Profile        string            `json:"profile"`
+	PixFmt         string            `json:"pix_fmt"`
+	SampleRate     uint              `json:"sample_rate,string"`
+	Channels       uint              `json:"channels"`
+	Width          uint              `json:"width"`
+	Height         uint              `json:"height"`
+	BitRate        uint              `json:"bit_rate,string"`
+	FrameRate      string            `json:"avg_frame_rate"`
+	ColorTransfer  string            `json:"color_transfer"`
+	ColorPrimaries string            `json:"color_primaries"`
+	Disposition    map[string]int    `json:"disposition"`
+	Tags           map[string]string `json:"tags"`
+	SideDataList   []SideData        `json:"side_data_list"`
+}
+
+type SideData struct {
+	Type     string `json:"side_data_type"`
+	Rotation int    `json:"rotation"`
 }
 
 func (s Stream) Resolution() string {
 	return fmt.Sprintf("%dx%d", s.Width, s.Height)
 }
 
+// Tag returns value of stream tag, tag name is case-insensitive ("title" and "TITLE" are the same)
+func (s Stream) Tag(name string) string {
+	for k, v := range s.Tags {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s Stream) Language() string {
+	if lang := s.Tag("language"); lang != "und" {
+		return lang
+	}
+	return ""
+}
+
+func (s Stream) Title() string {
+	return s.Tag("title")
+}
+
+func (s Stream) IsDefault() bool {
+	return s.Disposition["default"] == 1
+}
+
+func (s Stream) IsForced() bool {
+	return s.Disposition["forced"] == 1
+}
+
+// Rotation returns rotation of video in degrees from display matrix side data
+func (s Stream) Rotation() int {
+	for _, sd := range s.SideDataList {
+		if sd.Type == "Display Matrix" {
+			return sd.Rotation
+		}
+	}
+	if v, err := strconv.Atoi(s.Tag("rotate")); err == nil {
+		return v
+	}
+	return 0
+}
+
+// HDR returns HDR format of video stream: DolbyVision, HDR10, HLG or empty string for SDR video
+func (s Stream) HDR() string {
+	for _, sd := range s.SideDataList {
+		if sd.Type == "DOVI configuration record" {
+			return "DolbyVision"
+		}
+	}
+	switch s.ColorTransfer {
+	case "smpte2084":
+		return "HDR10"
+	case "arib-std-b67":
+		return "HLG"
+	}
+	return ""
+}
+
 type Format struct {
 	Name     string
 	Duration time.Duration
@@ -98,12 +177,13 @@ func (d *Data) FirstAudioStream() *Stream {
 
 func Probe(src string) (data *Data, err error) {
 	args := []string{
-		"-i", src, "-show_entries",
-		"stream=index,codec_type,codec_name,sample_rate,channels,width,height : format=format_name,duration,size,bit_rate",
+		"-i", src, "-show_streams", "-show_entries", "format=format_name,duration,size,bit_rate",
 		"-of", "json", "-hide_banner", "-loglevel", "panic",
 	}
 	var b []byte
+	start := time.Now()
 	b, err = exec.Command(binPath, args...).Output()
+	execDuration.ObserveSince(start, "probe")
 	if err != nil {
 		return
 	}
@@ -121,7 +201,9 @@ func Duration(src string) (time.Duration, error) {
 		"-of", "default=noprint_wrappers=1:nokey=1",
 		src)
 
+	start := time.Now()
 	out, err := cmd.Output()
+	execDuration.ObserveSince(start, "duration")
 	if err != nil {
 		return 0, fmt.Errorf("(ffprobe) can not get duration: %w", err)
 	}
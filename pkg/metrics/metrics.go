@@ -0,0 +1,341 @@
+// Package metrics is a minimal implementation of Prometheus metrics (counters, gauges and histograms
+// with labels) and text exposition format, without external dependencies.
+//
+// Metrics are registered in the Default registry on creation, usually as package level variables:
+//
+//	var requests = metrics.NewCounter("app_requests_total", "Amount of requests.", "method")
+//
+//	requests.Inc("GET")
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are default buckets of histograms (seconds), suitable for durations of http requests
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExecBuckets are buckets of histograms (seconds), suitable for durations of external commands
+var ExecBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 1800}
+
+// Default is a registry used by NewCounter, NewGauge, NewHistogram and Handler
+var Default = NewRegistry()
+
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry is a list of metrics exposed together
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds metric to the registry, panics on duplicate name, as it is a programming error
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic(fmt.Sprintf("metrics: duplicate metric '%s'", c.name()))
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes all metrics in Prometheus text format, metrics are ordered by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	list := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name() < list[j].name()
+	})
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range list {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns http handler exposing metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Handler returns http handler exposing metrics of the Default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// family is a common part of metrics: name, help, label names and series by label values
+type family struct {
+	mu         sync.Mutex
+	metricName string
+	help       string
+	typ        string
+	labelNames []string
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+
+	// histogram only
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newFamily(name, help, typ string, labelNames []string) family {
+	return family{
+		metricName: name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// get returns series of label values, should be called with locked mutex
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: '%s' expects %d label values, got %d", f.metricName, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sorted returns copy of all series ordered by label values, should be called with locked mutex
+func (f *family) sorted() []series {
+	list := make([]series, 0, len(f.series))
+	for _, s := range f.series {
+		item := *s
+		item.buckets = slices.Clone(s.buckets)
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return slices.Compare(list[i].labelValues, list[j].labelValues) < 0
+	})
+	return list
+}
+
+func (f *family) writeHeader(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, f.typ)
+}
+
+// labels formats label pairs: {name="value",...}, extra is appended as is (used for "le" of histograms)
+func (f *family) labels(values []string, extra string) string {
+	if len(values) == 0 && extra == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, f.labelNames[i]+`="`+escapeLabel(v)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric which value only goes up
+type Counter struct {
+	family
+}
+
+// NewCounter creates counter and registers it in the Default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labelNames)}
+	Default.register(c)
+	return c
+}
+
+// Inc increments counter of given label values by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments counter of given label values by v, negative values are ignored
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues).value += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	list := c.sorted()
+	c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, s := range list {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labels(s.labelValues, ""), formatFloat(s.value))
+	}
+}
+
+// Gauge is a metric which value can go up and down
+type Gauge struct {
+	family
+}
+
+// NewGauge creates gauge and registers it in the Default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labelNames)}
+	Default.register(g)
+	return g
+}
+
+// Set sets value of the gauge of given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value = v
+}
+
+// Add adds v (can be negative) to the gauge of given label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(labelValues).value += v
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	list := g.sorted()
+	g.mu.Unlock()
+
+	g.writeHeader(w)
+	for _, s := range list {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labels(s.labelValues, ""), formatFloat(s.value))
+	}
+}
+
+// Histogram counts observations (durations, sizes) in configurable buckets
+type Histogram struct {
+	family
+	upperBounds []float64
+}
+
+// NewHistogram creates histogram and registers it in the Default registry, buckets are upper bounds
+// in increasing order, +Inf bucket is added automatically
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, "histogram", labelNames), upperBounds: slices.Clone(buckets)}
+	slices.Sort(h.upperBounds)
+	Default.register(h)
+	return h
+}
+
+// Observe adds observation to the histogram of given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.upperBounds))
+	}
+	if i := sort.SearchFloat64s(h.upperBounds, v); i < len(h.upperBounds) {
+		s.buckets[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// ObserveSince adds time elapsed since start (in seconds) to the histogram of given label values
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	list := h.sorted()
+	h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, s := range list {
+		var cumulative uint64
+		for i, bound := range h.upperBounds {
+			cumulative += s.buckets[i]
+			le := `le="` + formatFloat(bound) + `"`
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, le), cumulative)
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, `le="+Inf"`), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels(s.labelValues, ""), formatFloat(s.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels(s.labelValues, ""), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
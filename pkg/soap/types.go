@@ -19,6 +19,9 @@ type (
 	DIDLLite struct {
 		Debug bool
 		Items []interface{}
+
+		// NamedEntities use &quot; and &apos; instead of &#34; and &#39;, needed for old Samsung TVs
+		NamedEntities bool
 	}
 
 	XMLLite string
@@ -52,9 +55,11 @@ func (v *DIDLLite) XMLBody() string {
 		slog.Error(err.Error())
 	}
 
-	// needed for old Samsung TVs
-	res := strings.Replace(string(result), "&#34;", "&quot;", -1)
-	res = strings.Replace(res, "&#39;", "&apos;", -1)
+	res := string(result)
+	if v.NamedEntities {
+		res = strings.Replace(res, "&#34;", "&quot;", -1)
+		res = strings.Replace(res, "&#39;", "&apos;", -1)
+	}
 
 	return `<DIDL-Lite` +
 		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
@@ -0,0 +1,120 @@
+// Package subtitles converts SubRip (.srt) subtitles to SAMI (.smi), the only external subtitles format
+// supported by old Samsung TVs
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var srtTimingRegexp = regexp.MustCompile(`(\d+):(\d{2}):(\d{2})[,.](\d{1,3})\s*-->\s*(\d+):(\d{2}):(\d{2})[,.](\d{1,3})`)
+
+// Cue is one subtitle, Start and End in milliseconds
+type Cue struct {
+	Start int64
+	End   int64
+	Lines []string
+}
+
+// ParseSrt reads SubRip subtitles, broken cues are skipped
+func ParseSrt(r io.Reader) ([]Cue, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	cues := make([]Cue, 0)
+	var cur *Cue
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		if m := srtTimingRegexp.FindStringSubmatch(line); m != nil {
+			if cur != nil {
+				cues = append(cues, *cur)
+			}
+			cur = &Cue{Start: srtTime(m[1:5]), End: srtTime(m[5:9])}
+			continue
+		}
+		if cur == nil {
+			// cue number or garbage before the first timing line
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			cues = append(cues, *cur)
+			cur = nil
+			continue
+		}
+		cur.Lines = append(cur.Lines, line)
+	}
+	if cur != nil {
+		cues = append(cues, *cur)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("(subtitles) failed to read srt: %w", err)
+	}
+
+	// cue number of the next cue is read as text line of the previous one when cues are not separated
+	// by empty line, drop trailing numeric lines
+	for i := range cues {
+		lines := cues[i].Lines
+		for len(lines) > 0 {
+			if _, err := strconv.Atoi(strings.TrimSpace(lines[len(lines)-1])); err != nil || i == len(cues)-1 {
+				break
+			}
+			lines = lines[:len(lines)-1]
+		}
+		cues[i].Lines = lines
+	}
+	return cues, nil
+}
+
+func srtTime(m []string) int64 {
+	h, _ := strconv.ParseInt(m[0], 10, 64)
+	mi, _ := strconv.ParseInt(m[1], 10, 64)
+	s, _ := strconv.ParseInt(m[2], 10, 64)
+	ms, _ := strconv.ParseInt((m[3] + "00")[:3], 10, 64)
+	return ((h*60+mi)*60+s)*1000 + ms
+}
+
+// WriteSami writes cues in SAMI format, lang is ISO 639-1 code used in class name, for example "en"
+func WriteSami(w io.Writer, cues []Cue, lang string) error {
+	if lang == "" {
+		lang = "en"
+	}
+	class := strings.ToUpper(lang) + "CC"
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("<SAMI>\r\n<HEAD>\r\n<TITLE></TITLE>\r\n<STYLE TYPE=\"text/css\">\r\n<!--\r\n")
+	buf.WriteString("P { margin-left:2pt; margin-right:2pt; margin-bottom:1pt; margin-top:1pt; text-align:center; }\r\n")
+	fmt.Fprintf(buf, ".%s { Name:%s; lang:%s; SAMIType:CC; }\r\n", class, lang, lang)
+	buf.WriteString("-->\r\n</STYLE>\r\n</HEAD>\r\n<BODY>\r\n")
+
+	for i, cue := range cues {
+		fmt.Fprintf(buf, "<SYNC Start=%d><P Class=%s>%s</P></SYNC>\r\n", cue.Start, class, strings.Join(cue.Lines, "<br>"))
+		// clear screen at the end of cue, if next cue does not start at the same time
+		if i == len(cues)-1 || cues[i+1].Start > cue.End {
+			fmt.Fprintf(buf, "<SYNC Start=%d><P Class=%s>&nbsp;</P></SYNC>\r\n", cue.End, class)
+		}
+	}
+	buf.WriteString("</BODY>\r\n</SAMI>\r\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// SrtToSami converts SubRip subtitles to SAMI
+func SrtToSami(r io.Reader, w io.Writer, lang string) error {
+	cues, err := ParseSrt(r)
+	if err != nil {
+		return err
+	}
+	return WriteSami(w, cues, lang)
+}
@@ -0,0 +1,107 @@
+package subtitles
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSrt(t *testing.T) {
+	tests := []struct {
+		name string
+		srt  string
+		want []Cue
+	}{
+		{"empty", "", []Cue{}},
+		{"one cue", "1\n00:00:01,000 --> 00:00:02,500\nHello\n",
+			[]Cue{{1000, 2500, []string{"Hello"}}}},
+		{"multiline and crlf", "1\r\n00:01:02,003 --> 01:00:00,000\r\nfirst line\r\n<i>second line</i>\r\n\r\n",
+			[]Cue{{62003, 3600000, []string{"first line", "<i>second line</i>"}}}},
+		{"bom", "\ufeff1\n00:00:01,000 --> 00:00:02,000\nText\n",
+			[]Cue{{1000, 2000, []string{"Text"}}}},
+		{"dot separator and short milliseconds", "1\n00:00:01.5 --> 00:00:02.25\nText\n",
+			[]Cue{{1500, 2250, []string{"Text"}}}},
+		{"without spaces around arrow", "1\n00:00:01,000-->00:00:02,000\nText\n",
+			[]Cue{{1000, 2000, []string{"Text"}}}},
+		{"hours over 99", "1\n100:00:00,000 --> 100:00:01,000\nText\n",
+			[]Cue{{360000000, 360001000, []string{"Text"}}}},
+		{"several cues", "1\n00:00:01,000 --> 00:00:02,000\nOne\n\n2\n00:00:03,000 --> 00:00:04,000\nTwo\n\n\n3\n00:00:05,000 --> 00:00:06,000\nThree",
+			[]Cue{{1000, 2000, []string{"One"}}, {3000, 4000, []string{"Two"}}, {5000, 6000, []string{"Three"}}}},
+		{"cues without empty line between", "1\n00:00:01,000 --> 00:00:02,000\nOne\n2\n00:00:03,000 --> 00:00:04,000\nTwo\n",
+			[]Cue{{1000, 2000, []string{"One"}}, {3000, 4000, []string{"Two"}}}},
+		{"numeric text of the last cue is kept", "1\n00:00:01,000 --> 00:00:02,000\n1984\n",
+			[]Cue{{1000, 2000, []string{"1984"}}}},
+		{"cue without text", "1\n00:00:01,000 --> 00:00:02,000\n\n2\n00:00:03,000 --> 00:00:04,000\nTwo\n",
+			[]Cue{{1000, 2000, nil}, {3000, 4000, []string{"Two"}}}},
+		{"garbage is skipped", "garbage\n\n1\n00:00:01 --> 00:00:02\nbroken\n\n2\n00:00:03,000 --> 00:00:04,000\nTwo\n",
+			[]Cue{{3000, 4000, []string{"Two"}}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSrt(strings.NewReader(tt.srt))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseSrt() = %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+const samiHead = "<SAMI>\r\n<HEAD>\r\n<TITLE></TITLE>\r\n<STYLE TYPE=\"text/css\">\r\n<!--\r\n" +
+	"P { margin-left:2pt; margin-right:2pt; margin-bottom:1pt; margin-top:1pt; text-align:center; }\r\n"
+
+func TestWriteSami(t *testing.T) {
+	tests := []struct {
+		name string
+		cues []Cue
+		lang string
+		want string
+	}{
+		{"no cues", nil, "",
+			".ENCC { Name:en; lang:en; SAMIType:CC; }\r\n-->\r\n</STYLE>\r\n</HEAD>\r\n<BODY>\r\n" +
+				"</BODY>\r\n</SAMI>\r\n"},
+		{"gap between cues", []Cue{{1000, 2000, []string{"One", "line"}}, {3000, 4000, []string{"Two"}}}, "ru",
+			".RUCC { Name:ru; lang:ru; SAMIType:CC; }\r\n-->\r\n</STYLE>\r\n</HEAD>\r\n<BODY>\r\n" +
+				"<SYNC Start=1000><P Class=RUCC>One<br>line</P></SYNC>\r\n" +
+				"<SYNC Start=2000><P Class=RUCC>&nbsp;</P></SYNC>\r\n" +
+				"<SYNC Start=3000><P Class=RUCC>Two</P></SYNC>\r\n" +
+				"<SYNC Start=4000><P Class=RUCC>&nbsp;</P></SYNC>\r\n" +
+				"</BODY>\r\n</SAMI>\r\n"},
+		{"adjacent cues", []Cue{{1000, 2000, []string{"One"}}, {2000, 3000, []string{"Two"}}}, "en",
+			".ENCC { Name:en; lang:en; SAMIType:CC; }\r\n-->\r\n</STYLE>\r\n</HEAD>\r\n<BODY>\r\n" +
+				"<SYNC Start=1000><P Class=ENCC>One</P></SYNC>\r\n" +
+				"<SYNC Start=2000><P Class=ENCC>Two</P></SYNC>\r\n" +
+				"<SYNC Start=3000><P Class=ENCC>&nbsp;</P></SYNC>\r\n" +
+				"</BODY>\r\n</SAMI>\r\n"},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		if err := WriteSami(&buf, tt.cues, tt.lang); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got, want := buf.String(), samiHead+tt.want; got != want {
+			t.Errorf("%s: WriteSami() =\n%q\nwant\n%q", tt.name, got, want)
+		}
+	}
+}
+
+func TestSrtToSami(t *testing.T) {
+	var buf strings.Builder
+	srt := "1\r\n00:00:01,000 --> 00:00:02,000\r\nПривет\r\n\r\n2\r\n00:00:02,000 --> 00:00:03,000\r\nмир\r\n"
+	if err := SrtToSami(strings.NewReader(srt), &buf, "ru"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<SYNC Start=1000><P Class=RUCC>Привет</P></SYNC>\r\n<SYNC Start=2000><P Class=RUCC>мир</P></SYNC>\r\n",
+		"<SYNC Start=3000><P Class=RUCC>&nbsp;</P></SYNC>\r\n</BODY>",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("SrtToSami() = %q; want to contain %q", buf.String(), want)
+		}
+	}
+	if strings.Count(buf.String(), "<SYNC") != 3 {
+		t.Errorf("SrtToSami() = %q; want 3 SYNC elements", buf.String())
+	}
+}
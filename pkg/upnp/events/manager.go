@@ -5,19 +5,34 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/szonov/godlna/pkg/metrics"
 )
 
 var (
 	CleanAfterVisits uint32 = 500
 )
 
+var (
+	subscribersGauge = metrics.NewGauge("godlna_gena_subscribers",
+		"Current GENA subscribers (including expired, but not cleaned yet), by service.",
+		"service")
+
+	notifyFailures = metrics.NewCounter("godlna_gena_notify_failures_total",
+		"Failed GENA event notifications, by service.",
+		"service")
+)
+
 type (
 	Manager struct {
 		subscribers sync.Map
 		visits      uint32
+		service     string
 	}
 
 	Subscriber struct {
@@ -51,6 +66,12 @@ func NewManager() *Manager {
 	}
 }
 
+// WithService sets name of the service, used as label of metrics
+func (m *Manager) WithService(service string) *Manager {
+	m.service = service
+	return m
+}
+
 func (m *Manager) Clean() {
 	slog.Debug("clean up expired subscribers")
 	m.subscribers.Range(func(sid, v interface{}) bool {
@@ -59,6 +80,17 @@ func (m *Manager) Clean() {
 		}
 		return true
 	})
+	m.updateMetrics()
+}
+
+// updateMetrics publishes amount of subscribers to metrics
+func (m *Manager) updateMetrics() {
+	count := 0
+	m.subscribers.Range(func(k, v interface{}) bool {
+		count++
+		return true
+	})
+	subscribersGauge.Set(float64(count), m.service)
 }
 
 func (m *Manager) checkForCleanup() {
@@ -117,6 +149,7 @@ func (m *Manager) Unsubscribe(sid string, nt string, callback string) int {
 	if _, ok := m.subscribers.LoadAndDelete(sid); !ok {
 		return http.StatusPreconditionFailed
 	}
+	m.updateMetrics()
 	return http.StatusOK
 }
 
@@ -131,6 +164,7 @@ func (m *Manager) newSubscribe(sid string, callback []url.URL, timeout time.Time
 		Timeout: timeout,
 		Seq:     0,
 	})
+	m.updateMetrics()
 	return http.StatusOK
 }
 
@@ -199,10 +233,12 @@ func (m *Manager) notifySubscriber(s Subscriber, body []byte) {
 	if s.IsExpired() {
 		// skip sending to expired subscribers
 		m.subscribers.Delete(s.SID)
+		m.updateMetrics()
 		return
 	}
 	for _, u := range s.URLs {
 		if err := SendNotification(s.SID, s.Seq, u, body); err != nil {
+			notifyFailures.Inc(m.service)
 			slog.Debug("Failed to send notification",
 				slog.String("err", err.Error()),
 				slog.String("to", u.String()),
@@ -213,9 +249,20 @@ func (m *Manager) notifySubscriber(s Subscriber, body []byte) {
 }
 
 func (m *Manager) Dump() {
-	m.subscribers.Range(func(k, v interface{}) bool {
-		ss := v.(Subscriber)
+	for _, ss := range m.Subscribers() {
 		slog.Info("SUBSCRIBER", "sid", ss.SID, "seq", ss.Seq, "urls", ss.URLs, "expired", ss.IsExpired())
+	}
+}
+
+// Subscribers returns copy of all subscribers (including expired, but not cleaned yet) ordered by SID
+func (m *Manager) Subscribers() []Subscriber {
+	list := make([]Subscriber, 0)
+	m.subscribers.Range(func(k, v interface{}) bool {
+		list = append(list, v.(Subscriber))
 		return true
 	})
+	slices.SortFunc(list, func(a, b Subscriber) int {
+		return strings.Compare(a.SID, b.SID)
+	})
+	return list
 }
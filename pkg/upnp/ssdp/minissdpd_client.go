@@ -110,6 +110,12 @@ func (s *MinissdpdClient) minissdpdNotify() error {
 	return nil
 }
 
+// Announce sends ByeBye and Alive messages, for example after change of device description
+func (s *MinissdpdClient) Announce() {
+	s.sendByeBye()
+	s.sendAlive()
+}
+
 // sendAlive submit to minissdpd and write Alive message to udp connection
 func (s *MinissdpdClient) sendAlive() {
 	if err := s.minissdpdNotify(); err != nil {
@@ -10,4 +10,7 @@ const (
 type Server interface {
 	Start() error
 	Stop() error
+	// Announce sends ByeBye and then Alive messages out of schedule,
+	// control points forget cached device description and fetch it again
+	Announce()
 }
@@ -9,9 +9,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/szonov/godlna/pkg/metrics"
 	"golang.org/x/net/ipv4"
 )
 
+var msearchAnswered = metrics.NewCounter("godlna_ssdp_msearch_answered_total",
+	"M-SEARCH requests answered, by search target (ST).",
+	"st")
+
 type UdpServer struct {
 	// [page 12] To limit network congestion, the time-to-live (TTL) of each
 	// IP packet for each multicast message should default to 4 and should be configurable.
@@ -195,6 +200,7 @@ func (s *UdpServer) parseUdpMessage(buf []byte, sender *net.UDPAddr) {
 	}
 
 	slog.Debug("ssdp:discover", slog.String("st", st), slog.String("sender", sender.String()))
+	msearchAnswered.Inc(st)
 
 	for _, target := range targets {
 		msg := s.o.MSearchResponseMessage(target)
@@ -222,6 +228,16 @@ func (s *UdpServer) send(msg []byte, to *net.UDPAddr, delay ...time.Duration) {
 	}
 }
 
+// Announce sends ByeBye and Alive messages, for example after change of device description
+func (s *UdpServer) Announce() {
+	if s.udpConn == nil {
+		// not started yet, Alive is sent on start
+		return
+	}
+	s.sendByeBye()
+	s.sendAlive()
+}
+
 // sendAlive write Alive message to udp connection
 func (s *UdpServer) sendAlive() {
 	for _, target := range s.o.AllTargets() {
@@ -0,0 +1,225 @@
+// Package searchcriteria implements parser of SearchCriteria argument of ContentDirectory Search action,
+// grammar is described in "ContentDirectory:1 Service Template", section 2.5.5:
+//
+//	searchCrit  ::= searchExp | asterisk
+//	searchExp   ::= relExp | searchExp logOp searchExp | '(' searchExp ')'
+//	logOp       ::= 'and' | 'or'
+//	relExp      ::= property binOp quotedVal | property existsOp boolVal
+//	binOp       ::= relOp | stringOp
+//	relOp       ::= '=' | '!=' | '<' | '<=' | '>' | '>='
+//	stringOp    ::= 'contains' | 'doesNotContain' | 'derivedfrom'
+//	existsOp    ::= 'exists'
+//	boolVal     ::= 'true' | 'false'
+//
+// 'and' has higher precedence than 'or', keywords are case-insensitive.
+package searchcriteria
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OpEqual          Operator = "="
+	OpNotEqual       Operator = "!="
+	OpLess           Operator = "<"
+	OpLessOrEqual    Operator = "<="
+	OpGreater        Operator = ">"
+	OpGreaterOrEqual Operator = ">="
+	OpContains       Operator = "contains"
+	OpDoesNotContain Operator = "doesNotContain"
+	OpDerivedFrom    Operator = "derivedfrom"
+)
+
+// Expr is a node of parsed search criteria: All, And, Or, Relation or Exists
+type Expr interface {
+	String() string
+}
+
+// All matches all objects, search criteria "*"
+type All struct{}
+
+// And matches when both Left and Right match
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+// Or matches when at least one of Left and Right matches
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+// Relation is comparison of Property with Value, for example `dc:title contains "Matrix"`
+type Relation struct {
+	Property string
+	Op       Operator
+	Value    string
+}
+
+// Exists checks presence (or absence) of Property, for example `upnp:genre exists true`
+type Exists struct {
+	Property string
+	Exists   bool
+}
+
+func (All) String() string {
+	return "*"
+}
+
+func (e And) String() string {
+	return "(" + e.Left.String() + " and " + e.Right.String() + ")"
+}
+
+func (e Or) String() string {
+	return "(" + e.Left.String() + " or " + e.Right.String() + ")"
+}
+
+func (e Relation) String() string {
+	return fmt.Sprintf("%s %s %s", e.Property, e.Op, quote(e.Value))
+}
+
+func (e Exists) String() string {
+	return fmt.Sprintf("%s exists %t", e.Property, e.Exists)
+}
+
+// SyntaxError describes invalid search criteria, Pos is a byte offset in the source string
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("(searchcriteria) %s at position %d", e.Msg, e.Pos)
+}
+
+// Parse parses search criteria, empty string is the same as "*"
+func Parse(s string) (Expr, error) {
+	if t := strings.TrimSpace(s); t == "" || t == "*" {
+		return All{}, nil
+	}
+
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected '%s'", tok.value)}
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, closing.unexpected("')'")
+		}
+		return expr, nil
+	case tokenWord:
+		return p.parseRelation(tok.value)
+	default:
+		return nil, tok.unexpected("property or '('")
+	}
+}
+
+func (p *parser) parseRelation(property string) (Expr, error) {
+	tok := p.next()
+
+	var op Operator
+	switch {
+	case tok.kind == tokenSymbol:
+		op = Operator(tok.value)
+	case tok.isKeyword("exists"):
+		val := p.next()
+		switch {
+		case val.isKeyword("true"):
+			return Exists{Property: property, Exists: true}, nil
+		case val.isKeyword("false"):
+			return Exists{Property: property, Exists: false}, nil
+		default:
+			return nil, val.unexpected("'true' or 'false'")
+		}
+	case tok.isKeyword(string(OpContains)):
+		op = OpContains
+	case tok.isKeyword(string(OpDoesNotContain)):
+		op = OpDoesNotContain
+	case tok.isKeyword(string(OpDerivedFrom)):
+		op = OpDerivedFrom
+	default:
+		return nil, tok.unexpected("operator")
+	}
+
+	val := p.next()
+	if val.kind != tokenString {
+		return nil, val.unexpected("quoted value")
+	}
+	return Relation{Property: property, Op: op, Value: val.value}, nil
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,131 @@
+package searchcriteria
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		criteria string
+		want     string
+	}{
+		// all
+		{"", "*"},
+		{"  *  ", "*"},
+
+		// relations
+		{`dc:title = "Matrix"`, `dc:title = "Matrix"`},
+		{`dc:title="Matrix"`, `dc:title = "Matrix"`},
+		{`res@size != "0"`, `res@size != "0"`},
+		{`dc:date < "2020-01-01"`, `dc:date < "2020-01-01"`},
+		{`dc:date <= "2020-01-01"`, `dc:date <= "2020-01-01"`},
+		{`dc:date > "2020-01-01"`, `dc:date > "2020-01-01"`},
+		{`dc:date >= "2020-01-01"`, `dc:date >= "2020-01-01"`},
+		{`dc:title contains "matrix"`, `dc:title contains "matrix"`},
+		{`dc:title CONTAINS "matrix"`, `dc:title contains "matrix"`},
+		{`dc:title doesNotContain "sample"`, `dc:title doesNotContain "sample"`},
+		{`dc:title doesnotcontain "sample"`, `dc:title doesNotContain "sample"`},
+		{`upnp:class derivedfrom "object.item.videoItem"`, `upnp:class derivedfrom "object.item.videoItem"`},
+		{`upnp:class DerivedFrom "object.item.videoItem"`, `upnp:class derivedfrom "object.item.videoItem"`},
+
+		// exists
+		{`upnp:genre exists true`, `upnp:genre exists true`},
+		{`upnp:genre exists FALSE`, `upnp:genre exists false`},
+
+		// quoted strings
+		{`dc:title = ""`, `dc:title = ""`},
+		{`dc:title = "The \"Matrix\""`, `dc:title = "The \"Matrix\""`},
+		{`dc:title = "C:\\Video"`, `dc:title = "C:\\Video"`},
+		{`dc:title = "a\b"`, `dc:title = "a\\b"`},
+		{`dc:title = "and or ( ) = exists"`, `dc:title = "and or ( ) = exists"`},
+		{`dc:title = "Фильм"`, `dc:title = "Фильм"`},
+
+		// precedence: and is stronger than or, both are left-associative
+		{`a = "1" or b = "2" and c = "3"`, `(a = "1" or (b = "2" and c = "3"))`},
+		{`a = "1" and b = "2" or c = "3"`, `((a = "1" and b = "2") or c = "3")`},
+		{`a = "1" and b = "2" and c = "3"`, `((a = "1" and b = "2") and c = "3")`},
+		{`a = "1" or b = "2" or c = "3"`, `((a = "1" or b = "2") or c = "3")`},
+		{`a = "1" AND b = "2" Or c = "3"`, `((a = "1" and b = "2") or c = "3")`},
+
+		// parens
+		{`(a = "1" or b = "2") and c = "3"`, `((a = "1" or b = "2") and c = "3")`},
+		{`a = "1" and (b = "2" or c = "3")`, `(a = "1" and (b = "2" or c = "3"))`},
+		{`((a = "1"))`, `a = "1"`},
+		{`(a = "1")and(b = "2")`, `(a = "1" and b = "2")`},
+
+		// typical criteria of the clients
+		{`(upnp:class derivedfrom "object.item.videoItem") and (dc:title contains "matrix")`,
+			`(upnp:class derivedfrom "object.item.videoItem" and dc:title contains "matrix")`},
+		{"upnp:class = \"object.container.storageFolder\"\tand\n@refID exists false",
+			`(upnp:class = "object.container.storageFolder" and @refID exists false)`},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.criteria)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.criteria, err)
+			continue
+		}
+		if got := expr.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s; want %s", tt.criteria, got, tt.want)
+		}
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	expr, err := Parse(`upnp:class derivedfrom "object.item" and (dc:title contains "a\"b" or upnp:genre exists true)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := And{
+		Left: Relation{Property: "upnp:class", Op: OpDerivedFrom, Value: "object.item"},
+		Right: Or{
+			Left:  Relation{Property: "dc:title", Op: OpContains, Value: `a"b`},
+			Right: Exists{Property: "upnp:genre", Exists: true},
+		},
+	}
+	if !reflect.DeepEqual(expr, want) {
+		t.Errorf("Parse() = %#v; want %#v", expr, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		criteria string
+		pos      int
+	}{
+		{`dc:title`, 8},
+		{`dc:title =`, 10},
+		{`dc:title = Matrix`, 11},
+		{`dc:title == "Matrix"`, 9},
+		{`dc:title =! "Matrix"`, 9},
+		{`dc:title like "Matrix"`, 9},
+		{`dc:title = "Matrix`, 11},
+		{`dc:title = "Matrix\"`, 11},
+		{`"Matrix" = dc:title`, 0},
+		{`upnp:genre exists`, 17},
+		{`upnp:genre exists yes`, 18},
+		{`upnp:genre exists "true"`, 18},
+		{`(dc:title = "a"`, 15},
+		{`dc:title = "a")`, 14},
+		{`()`, 1},
+		{`dc:title = "a" and`, 18},
+		{`dc:title = "a" or or b = "c"`, 21},
+		{`and dc:title = "a"`, 4},
+		{`dc:title = "a" dc:date = "b"`, 15},
+		{`dc:title = "a" xor dc:date = "b"`, 15},
+		{`*  and dc:title = "a"`, 3},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.criteria)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) = %v, %v; want syntax error", tt.criteria, expr, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos {
+			t.Errorf("Parse(%q): error at position %d; want %d (%v)", tt.criteria, syntaxErr.Pos, tt.pos, err)
+		}
+	}
+}
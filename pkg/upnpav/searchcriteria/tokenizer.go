@@ -0,0 +1,106 @@
+package searchcriteria
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenSymbol
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.value, keyword)
+}
+
+func (t token) unexpected(expected string) error {
+	if t.kind == tokenEOF {
+		return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected end of criteria, expected %s", expected)}
+	}
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected '%s', expected %s", t.value, expected)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isSymbol(c byte) bool {
+	return c == '=' || c == '!' || c == '<' || c == '>'
+}
+
+// tokenize splits search criteria to tokens, last token is always tokenEOF
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case isSpace(c):
+			i++
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, value: "(", pos: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, value: ")", pos: i})
+			i++
+
+		case c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, &SyntaxError{Pos: start, Msg: "unterminated quoted value"}
+				}
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					sb.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+				if s[i] == '"' {
+					i++
+					break
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, value: sb.String(), pos: start})
+
+		case isSymbol(c):
+			start := i
+			for i < len(s) && isSymbol(s[i]) {
+				i++
+			}
+			op := s[start:i]
+			switch Operator(op) {
+			case OpEqual, OpNotEqual, OpLess, OpLessOrEqual, OpGreater, OpGreaterOrEqual:
+				tokens = append(tokens, token{kind: tokenSymbol, value: op, pos: start})
+			default:
+				return nil, &SyntaxError{Pos: start, Msg: fmt.Sprintf("invalid operator '%s'", op)}
+			}
+
+		default:
+			start := i
+			for i < len(s) && !isSpace(s[i]) && !isSymbol(s[i]) && s[i] != '(' && s[i] != ')' && s[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: s[start:i], pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
@@ -6,7 +6,14 @@ import (
 )
 
 const (
-	NoSuchObjectErrorCode = 701
+	NoSuchObjectErrorCode          = 701
+	InvalidSearchCriteriaErrorCode = 708
+	NoSuchContainerErrorCode       = 710
+)
+
+const (
+	ClassStorageFolder = "object.container.storageFolder"
+	ClassVideoItem     = "object.item.videoItem"
 )
 
 // Resource description
@@ -25,7 +32,8 @@ type Resource struct {
 // Container description
 type Container struct {
 	Object
-	XMLName xml.Name `xml:"container"`
+	XMLName    xml.Name `xml:"container"`
+	Searchable int      `xml:"searchable,attr,omitempty"`
 }
 
 type Bookmark int64
@@ -39,7 +47,14 @@ type Item struct {
 	Object
 	XMLName  xml.Name `xml:"item"`
 	Res      []Resource
-	Bookmark Bookmark `xml:"sec:dcmInfo,omitempty"`
+	Bookmark Bookmark      `xml:"sec:dcmInfo,omitempty"`
+	Captions []CaptionInfo `xml:"sec:CaptionInfoEx,omitempty"`
+}
+
+// CaptionInfo is a link to external subtitles for Samsung TVs
+type CaptionInfo struct {
+	Type string `xml:"sec:type,attr"`
+	URL  string `xml:",chardata"`
 }
 
 type AlbumArtURI struct {
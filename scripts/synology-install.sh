@@ -13,11 +13,12 @@ cd "$(cd "$(dirname "${BASH_SOURCE[0]}")" &>/dev/null && pwd)" || {
 
 echo "SUDO: '${SUDO}'"
 
-echo "0. Drop database for godlna"
-$SUDO dropdb godlna
-
-echo "1. Create database for godlna"
-$SUDO createdb godlna -E utf8 -T template0
+echo "1. Create database for godlna (if not exists)"
+if $SUDO psql -lqt | cut -d '|' -f 1 | grep -qw godlna; then
+  echo "   database already exists"
+else
+  $SUDO createdb godlna -E utf8 -T template0
+fi
 
-echo "2. Install database schema"
-$SUDO psql godlna < ./schema.psql.sql
+# Database schema is installed and upgraded by godlna itself on startup,
+# it can be done manually by command: ./godlna db migrate